@@ -0,0 +1,145 @@
+package gzipped
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LogFormat is the format of the access log written by WithAccessLog.
+type LogFormat int
+
+const (
+	// CommonLog is the Common Log Format of NCSA httpd and Apache, followed
+	// by the content-coding sent and the time taken.
+	CommonLog LogFormat = iota
+	// CombinedLog is CommonLog with the Referer and User-Agent headers
+	// added before the content-coding, as Apache's and nginx's combined
+	// formats have them.
+	CombinedLog
+)
+
+// AccessLogger receives a structured record of each request. Its method has
+// the signature of (*slog.Logger).Info, so a *slog.Logger can be passed
+// straight to WithAccessLogger; args are alternating keys and values.
+type AccessLogger interface {
+	Info(msg string, args ...interface{})
+}
+
+// WithAccessLog writes a line to w for each request, in the given format,
+// such as
+//
+//	192.0.2.1 - - [14/Oct/2026:09:30:00 +0000] "GET /app.js HTTP/1.1" 200 5120 "https://example.com/" "Mozilla/5.0" br 0.001
+//
+// for CombinedLog. The usual fields are followed by the content-coding of
+// the body sent, "-" if it wasn't compressed, and the time taken in
+// seconds. Which encoding was negotiated is what generic logging middleware
+// can't tell you. Lines are written whole, one at a time.
+func WithAccessLog(w io.Writer, format LogFormat) Option {
+	return func(f *fileHandler) {
+		if f.access == nil {
+			f.access = &accessLog{}
+		}
+		f.access.w, f.access.format = w, format
+	}
+}
+
+// WithAccessLogger passes a record of each request to l at info level, with
+// the keys method, path, status, bytes, encoding, duration and client. The
+// encoding is "identity" if the body wasn't compressed, and "" if no file
+// was served.
+func WithAccessLogger(l AccessLogger) Option {
+	return func(f *fileHandler) {
+		if f.access == nil {
+			f.access = &accessLog{}
+		}
+		f.access.logger = l
+	}
+}
+
+type accessLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format LogFormat
+	logger AccessLogger
+}
+
+// info logs the request req to the AccessLogger.
+func (a *accessLog) info(req SlowRequest) {
+	r := req.Request
+	a.logger.Info("gzipped: request", "method", r.Method, "path", r.URL.Path, "status", req.Status,
+		"bytes", req.Bytes, "encoding", req.Encoding, "duration", req.Duration, "client", req.Client)
+}
+
+// write logs the request req, which started at start, to the access log
+// writer.
+func (a *accessLog) write(start time.Time, req SlowRequest) {
+	r := req.Request
+	b := make([]byte, 0, 256)
+	host, _, err := net.SplitHostPort(req.Client)
+	if err != nil {
+		host = req.Client
+	}
+	b = appendLogField(b, host)
+	b = append(b, " - "...)
+	user, _, _ := r.BasicAuth()
+	b = appendLogField(b, user)
+	b = append(b, " ["...)
+	b = start.AppendFormat(b, "02/Jan/2006:15:04:05 -0700")
+	b = append(b, `] "`...)
+	b = appendLogEscaped(b, r.Method+" "+r.URL.RequestURI()+" "+r.Proto, false)
+	b = append(b, `" `...)
+	b = strconv.AppendInt(b, int64(req.Status), 10)
+	b = append(b, ' ')
+	if req.Bytes == 0 {
+		b = append(b, '-')
+	} else {
+		b = strconv.AppendInt(b, req.Bytes, 10)
+	}
+	if a.format == CombinedLog {
+		b = append(b, ` "`...)
+		b = appendLogEscaped(b, r.Referer(), false)
+		b = append(b, `" "`...)
+		b = appendLogEscaped(b, r.UserAgent(), false)
+		b = append(b, '"')
+	}
+	b = append(b, ' ')
+	if req.Encoding == "" || req.Encoding == identityEncoding {
+		b = append(b, '-')
+	} else {
+		b = append(b, req.Encoding...)
+	}
+	b = append(b, ' ')
+	b = strconv.AppendFloat(b, req.Duration.Seconds(), 'f', 3, 64)
+	b = append(b, '\n')
+	a.mu.Lock()
+	a.w.Write(b)
+	a.mu.Unlock()
+}
+
+// appendLogField appends an unquoted field, "-" if it's empty, escaped so
+// that the line can still be split on spaces.
+func appendLogField(b []byte, s string) []byte {
+	if s == "" {
+		return append(b, '-')
+	}
+	return appendLogEscaped(b, s, true)
+}
+
+// appendLogEscaped appends s with quotes, backslashes and control
+// characters escaped as \xHH, as Apache does, so that clients can't forge
+// log lines. Spaces are escaped too if space is set.
+func appendLogEscaped(b []byte, s string, space bool) []byte {
+	const hex = "0123456789abcdef"
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < ' ' || c == '"' || c == '\\' || c >= 0x7f || space && c == ' ' {
+			b = append(b, '\\', 'x', hex[c>>4], hex[c&0xf])
+		} else {
+			b = append(b, c)
+		}
+	}
+	return b
+}
@@ -0,0 +1,75 @@
+package gzipped
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	var common, combined bytes.Buffer
+	h := FileServer(Dir("./testdata/"), WithAccessLog(&common, CommonLog))
+	hc := FileServer(Dir("./testdata/"), WithAccessLog(&combined, CombinedLog))
+	for _, handler := range []http.Handler{h, hc} {
+		req := httptest.NewRequest(http.MethodGet, "/file.txt?v=1", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("Referer", "https://example.com/")
+		req.Header.Set("User-Agent", `Evil "agent"`+"\n")
+		req.SetBasicAuth("alice", "secret")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		req = httptest.NewRequest(http.MethodGet, "/missing", nil)
+		req.RemoteAddr = "[2001:db8::1]:1234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	date := `\[\d\d/[A-Z][a-z]{2}/\d{4}:\d\d:\d\d:\d\d [-+]\d{4}\]`
+	for _, tc := range []struct {
+		log    string
+		expect []string
+	}{
+		{common.String(), []string{
+			`^192\.0\.2\.1 - alice ` + date + ` "GET /file\.txt\?v=1 HTTP/1\.1" 200 47 gzip \d+\.\d{3}$`,
+			`^2001:db8::1 - - ` + date + ` "GET /missing HTTP/1\.1" 404 19 - \d+\.\d{3}$`,
+		}},
+		{combined.String(), []string{
+			`^192\.0\.2\.1 - alice ` + date + ` "GET /file\.txt\?v=1 HTTP/1\.1" 200 47 "https://example\.com/" "Evil \\x22agent\\x22\\x0a" gzip \d+\.\d{3}$`,
+			`^2001:db8::1 - - ` + date + ` "GET /missing HTTP/1\.1" 404 19 "" "" - \d+\.\d{3}$`,
+		}},
+	} {
+		lines := strings.Split(strings.TrimSuffix(tc.log, "\n"), "\n")
+		if len(lines) != len(tc.expect) {
+			t.Fatalf("logged %q", tc.log)
+		}
+		for i, line := range lines {
+			if !regexp.MustCompile(tc.expect[i]).MatchString(line) {
+				t.Errorf("logged %s\nexpected %s", line, tc.expect[i])
+			}
+		}
+	}
+}
+
+// recordLogger records what's passed to its Info method.
+type recordLogger []string
+
+func (l *recordLogger) Info(msg string, args ...interface{}) {
+	*l = append(*l, fmt.Sprint(msg, args))
+}
+
+func TestAccessLogger(t *testing.T) {
+	var logged recordLogger
+	var slow []SlowRequest
+	h := FileServer(Dir("./testdata/"), WithAccessLogger(&logged), WithSlowRequestLog(0, func(sr SlowRequest) { slow = append(slow, sr) }))
+	req := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if len(logged) != 1 || !strings.HasPrefix(logged[0], "gzipped: request[method GET path /file.txt status 200 bytes ") ||
+		!strings.Contains(logged[0], "encoding identity duration") {
+		t.Errorf("logged %q", logged)
+	}
+	if len(slow) != 1 {
+		t.Errorf("slow request log got %d requests alongside the access log", len(slow))
+	}
+}
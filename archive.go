@@ -0,0 +1,245 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const archiveSuffix = ".tar.gz"
+
+// ArchiveConfig configures WithDirectoryArchives.
+type ArchiveConfig struct {
+	// MaxFiles is the most files an archive may contain. The default is
+	// 10000.
+	MaxFiles int
+	// MaxBytes is the most bytes of file content an archive may contain,
+	// before compression. The default is 1 GiB.
+	MaxBytes int64
+	// CacheSize is the memory in bytes set aside for keeping generated
+	// archives, so that repeated downloads of an unchanged directory neither
+	// recompress it nor lose range support. Zero disables caching.
+	CacheSize int64
+}
+
+// WithDirectoryArchives makes requests for /path/name.tar.gz, where there's no
+// such file but /path/name is a directory, download a gzipped tar archive of
+// the directory, generated on the fly. The FileSystem must implement
+// DirReader. Directories over the limits in cfg get 403 Forbidden.
+//
+// Archives have an ETag derived from the names, sizes and modification times
+// of their files, so unchanged directories aren't sent again to clients
+// which already have them.
+func WithDirectoryArchives(cfg ArchiveConfig) Option {
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = 10000
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 1 << 30
+	}
+	a := &archiver{cfg: cfg, cache: make(map[string][]byte)}
+	return func(f *fileHandler) {
+		f.archives = a
+	}
+}
+
+type archiver struct {
+	cfg   ArchiveConfig
+	mu    sync.Mutex
+	cache map[string][]byte // archives by ETag
+	used  int64
+}
+
+type archiveEntry struct {
+	name string // path within the archive
+	path string // path on the FileSystem
+	size int64
+	mode int64
+	mod  time.Time
+	dir  bool
+}
+
+// errArchiveTooLarge means a directory is over the archive limits.
+var errArchiveTooLarge = fmt.Errorf("directory too large to archive")
+
+// serve responds with an archive if fpath names one, reporting whether it
+// did.
+func (a *archiver) serve(f *fileHandler, w http.ResponseWriter, r *http.Request, fpath string) bool {
+	dr, ok := f.root.(DirReader)
+	if !ok || !strings.HasSuffix(fpath, archiveSuffix) {
+		return false
+	}
+	dir := strings.TrimSuffix(fpath, archiveSuffix)
+	if dir == "" || strings.HasSuffix(dir, "/") {
+		return false
+	}
+	top := path.Base(dir)
+	entries := []archiveEntry{{name: top + "/", path: dir, mode: 0o755, dir: true}}
+	var total int64
+	entries, err := a.walk(f, dr, dir, top+"/", entries, &total)
+	if err == errArchiveTooLarge {
+		serveErrorText(w, r, "Directory too large to archive", http.StatusForbidden)
+		return true
+	}
+	if err != nil {
+		// Most likely not a directory at all.
+		return false
+	}
+
+	// The directory's signature identifies the archive.
+	sig := sha256.New()
+	var modTime time.Time
+	for _, e := range entries {
+		fmt.Fprintf(sig, "%s\x00%d\x00%d\x00", e.name, e.size, e.mod.UnixNano())
+		if e.mod.After(modTime) {
+			modTime = e.mod
+		}
+	}
+	etag := `"` + hex.EncodeToString(sig.Sum(nil)[:16]) + `"`
+
+	h := w.Header()
+	h.Set("Etag", etag)
+	h.Set(contentTypeHeader, "application/gzip")
+	h.Set("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(top, `"`, "")+archiveSuffix+`"`)
+
+	if a.cfg.CacheSize > 0 && total <= a.cfg.CacheSize {
+		data, err := a.cached(f, etag, entries)
+		if err != nil {
+			f.serveError(w, r, err)
+			return true
+		}
+		http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+		return true
+	}
+
+	if !isZeroTime(modTime) {
+		h.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	if notModified(w, r, modTime) {
+		writeNotModified(w)
+		return true
+	}
+	h.Set("Accept-Ranges", "none")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		if err := writeArchive(w, f.root, entries); err != nil {
+			// Too late for an error response; the client sees a truncated
+			// archive.
+			f.reportError(r, err)
+		}
+	}
+	return true
+}
+
+// walk lists the directory dir recursively, checking the limits. Files and
+// directories WithoutDotfiles hides are left out, as they can't be fetched
+// on their own either.
+func (a *archiver) walk(f *fileHandler, dr DirReader, dir, prefix string, entries []archiveEntry, total *int64) ([]archiveEntry, error) {
+	des, err := dr.ReadDir(dir)
+	if err != nil {
+		return entries, err
+	}
+	for _, de := range des {
+		if f.hideDotfiles && strings.HasPrefix(de.Name(), ".") && !f.dotfileAllowed(de.Name()) {
+			continue
+		}
+		p := path.Join(dir, de.Name())
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		if de.IsDir() {
+			entries = append(entries, archiveEntry{name: prefix + de.Name() + "/", path: p, mode: 0o755, mod: info.ModTime(), dir: true})
+			if entries, err = a.walk(f, dr, p, prefix+de.Name()+"/", entries, total); err != nil {
+				return entries, err
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		*total += info.Size()
+		entries = append(entries, archiveEntry{name: prefix + de.Name(), path: p, size: info.Size(), mode: 0o644, mod: info.ModTime()})
+		if len(entries) > a.cfg.MaxFiles || *total > a.cfg.MaxBytes {
+			return entries, errArchiveTooLarge
+		}
+	}
+	return entries, nil
+}
+
+// cached returns the archive with the given ETag, generating and caching it
+// if need be.
+func (a *archiver) cached(f *fileHandler, etag string, entries []archiveEntry) ([]byte, error) {
+	a.mu.Lock()
+	data, ok := a.cache[etag]
+	a.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	if err := writeArchive(&buf, f.root, entries); err != nil {
+		return nil, err
+	}
+	data = buf.Bytes()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if int64(len(data)) > a.cfg.CacheSize {
+		return data, nil
+	}
+	for k, old := range a.cache {
+		if a.used+int64(len(data)) <= a.cfg.CacheSize {
+			break
+		}
+		a.used -= int64(len(old))
+		delete(a.cache, k)
+	}
+	a.cache[etag] = data
+	a.used += int64(len(data))
+	return data, nil
+}
+
+// writeArchive writes the entries to w as a gzipped tar archive.
+func writeArchive(w io.Writer, root FileSystem, entries []archiveEntry) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: e.mode, ModTime: e.mod, Format: tar.FormatPAX}
+		if e.dir {
+			hdr.Typeflag = tar.TypeDir
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			continue
+		}
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = e.size
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		file, err := root.Open(e.path)
+		if err != nil {
+			return err
+		}
+		// Copy exactly the size recorded, in case the file has changed.
+		_, err = io.CopyN(tw, file, e.size)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("archiving %s: %w", e.path, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
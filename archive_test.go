@@ -0,0 +1,152 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectoryArchives(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"reports/2024/a.txt":     "first report\n",
+		"reports/2024/sub/b.txt": "second report\n",
+		"reports/old.tar.gz":     "a real file",
+	}
+	for name, content := range files {
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, cacheSize := range []int64{0, 1 << 20} {
+		h := FileServer(Dir(dir), WithDirectoryArchives(ArchiveConfig{CacheSize: cacheSize}))
+		get := func(p string, hdr ...string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, p, nil)
+			for i := 0; i < len(hdr); i += 2 {
+				req.Header.Set(hdr[i], hdr[i+1])
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := get("/reports/2024.tar.gz")
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/gzip" {
+			t.Fatalf("cache %d: status %d, Content-Type %q", cacheSize, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="2024.tar.gz"` {
+			t.Errorf("cache %d: Content-Disposition %q", cacheSize, got)
+		}
+		if (rec.Header().Get("Content-Length") != "") != (cacheSize > 0) {
+			t.Errorf("cache %d: Content-Length %q", cacheSize, rec.Header().Get("Content-Length"))
+		}
+		got := readArchive(t, rec.Body)
+		expect := map[string]string{
+			"2024/":          "",
+			"2024/a.txt":     "first report\n",
+			"2024/sub/":      "",
+			"2024/sub/b.txt": "second report\n",
+		}
+		if len(got) != len(expect) {
+			t.Errorf("cache %d: archive contains %v", cacheSize, got)
+		}
+		for name, content := range expect {
+			if c, ok := got[name]; !ok || c != content {
+				t.Errorf("cache %d: archive entry %s is %q", cacheSize, name, c)
+			}
+		}
+
+		etag := rec.Header().Get("Etag")
+		if rec := get("/reports/2024.tar.gz", "If-None-Match", etag); rec.Code != http.StatusNotModified {
+			t.Errorf("cache %d: If-None-Match status %d", cacheSize, rec.Code)
+		}
+		if rec := get("/reports/old.tar.gz"); rec.Body.String() != "a real file" {
+			t.Errorf("cache %d: real archive file not served", cacheSize)
+		}
+		if rec := get("/reports/missing.tar.gz"); rec.Code != http.StatusNotFound {
+			t.Errorf("cache %d: missing directory status %d", cacheSize, rec.Code)
+		}
+		if rec := get("/reports/2024/a.txt.tar.gz"); rec.Code != http.StatusNotFound {
+			t.Errorf("cache %d: archive of a file status %d", cacheSize, rec.Code)
+		}
+	}
+
+	h := FileServer(Dir(dir), WithDirectoryArchives(ArchiveConfig{MaxFiles: 2}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports/2024.tar.gz", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("over the file limit: status %d", rec.Code)
+	}
+	h = FileServer(Dir(dir), WithDirectoryArchives(ArchiveConfig{MaxBytes: 20}))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports/2024.tar.gz", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("over the size limit: status %d", rec.Code)
+	}
+}
+
+func readArchive(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(zr)
+	got := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[hdr.Name] = string(data)
+	}
+}
+
+func TestDirectoryArchivesWithoutDotfiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"site/index.html", "site/.env", "site/.git/config", "site/.well-known/security.txt"} {
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := FileServer(Dir(dir), WithDirectoryArchives(ArchiveConfig{}), WithoutDotfiles(".well-known"))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/site.tar.gz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	got := readArchive(t, rec.Body)
+	for _, name := range []string{"site/.env", "site/.git/", "site/.git/config"} {
+		if _, ok := got[name]; ok {
+			t.Errorf("archive contains hidden %s", name)
+		}
+	}
+	for _, name := range []string{"site/index.html", "site/.well-known/security.txt"} {
+		if _, ok := got[name]; !ok {
+			t.Errorf("archive is missing %s: %v", name, got)
+		}
+	}
+}
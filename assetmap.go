@@ -0,0 +1,140 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// AssetMap maps logical asset names, such as /app.js, to the names of the
+// current content-hashed files, such as /app.3f9c2a.js. The same map can be
+// used by HTML templates to write links and by FileServer to serve or
+// redirect requests for the logical names, so they always agree.
+//
+// An AssetMap is immutable, and safe for concurrent use.
+type AssetMap struct {
+	names map[string]string
+}
+
+// NewAssetMap returns an AssetMap from logical names to hashed names. Names
+// are URL paths; a leading slash is added if missing.
+func NewAssetMap(names map[string]string) *AssetMap {
+	a := &AssetMap{names: make(map[string]string, len(names))}
+	for logical, hashed := range names {
+		a.names[cleanAssetPath(logical)] = cleanAssetPath(hashed)
+	}
+	return a
+}
+
+// LoadAssetMap reads the named JSON manifest from root, as written by bundlers
+// such as webpack's manifest plugin or Vite. The manifest is an object whose
+// keys are logical names, and whose values are either hashed names or objects
+// with the hashed name in a "file" field.
+func LoadAssetMap(root FileSystem, name string) (*AssetMap, error) {
+	file, err := root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("gzipped: asset manifest %s: %w", name, err)
+	}
+	names := make(map[string]string, len(raw))
+	for logical, v := range raw {
+		var hashed string
+		if err := json.Unmarshal(v, &hashed); err != nil {
+			var entry struct {
+				File string `json:"file"`
+			}
+			if err := json.Unmarshal(v, &entry); err != nil || entry.File == "" {
+				return nil, fmt.Errorf("gzipped: asset manifest %s: no file name for %q", name, logical)
+			}
+			hashed = entry.File
+		}
+		names[logical] = hashed
+	}
+	return NewAssetMap(names), nil
+}
+
+func cleanAssetPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// Resolve returns the hashed name for a logical name, and whether there was
+// one.
+func (a *AssetMap) Resolve(logical string) (string, bool) {
+	hashed, ok := a.names[cleanAssetPath(logical)]
+	return hashed, ok
+}
+
+// Path returns the hashed name for a logical name, or the logical name itself
+// if it isn't in the map. It's intended for use in templates.
+func (a *AssetMap) Path(logical string) string {
+	if hashed, ok := a.Resolve(logical); ok {
+		return hashed
+	}
+	return logical
+}
+
+// WithAssetMap makes the handler serve requests for logical names in a with
+// the current hashed file, as if the hashed name had been requested.
+func WithAssetMap(a *AssetMap) Option {
+	return func(f *fileHandler) {
+		f.assets, f.assetRedirect = a, false
+	}
+}
+
+// WithAssetRedirect makes the handler redirect requests for logical names in
+// a to the current hashed name, with 302 Found since the target changes with
+// each deploy. The redirect is relative, so that it works behind Mount or
+// http.StripPrefix.
+func WithAssetRedirect(a *AssetMap) Option {
+	return func(f *fileHandler) {
+		f.assets, f.assetRedirect = a, true
+	}
+}
+
+// resolveAsset handles a request for a logical asset name. It returns the
+// path to serve instead, or "" if it has sent a redirect.
+func (f *fileHandler) resolveAsset(w http.ResponseWriter, r *http.Request, fpath string) string {
+	hashed, ok := f.assets.names[fpath]
+	if !ok {
+		return fpath
+	}
+	if !f.assetRedirect {
+		return hashed
+	}
+	// Relative, so that it works behind Mount or http.StripPrefix.
+	redirect(w, r, relativePath(path.Dir(fpath), hashed), http.StatusFound)
+	return ""
+}
+
+// relativePath returns the relative URL path from the directory dir to the
+// file target, both cleaned and absolute.
+func relativePath(dir, target string) string {
+	from := strings.Split(strings.Trim(dir, "/"), "/")
+	to := strings.Split(strings.TrimPrefix(target, "/"), "/")
+	if from[0] == "" {
+		from = nil
+	}
+	n := 0
+	for n < len(from) && n < len(to)-1 && from[n] == to[n] {
+		n++
+	}
+	rel := strings.Repeat("../", len(from)-n) + strings.Join(to[n:], "/")
+	if n == len(from) && (strings.HasPrefix(to[n], ".") || strings.Contains(to[n], ":")) {
+		// Don't let the name read as a dot segment or a URL scheme.
+		rel = "./" + rel
+	}
+	return rel
+}
@@ -0,0 +1,134 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadAssetMap(t *testing.T) {
+	root := FS(fstest.MapFS{
+		"webpack.json": {Data: []byte(`{"app.js": "app.3f9c2a.js", "/css/site.css": "/css/site.77ab.css"}`)},
+		"vite.json":    {Data: []byte(`{"src/main.ts": {"file": "assets/main.4b1c.js", "src": "src/main.ts"}}`)},
+		"bad.json":     {Data: []byte(`{"app.js": 3}`)},
+		"broken.json":  {Data: []byte(`{`)},
+	})
+	a, err := LoadAssetMap(root, "/webpack.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for logical, expect := range map[string]string{
+		"/app.js":       "/app.3f9c2a.js",
+		"app.js":        "/app.3f9c2a.js",
+		"/css/site.css": "/css/site.77ab.css",
+		"/other.js":     "/other.js",
+	} {
+		if got := a.Path(logical); got != expect {
+			t.Errorf("Path(%q) = %q, expected %q", logical, got, expect)
+		}
+	}
+	if _, ok := a.Resolve("/other.js"); ok {
+		t.Error("resolved a name not in the manifest")
+	}
+
+	a, err = LoadAssetMap(root, "/vite.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Path("/src/main.ts"); got != "/assets/main.4b1c.js" {
+		t.Errorf("Vite manifest resolved to %q", got)
+	}
+
+	for _, name := range []string{"/bad.json", "/broken.json", "/missing.json"} {
+		if _, err := LoadAssetMap(root, name); err == nil {
+			t.Errorf("loaded %s without error", name)
+		}
+	}
+}
+
+func TestAssetMapServing(t *testing.T) {
+	a := NewAssetMap(map[string]string{"logical.txt": "file.txt"})
+
+	req := httptest.NewRequest(http.MethodGet, "/logical.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	FileServer(Dir("./testdata/"), WithAssetMap(a)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("serving in place: status %d, Content-Encoding %q", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/logical.txt?v=1", nil)
+	rec = httptest.NewRecorder()
+	FileServer(Dir("./testdata/"), WithAssetRedirect(a)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "file.txt?v=1" {
+		t.Errorf("redirect: status %d, Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/file2.txt", nil)
+	rec = httptest.NewRecorder()
+	FileServer(Dir("./testdata/"), WithAssetRedirect(a)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("unmapped file: status %d", rec.Code)
+	}
+
+	// The logical name isn't fingerprinted, even though the file served is.
+	h := FileServer(Dir("./testdata/"), WithAssetMap(a), WithImmutableAssets(regexp.MustCompile(`^file\.`), "no-cache"))
+	for path, expect := range map[string]string{"/logical.txt": "no-cache", "/file.txt": "public, max-age=31536000, immutable"} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := rec.Header().Get("Cache-Control"); got != expect {
+			t.Errorf("GET %s with immutable assets: Cache-Control %q", path, got)
+		}
+	}
+}
+
+func TestAssetRedirectUnderMount(t *testing.T) {
+	a := NewAssetMap(map[string]string{
+		"/app.js":        "/app.3f9c2a1b.js",
+		"/css/site.css":  "/css/site.0a1b2c3d.css",
+		"/css/theme.css": "/dist/theme.0a1b2c3d.css",
+		"/js/vendor.js":  "/vendor.0a1b2c3d.js",
+	})
+	h := Mount("/static", Dir("./testdata/"), WithAssetRedirect(a))
+	for req, expect := range map[string]string{
+		"/static/app.js?v=1":    "app.3f9c2a1b.js?v=1",
+		"/static/css/site.css":  "site.0a1b2c3d.css",
+		"/static/css/theme.css": "../dist/theme.0a1b2c3d.css",
+		"/static/js/vendor.js":  "../vendor.0a1b2c3d.js",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, req, nil))
+		loc := rec.Header().Get("Location")
+		if rec.Code != http.StatusFound || loc != expect {
+			t.Errorf("GET %s: status %d, Location %q, expected %q", req, rec.Code, loc, expect)
+			continue
+		}
+		base, _ := url.Parse("http://example.com" + req)
+		target, _ := url.Parse(loc)
+		if got := base.ResolveReference(target).Path; !strings.HasPrefix(got, "/static/") {
+			t.Errorf("GET %s: redirect resolves to %s, outside the mount", req, got)
+		}
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	for _, tc := range []struct{ dir, target, expect string }{
+		{"/", "/app.1a2b.js", "app.1a2b.js"},
+		{"/", "/css/site.1a2b.css", "css/site.1a2b.css"},
+		{"/css", "/css/site.1a2b.css", "site.1a2b.css"},
+		{"/a/b", "/a/c/x.js", "../c/x.js"},
+		{"/a", "/x.js", "../x.js"},
+		{"/", "/.well-known/x", "./.well-known/x"},
+		{"/", "/c:x.js", "./c:x.js"},
+	} {
+		if got := relativePath(tc.dir, tc.target); got != tc.expect {
+			t.Errorf("relativePath(%q, %q) = %q, expected %q", tc.dir, tc.target, got, tc.expect)
+		}
+	}
+}
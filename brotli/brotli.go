@@ -0,0 +1,33 @@
+// Package brotli provides a brotli encoder for gzipped.CompressWith, so that
+// responses compressed on the fly can use the br content-coding as well as
+// gzip. It's a separate module, so that the gzipped package itself only
+// depends on the standard library:
+//
+//	h := gzipped.CompressWith(gzipped.CompressConfig{
+//		Encoders: []gzipped.CompressEncoder{brotli.Encoder(5)},
+//	}, app)
+package brotli
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/lpar/gzipped/v2"
+)
+
+// Encoder returns a CompressEncoder for the br content-coding, compressing
+// at quality, from brotli.BestSpeed (0) to brotli.BestCompression (11).
+// Qualities around 4 to 6 suit compressing every response on the fly;
+// higher ones are much slower. Out of range values mean
+// brotli.DefaultCompression.
+func Encoder(quality int) gzipped.CompressEncoder {
+	if quality < brotli.BestSpeed || quality > brotli.BestCompression {
+		quality = brotli.DefaultCompression
+	}
+	return gzipped.CompressEncoder{
+		Name: "br",
+		New: func(w io.Writer) gzipped.Encoder {
+			return brotli.NewWriterLevel(w, quality)
+		},
+	}
+}
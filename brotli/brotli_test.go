@@ -0,0 +1,40 @@
+package brotli
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/lpar/gzipped/v2"
+)
+
+func TestEncoder(t *testing.T) {
+	body := strings.Repeat("brotli compressed on the fly ", 100)
+	h := gzipped.CompressWith(gzipped.CompressConfig{
+		Encoders: []gzipped.CompressEncoder{Encoder(5)},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	for _, ae := range []string{"gzip, br", "br", "br, gzip"} {
+		// Twice, so that a pooled encoder is reused.
+		for n := 0; n < 2; n++ {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", ae)
+			h.ServeHTTP(rr, req)
+			if ce := rr.Header().Get("Content-Encoding"); ce != "br" {
+				t.Fatalf("Accept-Encoding %q: Content-Encoding %q", ae, ce)
+			}
+			got, err := io.ReadAll(brotli.NewReader(rr.Body))
+			if err != nil || string(got) != body {
+				t.Errorf("Accept-Encoding %q: decoded %d bytes, %v", ae, len(got), err)
+			}
+		}
+	}
+	if e := Encoder(99); e.Name != "br" || e.New == nil {
+		t.Errorf("Encoder(99) = %+v", e)
+	}
+}
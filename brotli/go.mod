@@ -0,0 +1,8 @@
+module github.com/lpar/gzipped/v2/brotli
+
+go 1.20
+
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/lpar/gzipped/v2 v2.0.0-20261014184714-868b424a328c
+)
@@ -0,0 +1,119 @@
+package gzipped
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+)
+
+// BufferSizeClass sets the size of buffer used to copy files of up to a given
+// size to the client.
+type BufferSizeClass struct {
+	// MaxFileSize is the size in bytes of the largest file this class
+	// applies to. Zero means there's no limit.
+	MaxFileSize int64
+	// BufferSize is the size in bytes of the copy buffer.
+	BufferSize int
+}
+
+// WithBufferSizes sets the size of the buffers used to stream file content to
+// clients, by size of file. For example, large buffers give better
+// throughput for big files on high bandwidth-delay links, while small ones
+// minimize memory use when there are many concurrent transfers of small
+// files. Files larger than every class use the largest class's buffers.
+//
+// Files which can be sent by the operating system directly from disk (using
+// sendfile or similar) still are, and don't need a buffer.
+func WithBufferSizes(classes ...BufferSizeClass) Option {
+	bp := &bufferPools{}
+	for _, c := range classes {
+		if c.BufferSize <= 0 {
+			continue
+		}
+		size := c.BufferSize
+		bp.classes = append(bp.classes, bufferClass{
+			max: c.MaxFileSize,
+			pool: &sync.Pool{New: func() interface{} {
+				b := make([]byte, size)
+				return &b
+			}},
+		})
+	}
+	// Order by file size limit, with the unlimited class last.
+	sort.SliceStable(bp.classes, func(i, j int) bool {
+		mi, mj := bp.classes[i].max, bp.classes[j].max
+		return mi != 0 && (mj == 0 || mi < mj)
+	})
+	return func(f *fileHandler) {
+		if len(bp.classes) > 0 {
+			f.buffers = bp
+		}
+	}
+}
+
+type bufferClass struct {
+	max  int64
+	pool *sync.Pool
+}
+
+// bufferPools holds the pools of buffers for each size class.
+type bufferPools struct {
+	classes []bufferClass
+}
+
+// forSize returns the pool of buffers to use for a file of size bytes.
+func (bp *bufferPools) forSize(size int64) *sync.Pool {
+	for _, c := range bp.classes {
+		if c.max == 0 || size <= c.max {
+			return c.pool
+		}
+	}
+	return bp.classes[len(bp.classes)-1].pool
+}
+
+// bufferedWriter wraps a ResponseWriter so that content copied into it with
+// io.Copy, as http.ServeContent does, goes through a buffer from a pool.
+type bufferedWriter struct {
+	http.ResponseWriter
+	pool *sync.Pool
+}
+
+// ReadFrom copies from src using a pooled buffer, unless src is a file which
+// the underlying writer can send more efficiently itself.
+func (b *bufferedWriter) ReadFrom(src io.Reader) (int64, error) {
+	if rf, ok := b.ResponseWriter.(io.ReaderFrom); ok && isOSFile(src) {
+		return rf.ReadFrom(src)
+	}
+	buf := b.pool.Get().(*[]byte)
+	defer b.pool.Put(buf)
+	// Hide our own ReadFrom, and the underlying writer's, from CopyBuffer.
+	return io.CopyBuffer(writerOnly{b.ResponseWriter}, src, *buf)
+}
+
+// Flush passes flushes through to the underlying writer, if it supports them.
+func (b *bufferedWriter) Flush() {
+	if fl, ok := b.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (b *bufferedWriter) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
+
+type writerOnly struct {
+	io.Writer
+}
+
+// isOSFile reports whether r reads from an operating system file, as
+// http.ServeContent's reads of a Dir file do.
+func isOSFile(r io.Reader) bool {
+	if lr, ok := r.(*io.LimitedReader); ok {
+		r = lr.R
+	}
+	_, ok := r.(*os.File)
+	return ok
+}
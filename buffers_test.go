@@ -0,0 +1,47 @@
+package gzipped
+
+import (
+	fs2 "io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// chunkRecorder records the size of the largest single write.
+type chunkRecorder struct {
+	*httptest.ResponseRecorder
+	largest int
+}
+
+func (c *chunkRecorder) Write(b []byte) (int, error) {
+	if len(b) > c.largest {
+		c.largest = len(b)
+	}
+	return c.ResponseRecorder.Write(b)
+}
+
+func TestBufferSizes(t *testing.T) {
+	sub, err := fs2.Sub(testData, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := FileServer(FS(sub), WithBufferSizes(
+		BufferSizeClass{BufferSize: 16},
+		BufferSizeClass{MaxFileSize: 10, BufferSize: 4},
+		BufferSizeClass{MaxFileSize: 25, BufferSize: 8},
+	))
+	for path, expect := range map[string]int{
+		"/file2.txt": 8,  // 20 bytes
+		"/file.txt":  16, // 27 bytes
+	} {
+		rr := &chunkRecorder{ResponseRecorder: httptest.NewRecorder()}
+		req, _ := http.NewRequest("GET", path, nil)
+		h.ServeHTTP(rr, req)
+		if rr.Code != 200 {
+			t.Fatalf("GET %s returned %d", path, rr.Code)
+		}
+		if rr.largest != expect {
+			t.Errorf("GET %s was written in chunks of up to %d bytes, expected %d", path, rr.largest, expect)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// buildValidators supplies an ETag and modification time for files whose
+// FileSystem reports no modification time, such as an embed.FS.
+type buildValidators struct {
+	version string
+	modTime time.Time
+	etags   map[string][]string // ETag header values by encoding
+}
+
+// WithBuildValidators gives files which have no modification time, such as
+// those in an embed.FS, validators derived from the build of the program, so
+// that clients can make conditional requests for them. Since embedded files
+// can only change when the binary does, they count as modified at build time,
+// and each representation gets an ETag made from the build version and its
+// encoding.
+//
+// If version is empty, the VCS revision recorded in the binary's build info
+// is used, or failing that the main module version. If modTime is zero, the
+// VCS commit time is used. If no version or time can be found, there's no
+// ETag or Last-Modified respectively.
+func WithBuildValidators(version string, modTime time.Time) Option {
+	if version == "" || modTime.IsZero() {
+		v, t := buildInfoValidators()
+		if version == "" {
+			version = v
+		}
+		if modTime.IsZero() {
+			modTime = t
+		}
+	}
+	b := &buildValidators{version: etagSafe(version), modTime: modTime, etags: map[string][]string{}}
+	if b.version != "" {
+		for _, enc := range preferredEncodings {
+			b.etags[enc.name] = []string{b.etag(enc.name)}
+		}
+	}
+	return func(f *fileHandler) {
+		f.build = b
+	}
+}
+
+// buildInfoValidators finds a version and time for the running binary.
+func buildInfoValidators() (string, time.Time) {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", time.Time{}
+	}
+	var version string
+	var modTime time.Time
+	modified := false
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			version = s.Value
+		case "vcs.time":
+			modTime, _ = time.Parse(time.RFC3339, s.Value)
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if version != "" && modified {
+		// Uncommitted changes mean the revision doesn't identify the content.
+		version, modTime = "", time.Time{}
+	}
+	if version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		version = bi.Main.Version
+	}
+	return version, modTime
+}
+
+// etagSafe replaces characters which aren't allowed in an entity tag.
+func etagSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '"' || r >= 0x7f {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func (b *buildValidators) etag(encoding string) string {
+	return `"` + b.version + "-" + encoding + `"`
+}
+
+// apply sets the ETag for a variant with no modification time, and returns
+// the FileInfo to serve it with.
+func (b *buildValidators) apply(w http.ResponseWriter, v variant) os.FileInfo {
+	h := w.Header()
+	if _, ok := h["Etag"]; !ok && b.version != "" {
+		enc := v.encoding
+		if v.decode {
+			enc = identityEncoding
+		}
+		if etag, ok := b.etags[enc]; ok {
+			h["Etag"] = etag
+		} else {
+			h["Etag"] = []string{b.etag(enc)}
+		}
+	}
+	if b.modTime.IsZero() {
+		return v.info
+	}
+	return modTimeInfo{FileInfo: v.info, modTime: b.modTime}
+}
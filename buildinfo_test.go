@@ -0,0 +1,99 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	fs2 "io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBuildValidators(t *testing.T) {
+	sub, err := fs2.Sub(testData, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	built := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	h := FileServer(FS(sub), WithBuildValidators(`v1.2 "x"`, built))
+
+	get := func(path, ae string, hdr ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ae != "" {
+			req.Header.Set("Accept-Encoding", ae)
+		}
+		for i := 0; i < len(hdr); i += 2 {
+			req.Header.Set(hdr[i], hdr[i+1])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	etags := map[string]bool{}
+	for _, tc := range []struct {
+		path, ae string
+	}{
+		{"/file.txt", ""},
+		{"/file.txt", "gzip"},
+		{"/app.js", "br"},
+		{"/app.js", ""}, // decompressed on the fly
+	} {
+		rec := get(tc.path, tc.ae)
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s (%q): status %d", tc.path, tc.ae, rec.Code)
+			continue
+		}
+		etag := rec.Header().Get("Etag")
+		if etag == "" {
+			t.Errorf("GET %s (%q): no ETag", tc.path, tc.ae)
+			continue
+		}
+		if etag[0] != '"' || etag[len(etag)-1] != '"' || len(etag) < 3 {
+			t.Errorf("GET %s (%q): malformed ETag %s", tc.path, tc.ae, etag)
+		}
+		etags[tc.path+" "+etag] = true
+		if got := rec.Header().Get("Last-Modified"); got != built.Format(http.TimeFormat) {
+			t.Errorf("GET %s (%q): Last-Modified %q", tc.path, tc.ae, got)
+		}
+		if rec := get(tc.path, tc.ae, "If-None-Match", etag); rec.Code != http.StatusNotModified {
+			t.Errorf("GET %s (%q) If-None-Match: status %d, expected 304", tc.path, tc.ae, rec.Code)
+		}
+		if rec := get(tc.path, tc.ae, "If-Modified-Since", built.Format(http.TimeFormat)); rec.Code != http.StatusNotModified {
+			t.Errorf("GET %s (%q) If-Modified-Since: status %d, expected 304", tc.path, tc.ae, rec.Code)
+		}
+		if rec := get(tc.path, tc.ae, "If-None-Match", `"other"`); rec.Code != http.StatusOK {
+			t.Errorf("GET %s (%q) non-matching If-None-Match: status %d, expected 200", tc.path, tc.ae, rec.Code)
+		}
+	}
+	if len(etags) != 4 {
+		t.Errorf("representations don't have distinct ETags: %v", etags)
+	}
+
+	// Files with real modification times keep them, and get no ETag.
+	h = FileServer(Dir("./testdata/"), WithBuildValidators("v1", built))
+	rec := get("/file.txt", "")
+	if rec.Header().Get("Etag") != "" || rec.Header().Get("Last-Modified") == built.Format(http.TimeFormat) {
+		t.Errorf("build validators applied to a file with a modification time")
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	for _, tc := range []struct {
+		inm, etag string
+		expect    bool
+	}{
+		{`"a"`, `"a"`, true},
+		{`"b", "a"`, `"a"`, true},
+		{`W/"a"`, `"a"`, true},
+		{`"a"`, `W/"a"`, true},
+		{`*`, `"a"`, true},
+		{`"b"`, `"a"`, false},
+		{`"a"`, ``, false},
+	} {
+		if got := etagMatches(tc.inm, tc.etag); got != tc.expect {
+			t.Errorf("etagMatches(%s, %s) = %v", tc.inm, tc.etag, got)
+		}
+	}
+}
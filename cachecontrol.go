@@ -0,0 +1,79 @@
+package gzipped
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// cacheRule is a Cache-Control value for files matching a pattern.
+type cacheRule struct {
+	pattern string
+	value   []string
+}
+
+// WithCacheControl sets the Cache-Control header to value on responses for
+// files matching pattern, as MatchPattern matches them, such as
+//
+//	gzipped.WithCacheControl("/assets/*", "public, max-age=31536000, immutable")
+//
+// The option can be given more than once, and the first matching pattern
+// wins, so a default for everything else can be given last with the pattern
+// "*". Giving a pattern again replaces its value. Responses which already
+// have a Cache-Control header, set by a handler in front of this one, are
+// left alone, as are error responses.
+func WithCacheControl(pattern, value string) Option {
+	return func(f *fileHandler) {
+		for i := range f.cacheRules {
+			if f.cacheRules[i].pattern == pattern {
+				f.cacheRules[i].value = []string{value}
+				return
+			}
+		}
+		f.cacheRules = append(f.cacheRules, cacheRule{pattern: pattern, value: []string{value}})
+	}
+}
+
+// setCacheControl sets the Cache-Control header for the file fpath, served
+// in response to r.
+func (f *fileHandler) setCacheControl(w http.ResponseWriter, r *http.Request, fpath string) {
+	h := w.Header()
+	if _, ok := h["Cache-Control"]; ok {
+		return
+	}
+	if f.immutable != nil && f.immutable.fingerprinted(r.URL.Path) {
+		h["Cache-Control"] = immutableCacheControl
+		return
+	}
+	fpath = f.untranslated(fpath)
+	for _, rule := range f.cacheRules {
+		if MatchPattern(rule.pattern, fpath) {
+			h["Cache-Control"] = rule.value
+			return
+		}
+	}
+	if f.immutable != nil && f.immutable.otherwise != nil {
+		h["Cache-Control"] = f.immutable.otherwise
+	}
+}
+
+// MatchPattern reports whether the file path fpath matches pattern, the way
+// options such as WithCacheControl and RangesForPatterns match their
+// patterns. Patterns are in the syntax of path.Match. A pattern containing a
+// slash is matched against the whole path, and also matches everything
+// below a directory it matches, so "/assets/*" matches "/assets/js/app.js".
+// Other patterns are matched against the file name.
+func MatchPattern(pattern, fpath string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(fpath))
+		return ok
+	}
+	for p := fpath; ; p = path.Dir(p) {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+		if p == "/" || p == "." {
+			return false
+		}
+	}
+}
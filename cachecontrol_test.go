@@ -0,0 +1,62 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, fpath string
+		expect         bool
+	}{
+		{"*.js", "/assets/js/app.js", true},
+		{"*.js", "/app.json", false},
+		{"/assets/*", "/assets/app.js", true},
+		{"/assets/*", "/assets/js/app.js", true},
+		{"/assets/*", "/assetsx/app.js", false},
+		{"/assets/*.js", "/assets/js/app.css", false},
+		{"/*/index.html", "/docs/index.html", true},
+		{"*", "/anything/at/all", true},
+	} {
+		if got := MatchPattern(tc.pattern, tc.fpath); got != tc.expect {
+			t.Errorf("MatchPattern(%q, %q) = %v", tc.pattern, tc.fpath, got)
+		}
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	h := FileServer(Dir("./testdata/"),
+		WithCacheControl("*.js", "public, max-age=31536000, immutable"),
+		WithCacheControl("*", "public, max-age=60"),
+		WithCacheControl("*", "no-cache"))
+	for _, tc := range []struct {
+		path, ims, expect string
+		status            int
+	}{
+		{"/app.js", "", "public, max-age=31536000, immutable", 200},
+		{"/file.txt", "", "no-cache", 200},
+		{"/file.txt", "Thu, 01 Jan 2099 00:00:00 GMT", "no-cache", 304},
+		{"/nonexistent.txt", "", "", 404},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		if tc.ims != "" {
+			req.Header.Set("If-Modified-Since", tc.ims)
+		}
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Header().Get("Cache-Control") != tc.expect {
+			t.Errorf("GET %s: %d with Cache-Control %q, expected %d %q", tc.path, rr.Code, rr.Header().Get("Cache-Control"), tc.status, tc.expect)
+		}
+	}
+
+	// Headers set in front of the handler win.
+	rr := httptest.NewRecorder()
+	rr.Header().Set("Cache-Control", "private")
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/file.txt", nil))
+	if cc := rr.Header().Get("Cache-Control"); cc != "private" {
+		t.Errorf("Cache-Control %q replaced", cc)
+	}
+}
@@ -0,0 +1,188 @@
+//go:build !gzipped_minimal
+
+// Command gzipped-manifest writes a manifest of a directory of static files
+// and their compressed variants, for gzipped.WithManifest and
+// gzipped.LoadManifest to use in place of looking for variants on every
+// request:
+//
+//	gzipped-manifest /srv/static
+//
+// It walks the directory, and records the size, modification time, SHA-256
+// hash and content type of every file and its variants in
+// .gzipped.manifest.json at the top of the directory, or in the file given
+// by -o, "-" meaning standard output.
+//
+// With -check, nothing is written; instead the exit status is 1 if the
+// existing manifest doesn't match the files, as a check in CI. Modification
+// times aren't compared, since checkouts don't preserve them.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lpar/gzipped/v2"
+)
+
+func main() {
+	out := flag.String("o", "", "file to write the manifest to, - for standard output (default DIR/.gzipped.manifest.json)")
+	check := flag.Bool("check", false, "check the existing manifest matches the files, rather than writing one")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: gzipped-manifest [flags] DIR\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := flag.Arg(0)
+	if *out == "" {
+		*out = filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(gzipped.DefaultManifestName, "/")))
+	}
+
+	m, err := generate(dir, skipName(dir, *out))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *check {
+		ok, err := matches(*out, m)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !ok {
+			fmt.Fprintf(os.Stderr, "gzipped-manifest: %s is out of date\n", *out)
+			os.Exit(1)
+		}
+		return
+	}
+	body, err := encode(m)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "-" {
+		os.Stdout.Write(body)
+		return
+	}
+	if err := writeFile(*out, body); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// skipName returns the slash-separated name within dir of the manifest file
+// out, so that it's left out of the manifest, or "" if it's elsewhere.
+func skipName(dir, out string) string {
+	if out == "-" {
+		return ""
+	}
+	absDir, err1 := filepath.Abs(dir)
+	absOut, err2 := filepath.Abs(out)
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(absDir, absOut)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// generate returns the manifest of dir, leaving out the file skip.
+func generate(dir, skip string) (gzipped.Manifest, error) {
+	idx, err := gzipped.BuildIndex(gzipped.Dir(dir))
+	if err != nil {
+		return gzipped.Manifest{}, err
+	}
+	m := idx.Manifest()
+	files := m.Files[:0]
+	for _, mf := range m.Files {
+		if skip != "" && mf.Path == skip {
+			continue
+		}
+		variants := mf.Variants[:0]
+		for _, v := range mf.Variants {
+			if v.Name != skip {
+				variants = append(variants, v)
+			}
+		}
+		if len(variants) == 0 {
+			continue
+		}
+		mf.Variants = variants
+		files = append(files, mf)
+	}
+	m.Files = files
+	return m, nil
+}
+
+func encode(m gzipped.Manifest) ([]byte, error) {
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
+
+// matches reports whether the manifest in the file name describes the same
+// files as m in all but modification times.
+func matches(name string, m gzipped.Manifest) (bool, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return false, err
+	}
+	var old gzipped.Manifest
+	if err := json.Unmarshal(data, &old); err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+	a, err := encode(withoutModTimes(old))
+	if err != nil {
+		return false, err
+	}
+	b, err := encode(withoutModTimes(m))
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(a, b), nil
+}
+
+func withoutModTimes(m gzipped.Manifest) gzipped.Manifest {
+	files := make([]gzipped.ManifestFile, len(m.Files))
+	for i, mf := range m.Files {
+		mf.Variants = append([]gzipped.ManifestVariant(nil), mf.Variants...)
+		for j := range mf.Variants {
+			mf.Variants[j].ModTime = time.Time{}
+		}
+		files[i] = mf
+	}
+	return gzipped.Manifest{Files: files}
+}
+
+// writeFile replaces the file name with data, by way of a temporary file so
+// that a running server never sees half a manifest.
+func writeFile(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".gzipped-manifest-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
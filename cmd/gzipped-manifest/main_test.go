@@ -0,0 +1,84 @@
+//go:build !gzipped_minimal
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lpar/gzipped/v2"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"app.js":                 "console.log(1)",
+		"app.js.gz":              "not really gzip",
+		".gzipped.manifest.json": "{}",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(dir, ".gzipped.manifest.json")
+	m, err := generate(dir, skipName(dir, out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each variant is also listed as a file in its own right, as in an Index.
+	if len(m.Files) != 2 || m.Files[0].Path != "/app.js" || len(m.Files[0].Variants) != 2 || m.Files[1].Path != "/app.js.gz" {
+		t.Fatalf("manifest is %+v", m)
+	}
+	if ct := m.Files[0].ContentType; ct != "text/javascript; charset=utf-8" {
+		t.Errorf("content type %q", ct)
+	}
+	body, err := encode(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(out, body); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := gzipped.LoadManifest(gzipped.Dir(dir), gzipped.DefaultManifestName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := idx.Variants("/app.js"); len(v) != 2 {
+		t.Errorf("loaded manifest has variants %+v", v)
+	}
+
+	// Touching a file doesn't make the manifest stale; changing it does.
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(dir, "app.js"), later, later)
+	if m, err = generate(dir, skipName(dir, out)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := matches(out, m); !ok || err != nil {
+		t.Errorf("touched file made the manifest stale: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(2)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = generate(dir, skipName(dir, out)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := matches(out, m); ok {
+		t.Error("changed file didn't make the manifest stale")
+	}
+}
+
+func TestSkipName(t *testing.T) {
+	for _, tc := range []struct{ dir, out, expect string }{
+		{"static", "static/.gzipped.manifest.json", "/.gzipped.manifest.json"},
+		{"static", "static/meta/m.json", "/meta/m.json"},
+		{"static", "m.json", ""},
+		{"static", "-", ""},
+	} {
+		if got := skipName(tc.dir, filepath.FromSlash(tc.out)); got != tc.expect {
+			t.Errorf("skipName(%q, %q) = %q, expected %q", tc.dir, tc.out, got, tc.expect)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+//go:build !gzipped_minimal
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// config is what to serve, and how. It's read from the file given by
+// -config, if there is one, and flags override it.
+type config struct {
+	Addr string `json:"addr"`
+	// Roots are the directories to serve, layered as by gzipped.Multi, so
+	// that files in earlier ones hide those in later ones.
+	Roots      []string     `json:"roots"`
+	Index      string       `json:"index"`
+	SPA        string       `json:"spa"`
+	Listing    bool         `json:"listing"`
+	Cache      []cacheRule  `json:"cache"`
+	Headers    []headerRule `json:"headers"`
+	Redirects  []redirect   `json:"redirects"`
+	Encodings  []string     `json:"encodings"` // most preferred first
+	Cert       string       `json:"cert"`
+	Key        string       `json:"key"`
+	SelfSigned bool         `json:"selfSigned"`
+	Log        bool         `json:"log"`
+}
+
+// cacheRule sets Cache-Control to Value for files matching Path, in the
+// syntax of gzipped.WithCacheControl.
+type cacheRule struct {
+	Path  string `json:"path"`
+	Value string `json:"value"`
+}
+
+// headerRule sets headers on responses for files matching Path.
+type headerRule struct {
+	Path string            `json:"path"`
+	Set  map[string]string `json:"set"`
+}
+
+// redirect sends requests for the path From to To, with Status, 301 Moved
+// Permanently by default.
+type redirect struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status"`
+}
+
+func defaultConfig() config {
+	return config{Addr: ":8080", Roots: []string{"."}, Index: "index.html"}
+}
+
+// load reads the config file name over cfg, so that what it doesn't mention
+// keeps its value.
+func (cfg *config) load(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	dec := json.NewDecoder(file)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// check reports what's wrong with cfg, if anything.
+func (cfg *config) check() error {
+	if len(cfg.Roots) == 0 {
+		return errors.New("no roots to serve")
+	}
+	for _, root := range cfg.Roots {
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			return fmt.Errorf("%s isn't a directory", root)
+		}
+	}
+	if (cfg.Cert == "") != (cfg.Key == "") {
+		return errors.New("cert and key must be given together")
+	}
+	if cfg.SelfSigned && cfg.Cert != "" {
+		return errors.New("a self-signed certificate can't be used with cert and key")
+	}
+	for _, rd := range cfg.Redirects {
+		if !strings.HasPrefix(rd.From, "/") || rd.To == "" {
+			return fmt.Errorf("redirect from %q to %q: need a path to redirect from, and somewhere to redirect to", rd.From, rd.To)
+		}
+		if rd.Status != 0 && (rd.Status < 300 || rd.Status > 399) {
+			return fmt.Errorf("redirect from %s: status %d isn't a redirect", rd.From, rd.Status)
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma-separated flag value, trimming spaces and
+// dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseCacheRule parses the value of a -cache flag, [PATTERN=]VALUE.
+func parseCacheRule(s string) cacheRule {
+	// Values like "max-age=60" have an = too, but no * or /.
+	if p, v, ok := strings.Cut(s, "="); ok && strings.ContainsAny(p, "*/") {
+		return cacheRule{Path: p, Value: v}
+	}
+	return cacheRule{Path: "*", Value: s}
+}
+
+// redirects sends requests matching cfg's redirects on their way, and
+// passes the rest to next.
+func redirects(rds []redirect, next http.Handler) http.Handler {
+	if len(rds) == 0 {
+		return next
+	}
+	byPath := make(map[string]redirect, len(rds))
+	for _, rd := range rds {
+		if rd.Status == 0 {
+			rd.Status = http.StatusMovedPermanently
+		}
+		byPath[rd.From] = rd
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rd, ok := byPath[r.URL.Path]; ok {
+			http.Redirect(w, r, rd.To, rd.Status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,281 @@
+//go:build !gzipped_minimal
+
+// Command gzipped-serve serves a directory of static files over HTTP with
+// gzipped.FileServer, sending precompressed .br, .zst and .gz variants to
+// clients which accept them. It's a drop-in replacement for
+// python -m http.server which knows about compression:
+//
+//	gzipped-serve -root ./public -addr :8080
+//
+// Directory requests get index.html, or whatever -index names; -index ""
+// turns that off. -listing lists directories with no index file. -spa
+// serves the given file, typically /index.html, for extensionless paths which
+// don't match a file, for single-page applications.
+//
+// -cache sets Cache-Control, either for everything or, as PATTERN=VALUE, for
+// files matching a pattern in the syntax of gzipped.WithCacheControl, which
+// must contain a * or /. It can be given more than once, and the first match
+// wins:
+//
+//	gzipped-serve -cache '/assets/*=public, max-age=31536000, immutable' -cache 'no-cache'
+//
+// With -cert and -key, it serves HTTPS instead, using HTTP/2 for clients
+// which support it, since browsers only speak HTTP/2 over TLS. For local
+// development, -self-signed generates a certificate for localhost when the
+// server starts, which browsers will warn about but can be told to accept:
+//
+//	gzipped-serve -self-signed -addr localhost:8443
+//
+// -log writes a line to standard error for each file request, in the
+// combined log format followed by the encoding sent and the time taken, as
+// gzipped.WithAccessLog writes them; the config file's redirects aren't
+// logged.
+//
+// -encodings sets the server's order of preference between encodings, most
+// preferred first, as a comma-separated list such as gzip,br.
+//
+// For a production host, -config reads the same settings, and more, from a
+// JSON file; flags given as well override it:
+//
+//	{
+//		"addr": ":443",
+//		"roots": ["/srv/overrides", "/srv/static"],
+//		"cache": [
+//			{"path": "/assets/*", "value": "public, max-age=31536000, immutable"},
+//			{"path": "*", "value": "no-cache"}
+//		],
+//		"headers": [
+//			{"path": "*", "set": {"X-Content-Type-Options": "nosniff"}},
+//			{"path": "/fonts/*", "set": {"Access-Control-Allow-Origin": "*"}}
+//		],
+//		"redirects": [{"from": "/old.html", "to": "/new.html", "status": 301}],
+//		"encodings": ["br", "zstd", "gzip"],
+//		"cert": "/etc/tls/cert.pem",
+//		"key": "/etc/tls/key.pem",
+//		"log": true
+//	}
+//
+// Roots are layered, so files in earlier ones hide the same files in later
+// ones. The other settings are named after the flags, with selfSigned for
+// -self-signed. The format is JSON rather than YAML or TOML because the
+// command is part of the package's module, and a parser for either would
+// become a dependency of every program using the package.
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lpar/gzipped/v2"
+)
+
+// flagValue is a flag which can be given more than once, each value being
+// passed to add.
+type flagValue func(string)
+
+func (flagValue) String() string { return "" }
+
+func (add flagValue) Set(s string) error {
+	add(s)
+	return nil
+}
+
+func main() {
+	configFile := flag.String("config", "", "JSON `file` to read the configuration from")
+	root := flag.String("root", ".", "directory to serve")
+	addr := flag.String("addr", ":8080", "address to listen on")
+	index := flag.String("index", "index.html", "file to serve for directory requests, empty for none")
+	spa := flag.String("spa", "", "file to serve for unmatched extensionless paths, such as /index.html")
+	listing := flag.Bool("listing", false, "list directories with no index file")
+	var cache []cacheRule
+	flag.Var(flagValue(func(s string) { cache = append(cache, parseCacheRule(s)) }), "cache", "Cache-Control `[PATTERN=]VALUE`, may be repeated")
+	encodings := flag.String("encodings", "", "comma-separated encodings, most preferred first, such as gzip,br")
+	certFile := flag.String("cert", "", "TLS certificate file, to serve HTTPS")
+	keyFile := flag.String("key", "", "TLS private key file")
+	selfSigned := flag.Bool("self-signed", false, "serve HTTPS with a generated certificate for localhost")
+	logRequests := flag.Bool("log", false, "log each request to standard error")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: gzipped-serve [flags]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cfg := defaultConfig()
+	if *configFile != "" {
+		if err := cfg.load(*configFile); err != nil {
+			log.Fatal("gzipped-serve: ", err)
+		}
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "root":
+			cfg.Roots = []string{*root}
+		case "addr":
+			cfg.Addr = *addr
+		case "index":
+			cfg.Index = *index
+		case "spa":
+			cfg.SPA = *spa
+		case "listing":
+			cfg.Listing = *listing
+		case "cache":
+			// Flags come first, so they win.
+			cfg.Cache = append(cache, cfg.Cache...)
+		case "encodings":
+			cfg.Encodings = splitList(*encodings)
+		case "cert":
+			cfg.Cert = *certFile
+		case "key":
+			cfg.Key = *keyFile
+		case "self-signed":
+			cfg.SelfSigned = *selfSigned
+		case "log":
+			cfg.Log = *logRequests
+		}
+	})
+	if err := cfg.check(); err != nil {
+		log.Fatal("gzipped-serve: ", err)
+	}
+
+	var accessLog io.Writer
+	if cfg.Log {
+		accessLog = os.Stderr
+	}
+	h := newHandler(cfg, accessLog)
+	srv := &http.Server{Addr: cfg.Addr, Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	scheme := "http"
+	if cfg.Cert != "" || cfg.SelfSigned {
+		scheme = "https"
+	}
+	if cfg.SelfSigned {
+		cert, err := selfSignedCert(time.Now())
+		if err != nil {
+			log.Fatal("gzipped-serve: ", err)
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	log.Printf("gzipped-serve: serving %s on %s://%s", strings.Join(cfg.Roots, ", "), scheme, displayAddr(cfg.Addr))
+	var err error
+	if scheme == "https" {
+		// net/http negotiates HTTP/2 by ALPN by itself.
+		err = srv.ListenAndServeTLS(cfg.Cert, cfg.Key)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal("gzipped-serve: ", err)
+	}
+}
+
+// newHandler returns the file server cfg describes, logging requests to
+// accessLog if it's not nil.
+func newHandler(cfg config, accessLog io.Writer) http.Handler {
+	opts := []gzipped.Option{gzipped.WithETags(), gzipped.WithCanonicalRedirects()}
+	if accessLog != nil {
+		opts = append(opts, gzipped.WithAccessLog(accessLog, gzipped.CombinedLog))
+	}
+	if cfg.Index != "" {
+		opts = append(opts, gzipped.WithIndexFile(cfg.Index))
+	}
+	if cfg.SPA != "" {
+		opts = append(opts, gzipped.WithSPAFallback(cfg.SPA))
+	}
+	if cfg.Listing {
+		opts = append(opts, gzipped.WithDirectoryListing(nil))
+	}
+	for _, c := range cfg.Cache {
+		opts = append(opts, gzipped.WithCacheControl(c.Path, c.Value))
+	}
+	if len(cfg.Headers) > 0 {
+		rules := cfg.Headers
+		opts = append(opts, gzipped.WithHeaders(func(fpath string, h http.Header) {
+			for _, rule := range rules {
+				if gzipped.MatchPattern(rule.Path, fpath) {
+					for k, v := range rule.Set {
+						h.Set(k, v)
+					}
+				}
+			}
+		}))
+	}
+	if len(cfg.Encodings) > 0 {
+		opts = append(opts, gzipped.WithEncodingPreference(cfg.Encodings...))
+	}
+	roots := make([]gzipped.FileSystem, len(cfg.Roots))
+	for i, root := range cfg.Roots {
+		roots[i] = gzipped.Dir(root)
+	}
+	root := roots[0]
+	if len(roots) > 1 {
+		root = gzipped.Multi(roots...)
+	}
+	return redirects(cfg.Redirects, gzipped.FileServer(root, opts...))
+}
+
+// selfSignedCert generates a certificate for localhost, valid for a year
+// from now, for local development.
+func selfSignedCert(now time.Time) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"gzipped-serve"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// displayAddr returns addr with a host, so that it can be clicked on.
+func displayAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
@@ -0,0 +1,210 @@
+//go:build !gzipped_minimal
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServe(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"index.html":       "<p>home</p>",
+		"index.html.gz":    "not really gzip",
+		"assets/app.js":    "console.log('hello')",
+		"assets/app.js.br": "not really brotli",
+		"docs/readme.txt":  "read me",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var logged bytes.Buffer
+	h := newHandler(config{
+		Roots:   []string{dir},
+		Index:   "index.html",
+		SPA:     "/index.html",
+		Listing: true,
+		Cache:   []cacheRule{parseCacheRule("/assets/*=public, max-age=31536000, immutable"), parseCacheRule("max-age=60")},
+	}, &logged)
+	for _, tc := range []struct {
+		path, ae                string
+		status                  int
+		encoding, cache, substr string
+	}{
+		{"/", "gzip", http.StatusOK, "gzip", "max-age=60", "not really gzip"},
+		{"/", "", http.StatusOK, "", "max-age=60", "<p>home</p>"},
+		{"/assets/app.js", "br", http.StatusOK, "br", "public, max-age=31536000, immutable", "not really brotli"},
+		{"/users/42", "", http.StatusOK, "", "max-age=60", "<p>home</p>"},
+		{"/docs/", "", http.StatusOK, "", "", "readme.txt"},
+		{"/missing.js", "", http.StatusNotFound, "", "", ""},
+		{"/index.html", "", http.StatusMovedPermanently, "", "", ""},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status || rec.Header().Get("Content-Encoding") != tc.encoding ||
+			rec.Header().Get("Cache-Control") != tc.cache || !strings.Contains(rec.Body.String(), tc.substr) {
+			t.Errorf("GET %s (%q): %d, Content-Encoding %q, Cache-Control %q, %q", tc.path, tc.ae, rec.Code,
+				rec.Header().Get("Content-Encoding"), rec.Header().Get("Cache-Control"), rec.Body.String())
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(logged.String()), "\n")
+	if len(lines) != 7 || !strings.Contains(lines[2], `"GET /assets/app.js HTTP/1.1" 200 17 "" "" br `) {
+		t.Errorf("logged %q", lines)
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := strings.Join(splitList("br, gzip,,zstd ,"), "|")
+	if got != "br|gzip|zstd" {
+		t.Errorf("split into %q", got)
+	}
+}
+
+func TestSelfSignedHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"app.js": "console.log('hello')", "app.js.gz": "not really gzip"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cert, err := selfSignedCert(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.VerifyHostname("localhost"); err != nil {
+		t.Error(err)
+	}
+	if err := leaf.VerifyHostname("127.0.0.1"); err != nil {
+		t.Error(err)
+	}
+
+	srv := httptest.NewUnstartedServer(newHandler(config{Roots: []string{dir}}, nil))
+	srv.EnableHTTP2 = true
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	defer srv.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool},
+		ForceAttemptHTTP2: true,
+		// Ask for gzip explicitly, so the transport leaves the body alone.
+		DisableCompression: true,
+	}}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/app.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 || resp.Header.Get("Content-Encoding") != "gzip" || resp.Header.Get("Vary") != "Accept-Encoding" {
+		t.Errorf("%s %s, Content-Encoding %q, Vary %q", resp.Proto, resp.Status, resp.Header.Get("Content-Encoding"), resp.Header.Get("Vary"))
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"overrides/app.css":    "overridden",
+		"static/app.css":       "p { color: red }",
+		"static/app.css.gz":    "not really gzip",
+		"static/app.css.br":    "not really brotli",
+		"static/new.html":      "<p>new</p>",
+		"static/fonts/a.woff2": "font",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configFile := filepath.Join(dir, "config.json")
+	body := `{
+		"roots": ["` + filepath.ToSlash(filepath.Join(dir, "overrides")) + `", "` + filepath.ToSlash(filepath.Join(dir, "static")) + `"],
+		"cache": [{"path": "*.css", "value": "max-age=60"}],
+		"headers": [
+			{"path": "*", "set": {"X-Content-Type-Options": "nosniff"}},
+			{"path": "/fonts/*", "set": {"Access-Control-Allow-Origin": "*"}}
+		],
+		"redirects": [{"from": "/old.html", "to": "/new.html"}],
+		"encodings": ["gzip", "br"],
+		"log": true
+	}`
+	if err := os.WriteFile(configFile, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := defaultConfig()
+	if err := cfg.load(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.check(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != ":8080" || cfg.Index != "index.html" || !cfg.Log {
+		t.Errorf("defaults not kept: %+v", cfg)
+	}
+	h := newHandler(cfg, nil)
+	get := func(path, ae string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", ae)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	// The first root's app.css hides the second's and its variants.
+	if rec := get("/app.css", "br, gzip"); rec.Body.String() != "overridden" || rec.Header().Get("Cache-Control") != "max-age=60" ||
+		rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("app.css: %q, %v", rec.Body.String(), rec.Header())
+	}
+	if rec := get("/fonts/a.woff2", ""); rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Cache-Control") != "" {
+		t.Errorf("font: %v", rec.Header())
+	}
+	if rec := get("/old.html", ""); rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/new.html" {
+		t.Errorf("redirect: %d, %v", rec.Code, rec.Header())
+	}
+
+	// Encoding preferences apply when the client has none.
+	cfg.Roots = cfg.Roots[1:]
+	h = newHandler(cfg, nil)
+	if rec := get("/app.css", "br, gzip"); rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Content-Encoding %q, expected the preferred gzip", rec.Header().Get("Content-Encoding"))
+	}
+
+	for _, bad := range []string{
+		`{"root": "."}`,
+		`{"roots": []}`,
+		`{"cert": "cert.pem"}`,
+		`{"redirects": [{"from": "/a", "to": "/b", "status": 200}]}`,
+	} {
+		if err := os.WriteFile(configFile, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg := defaultConfig()
+		if err := cfg.load(configFile); err == nil && cfg.check() == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// Command parity compares the responses of gzipped.FileServer with those of a
+// reference server, typically nginx configured with gzip_static, to help when
+// migrating from one to the other.
+//
+// It serves the directory given by -root with gzipped.FileServer on a local
+// port, replays a set of requests against it and against the reference server
+// given by -ref, and reports any differences in status, selected headers and
+// body. The reference server should serve the same directory, for example:
+//
+//	location / {
+//	    root /srv/static;
+//	    gzip_static on;
+//	    brotli_static on;
+//	}
+//
+// Requests are read from the file given by -requests, in a format like the
+// start of an HTTP request: a request line with method and path, then header
+// lines, with blank lines between requests:
+//
+//	GET /index.html
+//	Accept-Encoding: gzip
+//
+//	GET /app.js
+//	Accept-Encoding: br, gzip
+//	Range: bytes=0-99
+//
+// Without -requests, a request is generated for every file under -root with
+// each of a set of common Accept-Encoding values.
+//
+// The exit status is 1 if any differences were found.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lpar/gzipped/v2"
+)
+
+// request is a request to replay against both servers.
+type request struct {
+	method string
+	path   string
+	header http.Header
+}
+
+func (r request) String() string {
+	var b strings.Builder
+	b.WriteString(r.method)
+	b.WriteByte(' ')
+	b.WriteString(r.path)
+	keys := make([]string, 0, len(r.header))
+	for k := range r.header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " [%s: %s]", k, strings.Join(r.header[k], ", "))
+	}
+	return b.String()
+}
+
+// Headers compared by default. Date, Server, ETag and the like are expected to
+// differ between implementations.
+const defaultHeaders = "Content-Type,Content-Encoding,Content-Length,Content-Range,Vary,Last-Modified,Accept-Ranges"
+
+// Accept-Encoding values used when generating requests.
+var generatedEncodings = []string{"", "gzip", "br", "br, gzip", "zstd, gzip", "gzip;q=0", "identity;q=0, gzip"}
+
+func main() {
+	root := flag.String("root", ".", "directory to serve with gzipped.FileServer")
+	ref := flag.String("ref", "", "base URL of the reference server, e.g. http://localhost:8080")
+	reqFile := flag.String("requests", "", "file of requests to replay; generated from -root if empty")
+	headers := flag.String("headers", defaultHeaders, "comma-separated response headers to compare")
+	bodies := flag.Bool("bodies", true, "compare response bodies")
+	flag.Parse()
+	if *ref == "" {
+		log.Fatal("parity: -ref is required")
+	}
+
+	var reqs []request
+	var err error
+	if *reqFile != "" {
+		var f *os.File
+		f, err = os.Open(*reqFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		reqs, err = parseRequests(f)
+		f.Close()
+	} else {
+		reqs, err = generateRequests(*root)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	srv := httptest.NewServer(gzipped.FileServer(gzipped.Dir(*root)))
+	defer srv.Close()
+
+	c := comparer{
+		client:  &http.Client{Transport: &http.Transport{DisableCompression: true}},
+		headers: splitList(*headers),
+		bodies:  *bodies,
+	}
+	ndiff := 0
+	for _, req := range reqs {
+		diffs, err := c.compare(req, srv.URL, strings.TrimRight(*ref, "/"))
+		if err != nil {
+			log.Fatalf("%v: %v", req, err)
+		}
+		if len(diffs) > 0 {
+			ndiff++
+			fmt.Println(req)
+			for _, d := range diffs {
+				fmt.Println("\t" + d)
+			}
+		}
+	}
+	fmt.Printf("%d requests, %d with differences\n", len(reqs), ndiff)
+	if ndiff > 0 {
+		os.Exit(1)
+	}
+}
+
+// parseRequests reads requests in the format described in the package
+// documentation.
+func parseRequests(r io.Reader) ([]request, error) {
+	var reqs []request
+	var cur *request
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		switch {
+		case text == "":
+			cur = nil
+		case strings.HasPrefix(text, "#"):
+		case cur == nil:
+			fields := strings.Fields(text)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: expected method and path, got %q", line, text)
+			}
+			reqs = append(reqs, request{method: fields[0], path: fields[1], header: http.Header{}})
+			cur = &reqs[len(reqs)-1]
+		default:
+			name, value, ok := strings.Cut(text, ":")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected header, got %q", line, text)
+			}
+			cur.header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	return reqs, sc.Err()
+}
+
+// generateRequests makes GET requests for each file under root, with each of
+// generatedEncodings. Files which look like compressed variants are requested
+// by the name of the file they're a variant of.
+func generateRequests(root string) ([]request, error) {
+	seen := map[string]bool{}
+	var paths []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		name := "/" + filepath.ToSlash(rel)
+		for _, ext := range []string{".gz", ".br", ".zst"} {
+			name = strings.TrimSuffix(name, ext)
+		}
+		if !seen[name] {
+			seen[name] = true
+			paths = append(paths, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var reqs []request
+	for _, p := range paths {
+		for _, ae := range generatedEncodings {
+			h := http.Header{}
+			if ae != "" {
+				h.Set("Accept-Encoding", ae)
+			}
+			reqs = append(reqs, request{method: http.MethodGet, path: p, header: h})
+		}
+	}
+	return reqs, nil
+}
+
+// comparer replays requests and compares the responses.
+type comparer struct {
+	client  *http.Client
+	headers []string
+	bodies  bool
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (c *comparer) fetch(req request, base string) (response, error) {
+	hr, err := http.NewRequest(req.method, base+req.path, nil)
+	if err != nil {
+		return response{}, err
+	}
+	for k, v := range req.header {
+		hr.Header[k] = v
+	}
+	resp, err := c.client.Do(hr)
+	if err != nil {
+		return response{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return response{}, err
+	}
+	return response{status: resp.StatusCode, header: resp.Header, body: body}, nil
+}
+
+// compare makes req against both servers, returning a description of each
+// difference between the responses.
+func (c *comparer) compare(req request, got, want string) ([]string, error) {
+	g, err := c.fetch(req, got)
+	if err != nil {
+		return nil, err
+	}
+	w, err := c.fetch(req, want)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []string
+	if g.status != w.status {
+		diffs = append(diffs, fmt.Sprintf("status: gzipped %d, reference %d", g.status, w.status))
+	}
+	for _, h := range c.headers {
+		gv, wv := normalizeHeader(h, g.header.Values(h)), normalizeHeader(h, w.header.Values(h))
+		if gv != wv {
+			diffs = append(diffs, fmt.Sprintf("%s: gzipped %q, reference %q", h, gv, wv))
+		}
+	}
+	// Error pages are expected to differ, so only compare successful bodies.
+	if c.bodies && g.status == w.status && g.status < 300 && !bytes.Equal(g.body, w.body) {
+		diffs = append(diffs, fmt.Sprintf("body: gzipped %d bytes, reference %d bytes", len(g.body), len(w.body)))
+	}
+	return diffs, nil
+}
+
+// normalizeHeader joins the values of a header, ignoring differences which
+// don't change its meaning, such as case and spacing in lists.
+func normalizeHeader(name string, values []string) string {
+	switch http.CanonicalHeaderKey(name) {
+	case "Vary", "Content-Encoding":
+		var items []string
+		for _, v := range values {
+			for _, item := range splitList(v) {
+				items = append(items, strings.ToLower(item))
+			}
+		}
+		sort.Strings(items)
+		return strings.Join(items, ", ")
+	case "Content-Type":
+		return strings.ToLower(strings.ReplaceAll(strings.Join(values, ", "), " ", ""))
+	}
+	return strings.Join(values, ", ")
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
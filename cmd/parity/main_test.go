@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseRequests(t *testing.T) {
+	reqs, err := parseRequests(strings.NewReader(`
+# comment
+GET /index.html
+Accept-Encoding: gzip
+
+HEAD /app.js
+Accept-Encoding: br, gzip
+Range: bytes=0-99
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 2 {
+		t.Fatalf("got %d requests, expected 2", len(reqs))
+	}
+	if got := reqs[0].String(); got != "GET /index.html [Accept-Encoding: gzip]" {
+		t.Errorf("first request is %s", got)
+	}
+	if got := reqs[1].String(); got != "HEAD /app.js [Accept-Encoding: br, gzip] [Range: bytes=0-99]" {
+		t.Errorf("second request is %s", got)
+	}
+
+	for _, bad := range []string{"GET", "GET /a\nnot a header"} {
+		if _, err := parseRequests(strings.NewReader(bad)); err == nil {
+			t.Errorf("parsing %q succeeded", bad)
+		}
+	}
+}
+
+func TestGenerateRequests(t *testing.T) {
+	reqs, err := generateRequests("../../testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, r := range reqs {
+		seen[r.path] = true
+	}
+	for _, p := range []string{"/file.txt", "/file2.txt", "/app.js", "/page"} {
+		if !seen[p] {
+			t.Errorf("no request generated for %s", p)
+		}
+	}
+	if seen["/file.txt.gz"] {
+		t.Errorf("request generated for a compressed variant")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	handler := func(ctype, vary string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ctype)
+			w.Header().Set("Vary", vary)
+			w.Write([]byte("hello"))
+		})
+	}
+	a := httptest.NewServer(handler("text/plain; charset=utf-8", "Accept-Encoding"))
+	defer a.Close()
+	b := httptest.NewServer(handler("text/plain;charset=UTF-8", "accept-encoding"))
+	defer b.Close()
+	c := httptest.NewServer(handler("text/html", "Accept-Encoding"))
+	defer c.Close()
+
+	cmp := comparer{client: http.DefaultClient, headers: splitList(defaultHeaders), bodies: true}
+	req := request{method: http.MethodGet, path: "/", header: http.Header{}}
+	diffs, err := cmp.compare(req, a.URL, b.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("equivalent responses reported as different: %v", diffs)
+	}
+	diffs, err = cmp.compare(req, a.URL, c.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || !strings.HasPrefix(diffs[0], "Content-Type") {
+		t.Errorf("expected a Content-Type difference, got %v", diffs)
+	}
+}
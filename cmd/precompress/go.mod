@@ -0,0 +1,8 @@
+module github.com/lpar/gzipped/v2/cmd/precompress
+
+go 1.20
+
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/klauspost/compress v1.17.9
+)
@@ -0,0 +1,329 @@
+// Command precompress walks a directory tree and writes compressed variants
+// of its compressible files alongside them, ready for gzipped.FileServer:
+// file.js.gz, file.js.br and file.js.zst for file.js.
+//
+//	precompress -formats gz,br,zst -min 256 /srv/static
+//
+// Files smaller than -min bytes, and files whose extension isn't in -ext,
+// are left alone, as are files which don't get any smaller. A variant is
+// only rewritten if it's missing or older than its source, so running the
+// command again after a deploy only compresses what changed; -force
+// rewrites everything. Variants are given the modification time of their
+// source, so they agree with it for Last-Modified.
+//
+// Compression uses the highest level each format has, in -j parallel
+// workers, since it's done once and the files are served many times.
+//
+// For development, -watch keeps the command running after the first pass,
+// looking for changes at the interval given, so that a local server sees
+// fresh variants as files are edited, as it would in production:
+//
+//	precompress -watch 1s ./static
+//
+// Each pass compresses new and changed files, and removes the variants of
+// files which have been deleted.
+//
+// This command is a separate module from the gzipped package, so that the
+// package itself keeps to the standard library.
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// format is a compression format variants can be written in.
+type format struct {
+	ext string
+	// newWriter returns a writer compressing to w at the best level.
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var formats = map[string]format{
+	"gz": {".gz", func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	}},
+	"br": {".br", func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriterLevel(w, brotli.BestCompression), nil
+	}},
+	"zst": {".zst", func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	}},
+}
+
+// Extensions of files worth compressing by default: text formats, and
+// binary ones which aren't already compressed.
+const defaultExts = ".html,.htm,.css,.js,.mjs,.cjs,.json,.map,.xml,.svg,.txt,.md,.csv,.wasm,.ico,.ttf,.otf,.eot,.webmanifest"
+
+// config is what to compress, and how.
+type config struct {
+	formats []format
+	exts    map[string]bool
+	min     int64
+	force   bool
+	verbose bool
+}
+
+// stats counts what was done.
+type stats struct {
+	mu                      sync.Mutex
+	written, skipped, saved int64
+	removed                 int64
+	failed                  int
+}
+
+func main() {
+	formatList := flag.String("formats", "gz,br,zst", "comma-separated formats to write: gz, br, zst")
+	extList := flag.String("ext", defaultExts, "comma-separated extensions of files to compress")
+	min := flag.Int64("min", 256, "smallest file to compress, in bytes")
+	workers := flag.Int("j", runtime.NumCPU(), "number of files to compress in parallel")
+	force := flag.Bool("force", false, "rewrite variants even if they're up to date")
+	verbose := flag.Bool("v", false, "print each variant written")
+	watch := flag.Duration("watch", 0, "keep running, and compress changed files at this interval")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: precompress [flags] dir...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cfg, err := newConfig(*formatList, *extList, *min)
+	if err != nil {
+		log.Fatal("precompress: ", err)
+	}
+	cfg.force, cfg.verbose = *force, *verbose
+
+	var st stats
+	for _, dir := range flag.Args() {
+		if err := run(cfg, dir, *workers, &st); err != nil {
+			log.Fatal("precompress: ", err)
+		}
+	}
+	fmt.Printf("%d variants written, %d up to date, %d bytes saved\n", st.written, st.skipped, st.saved)
+	if *watch > 0 {
+		watchDirs(cfg, flag.Args(), *workers, *watch)
+	}
+	if st.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func newConfig(formatList, extList string, min int64) (*config, error) {
+	cfg := &config{exts: map[string]bool{}, min: min}
+	for _, name := range strings.Split(formatList, ",") {
+		name = strings.TrimSpace(name)
+		fm, ok := formats[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown format %q", name)
+		}
+		cfg.formats = append(cfg.formats, fm)
+	}
+	for _, ext := range strings.Split(extList, ",") {
+		if ext = strings.ToLower(strings.TrimSpace(ext)); ext != "" {
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			cfg.exts[ext] = true
+		}
+	}
+	return cfg, nil
+}
+
+// run compresses the files under dir with the given number of workers.
+func run(cfg *config, dir string, workers int, st *stats) error {
+	if workers < 1 {
+		workers = 1
+	}
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range paths {
+				for _, fm := range cfg.formats {
+					if err := cfg.compress(p, fm, st); err != nil {
+						log.Printf("precompress: %s: %v", p, err)
+						st.mu.Lock()
+						st.failed++
+						st.mu.Unlock()
+					}
+				}
+			}
+		}()
+	}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && cfg.wanted(p) {
+			paths <- p
+		}
+		return nil
+	})
+	close(paths)
+	wg.Wait()
+	return err
+}
+
+// watchDirs compresses the files under dirs which have changed, and removes
+// variants of files which have been deleted, every interval, forever.
+func watchDirs(cfg *config, dirs []string, workers int, interval time.Duration) {
+	for range time.Tick(interval) {
+		var st stats
+		for _, dir := range dirs {
+			if err := run(cfg, dir, workers, &st); err != nil {
+				log.Print("precompress: ", err)
+			}
+			if err := cfg.prune(dir, &st); err != nil {
+				log.Print("precompress: ", err)
+			}
+		}
+		if st.written > 0 || st.removed > 0 {
+			fmt.Printf("%s: %d variants written, %d removed\n", time.Now().Format(time.TimeOnly), st.written, st.removed)
+		}
+	}
+}
+
+// prune removes the variants under dir, in the configured formats, of
+// files which would be compressed but no longer exist.
+func (cfg *config) prune(dir string, st *stats) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Deleted while walking.
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		for _, fm := range cfg.formats {
+			src := strings.TrimSuffix(p, fm.ext)
+			if src == p || !cfg.wanted(src) {
+				continue
+			}
+			if _, err := os.Lstat(src); !os.IsNotExist(err) {
+				continue
+			}
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if cfg.verbose {
+				fmt.Printf("%s: removed\n", p)
+			}
+			st.mu.Lock()
+			st.removed++
+			st.mu.Unlock()
+		}
+		return nil
+	})
+}
+
+// wanted reports whether the file p should be compressed, going by its name.
+func (cfg *config) wanted(p string) bool {
+	if strings.HasPrefix(filepath.Base(p), ".") {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(p))
+	for _, fm := range formats {
+		if ext == fm.ext {
+			// Already a variant.
+			return false
+		}
+	}
+	return cfg.exts[ext]
+}
+
+// compress writes the variant of the file src in format fm, unless it's up
+// to date or wouldn't be smaller.
+func (cfg *config) compress(src string, fm format, st *stats) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.Size() < cfg.min {
+		return nil
+	}
+	dst := src + fm.ext
+	if vinfo, err := os.Stat(dst); err == nil && !cfg.force && !vinfo.ModTime().Before(info.ModTime()) {
+		st.mu.Lock()
+		st.skipped++
+		st.mu.Unlock()
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// Write to a temporary file and rename it into place, so that a server
+	// never sees a partly written variant.
+	tmp, err := os.CreateTemp(filepath.Dir(src), "."+filepath.Base(dst)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	zw, err := fm.newWriter(tmp)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := io.Copy(zw, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if size >= info.Size() {
+		// No saving, so no variant; remove any stale one.
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+	if cfg.verbose {
+		fmt.Printf("%s: %d -> %d bytes\n", dst, info.Size(), size)
+	}
+	st.mu.Lock()
+	st.written++
+	st.saved += info.Size() - size
+	st.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestPrecompress(t *testing.T) {
+	dir := t.TempDir()
+	text := strings.Repeat("body { color: red; }\n", 100)
+	write := func(name, content string) {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("css/site.css", text)
+	write("small.js", "x()")
+	write("photo.jpg", text)
+	write(".hidden.txt", text)
+	write("noise.txt", "\x8f\x12\xa0\x33\x91\xfe\x07\x4c\xd2\x6b")
+
+	cfg, err := newConfig("gz,br,zst", defaultExts, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st stats
+	if err := run(cfg, dir, 2, &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.written != 3 || st.failed != 0 {
+		t.Errorf("wrote %d variants with %d failures, expected 3 and none", st.written, st.failed)
+	}
+	for _, name := range []string{"small.js.gz", "photo.jpg.gz", ".hidden.txt.gz", "noise.txt.gz", "css/site.css.gz.gz"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("wrote %s", name)
+		}
+	}
+
+	src, _ := os.Stat(filepath.Join(dir, "css/site.css"))
+	for ext, newReader := range map[string]func(io.Reader) (io.Reader, error){
+		".gz":  func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		".br":  func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		".zst": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	} {
+		p := filepath.Join(dir, "css/site.css"+ext)
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := newReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", ext, err)
+		}
+		got, err := io.ReadAll(zr)
+		if err != nil || string(got) != text {
+			t.Errorf("%s variant decompressed to %d bytes, %v", ext, len(got), err)
+		}
+		if info, _ := os.Stat(p); !info.ModTime().Equal(src.ModTime()) {
+			t.Errorf("%s variant has modification time %v, expected %v", ext, info.ModTime(), src.ModTime())
+		}
+	}
+
+	// Up to date variants are skipped, and changed sources recompressed.
+	st = stats{}
+	if err := run(cfg, dir, 1, &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.written != 0 || st.skipped != 3 {
+		t.Errorf("second run wrote %d and skipped %d, expected 0 and 3", st.written, st.skipped)
+	}
+	later := src.ModTime().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(dir, "css/site.css"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	st = stats{}
+	if err := run(cfg, dir, 1, &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.written != 3 {
+		t.Errorf("run after change wrote %d variants, expected 3", st.written)
+	}
+
+	if _, err := newConfig("gz,lzma", defaultExts, 0); err == nil {
+		t.Error("accepted an unknown format")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"kept.css", "kept.css.gz", "gone.css.gz", "gone.css.br", "photo.jpg.gz", "gone.css.zst"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, err := newConfig("gz,br", defaultExts, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st stats
+	if err := cfg.prune(dir, &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.removed != 2 {
+		t.Errorf("removed %d variants, expected 2", st.removed)
+	}
+	// Variants of other formats, and of files which wouldn't be compressed,
+	// weren't written by precompress, so are left alone.
+	for name, exists := range map[string]bool{
+		"kept.css.gz": true, "gone.css.gz": false, "gone.css.br": false, "gone.css.zst": true, "photo.jpg.gz": true,
+	} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != exists {
+			t.Errorf("%s: exists %v, expected %v", name, err == nil, exists)
+		}
+	}
+}
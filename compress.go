@@ -0,0 +1,305 @@
+package gzipped
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Encoder compresses what's written to it into the writer it was last Reset
+// with, as *gzip.Writer does. Close finishes the compressed stream without
+// closing the underlying writer.
+type Encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// CompressEncoder is an encoder for a content-coding, which Compress can
+// compress responses with.
+type CompressEncoder struct {
+	Name string // the content-coding, such as "br"
+	// New returns an Encoder writing to w. Encoders are pooled and reused,
+	// so it's only called when there isn't one free.
+	New func(w io.Writer) Encoder
+}
+
+// CompressConfig configures CompressWith.
+type CompressConfig struct {
+	// Level is the gzip compression level, from gzip.BestSpeed to
+	// gzip.BestCompression. The default, 0, means gzip.DefaultCompression,
+	// as does anything out of range.
+	Level int
+	// MinSize is the size in bytes of the smallest response body which is
+	// compressed, since compressing small ones barely helps. The default is
+	// 1024.
+	MinSize int
+	// Types are the media types of the responses to compress, as patterns
+	// like "text/*" and "*+json" as well as exact types. The default is
+	// DefaultCompressibleTypes. Types in DefaultIncompressibleTypes are
+	// never compressed, whatever Types says. Responses without a
+	// Content-Type have theirs sniffed.
+	Types []string
+	// Encoders are content-codings to offer as well as gzip, most preferred
+	// first, such as the brotli encoder from the
+	// github.com/lpar/gzipped/v2/brotli module. Clients which are equally
+	// happy with several are sent the first, with gzip last. An encoder named
+	// "gzip" replaces the built-in one.
+	Encoders []CompressEncoder
+}
+
+// The default CompressConfig.MinSize.
+const defaultCompressMinSize = 1024
+
+// Compress compresses the responses of next on the fly, for dynamic content
+// or files which haven't been precompressed, with gzip for clients which
+// accept it. It's CompressWith with the default configuration.
+func Compress(next http.Handler) http.Handler {
+	return CompressWith(CompressConfig{}, next)
+}
+
+// CompressWith compresses the responses of next on the fly, with the
+// content-coding the client's Accept-Encoding prefers out of gzip and
+// cfg.Encoders, using pooled encoders. Responses are left alone if they're
+// smaller than cfg.MinSize, already have a Content-Encoding, are partial
+// content or have no body, and HEAD requests are passed straight through.
+// Compressed responses have their Content-Length removed and any strong
+// ETag made weak, and are sent with Vary: Accept-Encoding.
+//
+// Responses which FileServer has sent a precompressed variant for already
+// have a Content-Encoding, so CompressWith can wrap a FileServer to cover
+// files which weren't precompressed.
+func CompressWith(cfg CompressConfig, next http.Handler) http.Handler {
+	if cfg.Level < gzip.BestSpeed || cfg.Level > gzip.BestCompression {
+		cfg.Level = gzip.DefaultCompression
+	}
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = defaultCompressMinSize
+	}
+	if cfg.Types == nil {
+		cfg.Types = DefaultCompressibleTypes
+	}
+	c := &compressor{next: next, minSize: cfg.MinSize, types: newTypePatterns(cfg.Types)}
+	var news []func(io.Writer) Encoder
+	haveGzip := false
+	for _, e := range cfg.Encoders {
+		if e.Name == "" || e.Name == identityEncoding || e.New == nil || indexOfEncoding(c.encs, e.Name) >= 0 {
+			continue
+		}
+		haveGzip = haveGzip || e.Name == "gzip"
+		c.encs = append(c.encs, newEncoding(e.Name, ""))
+		news = append(news, e.New)
+	}
+	if !haveGzip {
+		level := cfg.Level
+		c.encs = append(c.encs, newEncoding("gzip", ""))
+		news = append(news, func(w io.Writer) Encoder {
+			zw, _ := gzip.NewWriterLevel(w, level)
+			return zw
+		})
+	}
+	c.encs = append(c.encs, newEncoding(identityEncoding, ""))
+	c.pools = make([]sync.Pool, len(news))
+	for i := range news {
+		newEncoder := news[i]
+		c.pools[i].New = func() interface{} { return newEncoder(nil) }
+	}
+	return c
+}
+
+// compressor is the handler returned by CompressWith.
+type compressor struct {
+	next    http.Handler
+	minSize int
+	types   typePatterns
+	encs    []encoding // offered, most preferred first, identity last
+	pools   []sync.Pool
+}
+
+func (c *compressor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		c.next.ServeHTTP(w, r)
+		return
+	}
+	i := negotiate(r.Header.Get(acceptEncodingHeader), c.encs, encodingSet(1<<uint(len(c.encs))-1))
+	if i >= 0 && c.encs[i].name == identityEncoding {
+		i = -1
+	}
+	cw := &compressWriter{ResponseWriter: w, c: c, enc: i}
+	defer cw.close()
+	c.next.ServeHTTP(cw, r)
+}
+
+// compressWriter buffers the start of a response body until it knows whether
+// the response is worth compressing, then sends it compressed or not.
+type compressWriter struct {
+	http.ResponseWriter
+	c      *compressor
+	enc    int // index in c.encs of the encoding to use, -1 for none
+	status int // 0 until WriteHeader
+	buf    []byte
+	zw     Encoder
+	done   bool // the header has been sent, and the choice made
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.status != 0 || cw.done {
+		return
+	}
+	if code < 200 {
+		// Informational responses go straight through.
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.status = code
+	if !cw.compressible() {
+		cw.start(false, nil)
+		return
+	}
+	addVary(cw.Header(), acceptEncodingHeader)
+	if cw.enc < 0 {
+		cw.start(false, nil)
+	}
+}
+
+// compressible reports whether the response could be worth compressing,
+// going by its status and headers.
+func (cw *compressWriter) compressible() bool {
+	switch cw.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	h := cw.Header()
+	if _, ok := h[contentEncodingHeader]; ok {
+		return false
+	}
+	if _, ok := h["Content-Range"]; ok {
+		return false
+	}
+	if ct := h[contentTypeHeader]; len(ct) > 0 && !cw.c.compressibleType(ct[0]) {
+		return false
+	}
+	if cl := h[contentLengthHeader]; len(cl) > 0 {
+		if n, err := strconv.ParseInt(cl[0], 10, 64); err == nil && n < int64(cw.c.minSize) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.done {
+		if len(cw.buf)+len(p) < cw.c.minSize {
+			if cw.buf == nil {
+				cw.buf = make([]byte, 0, cw.c.minSize)
+			}
+			cw.buf = append(cw.buf, p...)
+			return len(p), nil
+		}
+		if err := cw.start(true, p); err != nil {
+			return 0, err
+		}
+	}
+	if cw.zw != nil {
+		return cw.zw.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// start sends the header, compressed or not, followed by anything buffered.
+// pending is what's about to be written after that, if anything.
+func (cw *compressWriter) start(compress bool, pending []byte) error {
+	cw.done = true
+	h := cw.Header()
+	if compress {
+		if _, ok := h[contentTypeHeader]; !ok {
+			// Once it's compressed, the body can't be sniffed.
+			sniff := cw.buf
+			if n := sniffLen - len(sniff); n > 0 && len(pending) > 0 {
+				if n > len(pending) {
+					n = len(pending)
+				}
+				sniff = append(sniff[:len(sniff):len(sniff)], pending[:n]...)
+			}
+			ctype := http.DetectContentType(sniff)
+			h[contentTypeHeader] = []string{ctype}
+			compress = cw.c.compressibleType(ctype)
+		}
+	}
+	if compress {
+		delete(h, contentLengthHeader)
+		delete(h, "Accept-Ranges")
+		if etag := h["Etag"]; len(etag) > 0 && len(etag[0]) > 0 && etag[0][0] == '"' {
+			h["Etag"] = []string{"W/" + etag[0]}
+		}
+		h[contentEncodingHeader] = cw.c.encs[cw.enc].header
+		cw.zw = cw.c.pools[cw.enc].Get().(Encoder)
+		cw.zw.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.zw != nil {
+		_, err = cw.zw.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close sends whatever hasn't been, and finishes the compressed stream.
+func (cw *compressWriter) close() {
+	if !cw.done {
+		if cw.status == 0 {
+			cw.WriteHeader(http.StatusOK)
+		}
+		if !cw.done {
+			// The whole body is smaller than the minimum.
+			cw.start(false, nil)
+		}
+	}
+	if cw.zw != nil {
+		_ = cw.zw.Close()
+		cw.zw.Reset(nil)
+		cw.c.pools[cw.enc].Put(cw.zw)
+		cw.zw = nil
+	}
+}
+
+// Flush sends what's been written so far, compressing it if the response can
+// be compressed at all, since a handler which flushes is streaming.
+func (cw *compressWriter) Flush() {
+	if !cw.done {
+		if cw.status == 0 {
+			cw.WriteHeader(http.StatusOK)
+		}
+		if !cw.done {
+			_ = cw.start(true, nil)
+		}
+	}
+	if cw.zw != nil {
+		_ = cw.zw.Flush()
+	}
+	if fl, ok := cw.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// compressibleType reports whether responses of type ctype are compressed.
+func (c *compressor) compressibleType(ctype string) bool {
+	return c.types.match(ctype) && !defaultIncompressible.match(ctype)
+}
@@ -0,0 +1,188 @@
+package gzipped
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// reverseEncoder is a stand-in for a third-party encoder, which reverses
+// each write.
+type reverseEncoder struct {
+	w io.Writer
+}
+
+func (e *reverseEncoder) Write(p []byte) (int, error) {
+	r := make([]byte, len(p))
+	for i := range p {
+		r[len(p)-1-i] = p[i]
+	}
+	return e.w.Write(r)
+}
+
+func (e *reverseEncoder) Close() error      { return nil }
+func (e *reverseEncoder) Flush() error      { return nil }
+func (e *reverseEncoder) Reset(w io.Writer) { e.w = w }
+
+func TestCompress(t *testing.T) {
+	long := strings.Repeat("compress me please ", 100)
+	h := CompressWith(CompressConfig{
+		Level:    gzip.BestSpeed,
+		Encoders: []CompressEncoder{{Name: "rev", New: func(w io.Writer) Encoder { return &reverseEncoder{w} }}},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/short":
+			io.WriteString(w, "short")
+		case "/typed":
+			w.Header().Set("Content-Type", "text/css")
+			w.Header().Set("Content-Length", "1900")
+			w.Header().Set("Etag", `"v1"`)
+			io.WriteString(w, long)
+		case "/encoded":
+			w.Header().Set("Content-Encoding", "br")
+			io.WriteString(w, long)
+		case "/partial":
+			w.Header().Set("Content-Range", "bytes 0-1899/5000")
+			w.WriteHeader(http.StatusPartialContent)
+			io.WriteString(w, long)
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			// Written in small pieces, to be buffered.
+			for _, word := range strings.SplitAfter(long, " ") {
+				io.WriteString(w, word)
+			}
+		}
+	}))
+	for _, tc := range []struct {
+		method, path, ae string
+		encoding         string
+		vary             bool
+	}{
+		{"GET", "/", "gzip", "gzip", true},
+		{"GET", "/", "gzip, rev", "rev", true},
+		{"GET", "/", "rev;q=0.5, gzip", "gzip", true},
+		{"GET", "/", "", "", true},
+		{"GET", "/short", "gzip", "", true},
+		{"GET", "/typed", "gzip", "gzip", true},
+		{"GET", "/encoded", "gzip", "br", false},
+		{"GET", "/partial", "gzip", "", false},
+		{"GET", "/empty", "gzip", "", false},
+		{"HEAD", "/", "gzip", "", false},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		res := rr.Result()
+		if ce := res.Header.Get("Content-Encoding"); ce != tc.encoding {
+			t.Errorf("%s %s (%q): Content-Encoding %q, expected %q", tc.method, tc.path, tc.ae, ce, tc.encoding)
+			continue
+		}
+		if vary := res.Header.Get("Vary") == "Accept-Encoding"; vary != tc.vary {
+			t.Errorf("%s %s (%q): Vary %q", tc.method, tc.path, tc.ae, res.Header.Get("Vary"))
+		}
+		if tc.method == "HEAD" || tc.path == "/empty" || tc.path == "/encoded" {
+			continue
+		}
+		var body []byte
+		switch tc.encoding {
+		case "gzip":
+			zr, err := gzip.NewReader(rr.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ = io.ReadAll(zr)
+			if rr.Body.Len() >= len(long) || res.Header.Get("Content-Length") != "" {
+				t.Errorf("%s: compressed to %d bytes, Content-Length %q", tc.path, rr.Body.Len(), res.Header.Get("Content-Length"))
+			}
+		case "rev":
+			if !bytes.Contains(rr.Body.Bytes(), []byte(" esaelp em sserpmoc")) {
+				t.Errorf("%s: not encoded with rev", tc.path)
+			}
+			continue
+		default:
+			body = rr.Body.Bytes()
+		}
+		expect := long
+		if tc.path == "/short" {
+			expect = "short"
+		}
+		if string(body) != expect {
+			t.Errorf("%s %s (%q): body %q", tc.method, tc.path, tc.ae, body)
+		}
+		if tc.path == "/typed" {
+			if ct, etag := res.Header.Get("Content-Type"), res.Header.Get("Etag"); ct != "text/css" || etag != `W/"v1"` {
+				t.Errorf("/typed: Content-Type %q, ETag %q", ct, etag)
+			}
+		} else if tc.encoding == "gzip" {
+			if ct := res.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+				t.Errorf("%s: sniffed Content-Type %q", tc.path, ct)
+			}
+		}
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	h := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, "data: 2\n\n")
+	}))
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(rr, req)
+	if !rr.Flushed || rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("flushed %v, Content-Encoding %q", rr.Flushed, rr.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != "data: 1\n\ndata: 2\n\n" {
+		t.Errorf("body %q", body)
+	}
+}
+
+// Precompressed variants from a FileServer are passed through, and files
+// without them are compressed.
+func TestCompressFileServer(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/big.txt", []byte(strings.Repeat("plain text ", 200)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := Compress(FileServer(Dir("./testdata/")))
+	h2 := Compress(FileServer(Dir(dir)))
+	for _, tc := range []struct {
+		h    http.Handler
+		path string
+	}{
+		{h, "/file.txt"},
+		{h2, "/big.txt"},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		tc.h.ServeHTTP(rr, req)
+		if rr.Header().Get("Content-Encoding") != "gzip" {
+			t.Errorf("%s: Content-Encoding %q", tc.path, rr.Header().Get("Content-Encoding"))
+		}
+		if vary := rr.Header()["Vary"]; len(vary) != 1 {
+			t.Errorf("%s: Vary %q", tc.path, vary)
+		}
+		zr, err := gzip.NewReader(rr.Body)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.path, err)
+		}
+		if _, err := io.ReadAll(zr); err != nil {
+			t.Errorf("%s: %v", tc.path, err)
+		}
+	}
+}
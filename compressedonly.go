@@ -0,0 +1,16 @@
+package gzipped
+
+// WithCompressedOnly is for file trees which hold only compressed variants,
+// such as app.js.br and app.js.gz with no app.js, to save storage. The
+// handler then never looks for the original files: /app.js is served from
+// the best variant the client accepts, or decompressed from a gzip variant
+// for clients which accept none, with the Content-Type of app.js. Indexes
+// and manifests list such files under their logical names already.
+//
+// An original which does exist is ignored, and Validate reports every
+// variant as an orphan.
+func WithCompressedOnly() Option {
+	return func(f *fileHandler) {
+		f.compressedOnly = true
+	}
+}
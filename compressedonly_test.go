@@ -0,0 +1,73 @@
+package gzipped
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// openLog records the names opened and looked for.
+type openLog struct {
+	FileSystem
+	names []string
+}
+
+func (l *openLog) Open(name string) (http.File, error) {
+	l.names = append(l.names, name)
+	return l.FileSystem.Open(name)
+}
+
+func (l *openLog) Exists(name string) bool {
+	l.names = append(l.names, name)
+	return l.FileSystem.Exists(name)
+}
+
+func TestWithCompressedOnly(t *testing.T) {
+	dir := t.TempDir()
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("console.log(1)"))
+	zw.Close()
+	for name, content := range map[string][]byte{
+		"app.js.gz":    gz.Bytes(),
+		"app.js.br":    []byte("not really brotli"),
+		"style.css":    []byte("ignored"),
+		"style.css.br": []byte("not really brotli"),
+	} {
+		if err := os.WriteFile(dir+"/"+name, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := &openLog{FileSystem: Dir(dir)}
+	h := FileServer(root, WithCompressedOnly())
+	for _, tc := range []struct {
+		path, ae, encoding, body string
+		status                   int
+	}{
+		{"/app.js", "br, gzip", "br", "not really brotli", 200},
+		{"/app.js", "gzip", "gzip", gz.String(), 200},
+		{"/app.js", "", "", "console.log(1)", 200},
+		{"/style.css", "", "", "", 406},
+		{"/missing.js", "gzip", "", "", 404},
+	} {
+		root.names = nil
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Header().Get("Content-Encoding") != tc.encoding || (tc.body != "" && rr.Body.String() != tc.body) {
+			t.Errorf("GET %s (%q): %d %q %q", tc.path, tc.ae, rr.Code, rr.Header().Get("Content-Encoding"), rr.Body.String())
+		}
+		if tc.status == 200 && rr.Header().Get("Content-Type") != "text/javascript; charset=utf-8" {
+			t.Errorf("GET %s (%q): Content-Type %q", tc.path, tc.ae, rr.Header().Get("Content-Type"))
+		}
+		for _, name := range root.names {
+			if name == tc.path {
+				t.Errorf("GET %s (%q) looked for the original", tc.path, tc.ae)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package gzipped
+
+import "strings"
+
+// DefaultCompressibleTypes are the media types CompressWith compresses
+// unless CompressConfig.Types says otherwise: text, and the structured and
+// font formats which aren't compressed already.
+var DefaultCompressibleTypes = []string{
+	"text/*",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"application/wasm",
+	"*+json",
+	"*+xml",
+	"font/otf",
+	"font/ttf",
+	"application/vnd.ms-fontobject",
+	"image/bmp",
+	"image/x-icon",
+	"image/vnd.microsoft.icon",
+}
+
+// DefaultIncompressibleTypes are formats which are compressed already, so
+// that compressing them again gains nothing. FileServer ignores compressed
+// variants of files with these types when the original exists, and
+// CompressWith never compresses them.
+var DefaultIncompressibleTypes = []string{
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/webp",
+	"image/avif",
+	"font/woff",
+	"font/woff2",
+	"audio/*",
+	"video/*",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/zstd",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"application/vnd.rar",
+}
+
+var defaultIncompressible = newTypePatterns(DefaultIncompressibleTypes)
+
+// WithIncompressibleTypes replaces the list of types, DefaultIncompressibleTypes
+// unless it's used, whose compressed variants FileServer ignores when the
+// original file exists, since a .gz next to a JPEG or WOFF2 file is almost
+// certainly a mistake, and no smaller. Patterns like "video/*" and "*+zip"
+// match families of types. With no patterns, variants are served whatever
+// the type.
+func WithIncompressibleTypes(patterns ...string) Option {
+	types := newTypePatterns(patterns)
+	return func(f *fileHandler) {
+		f.incompressible = types
+	}
+}
+
+// typePatterns is a set of media types and patterns, which match a whole
+// top-level type, like "text/*", a structured syntax suffix, like "*+json",
+// or anything, "*/*". It's nil if there are none.
+type typePatterns map[string]bool
+
+func newTypePatterns(patterns []string) typePatterns {
+	if len(patterns) == 0 {
+		return nil
+	}
+	p := make(typePatterns, len(patterns))
+	for _, pattern := range patterns {
+		p[strings.ToLower(strings.TrimSpace(pattern))] = true
+	}
+	return p
+}
+
+// match reports whether the media type of the Content-Type value ctype
+// matches any of the patterns.
+func (p typePatterns) match(ctype string) bool {
+	if p == nil || ctype == "" {
+		return false
+	}
+	if i := strings.IndexByte(ctype, ';'); i >= 0 {
+		ctype = ctype[:i]
+	}
+	ctype = strings.ToLower(strings.TrimSpace(ctype))
+	if p[ctype] || p["*/*"] {
+		return true
+	}
+	if i := strings.IndexByte(ctype, '/'); i >= 0 && p[ctype[:i]+"/*"] {
+		return true
+	}
+	if i := strings.LastIndexByte(ctype, '+'); i >= 0 && p["*"+ctype[i:]] {
+		return true
+	}
+	return false
+}
+
+// incompressibleFile reports whether fpath has a type whose compressed
+// variants shouldn't be served.
+func (f *fileHandler) incompressibleFile(fpath string) bool {
+	return f.incompressible.match(f.typeByExtension(f.untranslated(fpath)))
+}
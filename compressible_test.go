@@ -0,0 +1,118 @@
+package gzipped
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTypePatterns(t *testing.T) {
+	p := newTypePatterns([]string{"text/*", "application/JSON", "*+xml"})
+	for ctype, expect := range map[string]bool{
+		"text/html; charset=utf-8": true,
+		"application/json":         true,
+		"Application/Json; q=1":    true,
+		"image/svg+xml":            true,
+		"application/javascript":   false,
+		"image/png":                false,
+		"":                         false,
+		"application/vnd.api+json": false,
+		"application/atom+xml;x=1": true,
+		"textual/plain":            false,
+	} {
+		if got := p.match(ctype); got != expect {
+			t.Errorf("match(%q) = %v, expected %v", ctype, got, expect)
+		}
+	}
+	if newTypePatterns(nil).match("text/plain") {
+		t.Error("empty patterns matched")
+	}
+	if !newTypePatterns([]string{"*/*"}).match("image/png") {
+		t.Error("*/* didn't match")
+	}
+}
+
+// Compressed variants of formats which are compressed already are ignored,
+// unless they're all there is.
+func TestIncompressibleTypes(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"photo.jpg", "photo.jpg.gz", "font.woff2.br", "app.js", "app.js.gz"} {
+		if err := os.WriteFile(dir+"/"+name, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		opts           []Option
+		path, encoding string
+	}{
+		{nil, "/photo.jpg", ""},
+		{nil, "/font.woff2", "br"},
+		{nil, "/app.js", "gzip"},
+		{[]Option{WithIncompressibleTypes()}, "/photo.jpg", "gzip"},
+		{[]Option{WithIncompressibleTypes("text/javascript")}, "/app.js", ""},
+		{[]Option{WithIncompressibleTypes("text/javascript")}, "/photo.jpg", "gzip"},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", "gzip, br")
+		FileServer(Dir(dir), tc.opts...).ServeHTTP(rr, req)
+		if rr.Code != 200 || rr.Header().Get("Content-Encoding") != tc.encoding {
+			t.Errorf("%s with %d options: %d, Content-Encoding %q, expected %q",
+				tc.path, len(tc.opts), rr.Code, rr.Header().Get("Content-Encoding"), tc.encoding)
+		}
+	}
+}
+
+func TestCompressTypes(t *testing.T) {
+	body := strings.Repeat("a fairly compressible body ", 100)
+	handler := func(ctype string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ctype != "" {
+				w.Header().Set("Content-Type", ctype)
+			}
+			io.WriteString(w, body)
+		})
+	}
+	for _, tc := range []struct {
+		types    []string
+		ctype    string
+		compress bool
+	}{
+		{nil, "text/html", true},
+		{nil, "application/ld+json", true},
+		{nil, "image/svg+xml", true},
+		{nil, "", true},
+		{nil, "image/png", false},
+		{nil, "application/octet-stream", false},
+		{[]string{"application/octet-stream"}, "application/octet-stream", true},
+		{[]string{"application/octet-stream"}, "text/html", false},
+		{[]string{"*/*"}, "video/mp4", false},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		CompressWith(CompressConfig{Types: tc.types}, handler(tc.ctype)).ServeHTTP(rr, req)
+		if got := rr.Header().Get("Content-Encoding") == "gzip"; got != tc.compress {
+			t.Errorf("types %v, Content-Type %q: compressed %v, expected %v", tc.types, tc.ctype, got, tc.compress)
+		}
+		if !tc.compress && rr.Body.String() != body {
+			t.Errorf("types %v, Content-Type %q: body altered", tc.types, tc.ctype)
+		}
+	}
+
+	// Sniffing sees the start of the body even when it's written in one go.
+	png := "\x89PNG\r\n\x1a\n" + body
+	h := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, png)
+	}))
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Encoding") != "" || rr.Header().Get("Content-Type") != "image/png" || rr.Body.String() != png {
+		t.Errorf("sniffed PNG: Content-Encoding %q, Content-Type %q", rr.Header().Get("Content-Encoding"), rr.Header().Get("Content-Type"))
+	}
+}
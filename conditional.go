@@ -0,0 +1,100 @@
+package gzipped
+
+import (
+	"net/http"
+	"time"
+)
+
+// Conditional requests are answered by the handler itself, using the
+// validators of the variant being served: its own ETag, and the modification
+// time sent as its Last-Modified. A client's If-Modified-Since came from
+// whichever representation it has, so comparing it with another's time, or
+// an If-None-Match with the wrong ETag, could tell it that what it has is
+// current when it isn't.
+
+// precheckConditional answers a request with 304 Not Modified, if the
+// validators of the representation it would be sent can be worked out from
+// the index or stat cache without opening any file, and they say the
+// client's copy is current. It reports whether it responded.
+func (f *fileHandler) precheckConditional(w http.ResponseWriter, r *http.Request, fpath string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead || hasPreconditions(r) {
+		return false
+	}
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" && r.Header.Get("If-Modified-Since") == "" || inm != "" && !f.etags || f.modTimeSource != ModTimeVariant {
+		return false
+	}
+	var meta *variantMeta
+	if f.index == nil {
+		if f.stats == nil {
+			return false
+		}
+		m, ok := f.stats.get(fpath)
+		if !ok {
+			return false
+		}
+		meta = m
+	}
+	encs := preferredEncodings
+	var names [maxEncodings]string
+	variantNames(fpath, encs, names[:])
+	available := f.availableEncodings(fpath, encs, names[:])
+	order := f.encodingOrderFor(fpath, encs, names[:], available)
+	i := f.negotiate(r, r.Header.Get(acceptEncodingHeader), encs, available, order)
+	if i < 0 || !available.has(i) {
+		return false
+	}
+	var size int64
+	var mtime time.Time
+	if meta != nil {
+		info := meta.info(i)
+		if info == nil {
+			return false
+		}
+		size, mtime = info.Size(), info.ModTime()
+	} else {
+		var ok bool
+		if size, mtime, ok = f.index.stat(fpath, encs[i].name); !ok {
+			return false
+		}
+	}
+	if isZeroTime(mtime) {
+		return false
+	}
+	var etag string
+	if f.etags {
+		etag = makeETag(mtime, size, encs[i].name)
+	}
+	if !notModifiedBy(r, etag, mtime) {
+		return false
+	}
+	h := w.Header()
+	if etag != "" {
+		h["Etag"] = []string{etag}
+	}
+	if available&^(1<<uint(indexOfEncoding(encs, identityEncoding))) != 0 {
+		addVary(h, acceptEncodingHeader)
+	}
+	if f.cacheRules != nil || f.immutable != nil {
+		f.setCacheControl(w, r, fpath)
+	}
+	if f.headers != nil {
+		f.setHeaders(w, r, fpath)
+	}
+	writeNotModified(w)
+	return true
+}
+
+// variantNotModified reports whether r can be answered 304 Not Modified
+// for v, whose ETag, if it has one, has been set on the response.
+func variantNotModified(w http.ResponseWriter, r *http.Request, v variant) bool {
+	return !hasPreconditions(r) && notModified(w, r, v.info.ModTime())
+}
+
+// hasPreconditions reports whether r has If-Match or If-Unmodified-Since,
+// which have to be evaluated before If-None-Match and If-Modified-Since
+// (RFC 9110 section 13.2.2). FileServer leaves those requests to
+// http.ServeContent.
+func hasPreconditions(r *http.Request) bool {
+	return r.Header.Get("If-Match") != "" || r.Header.Get("If-Unmodified-Since") != ""
+}
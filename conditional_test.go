@@ -0,0 +1,70 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVariantConditionals(t *testing.T) {
+	dir := t.TempDir()
+	original := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	compressed := original.Add(time.Hour)
+	for name, mtime := range map[string]time.Time{"page.html": original, "page.html.gz": compressed} {
+		full := filepath.Join(dir, name)
+		if err := os.WriteFile(full, []byte("<p>hello</p>"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(full, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := newCountingFS(Dir(dir))
+	idx, err := BuildIndex(Dir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := func(t time.Time) string { return t.Format(http.TimeFormat) }
+	for _, tc := range []struct {
+		ae     string
+		header []string
+		status int
+	}{
+		// Each representation is compared with its own time.
+		{"", []string{"If-Modified-Since", date(original)}, 304},
+		{"gzip", []string{"If-Modified-Since", date(original)}, 200},
+		{"gzip", []string{"If-Modified-Since", date(compressed)}, 304},
+		// An ETag only matches its own representation.
+		{"gzip", []string{"If-None-Match", makeETag(compressed, int64(len([]byte("<p>hello</p>"))), "gzip")}, 304},
+		{"", []string{"If-None-Match", makeETag(compressed, int64(len([]byte("<p>hello</p>"))), "gzip")}, 200},
+		// Preconditions are evaluated first, by ServeContent.
+		{"", []string{"If-Modified-Since", date(original), "If-Match", `"other"`}, 412},
+	} {
+		for _, opts := range [][]Option{{WithETags()}, {WithETags(), WithIndex(idx)}} {
+			h := FileServer(root, opts...)
+			before := root.count("/page.html") + root.count("/page.html.gz")
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/page.html", nil)
+			req.Header.Set("Accept-Encoding", tc.ae)
+			for i := 0; i < len(tc.header); i += 2 {
+				req.Header.Set(tc.header[i], tc.header[i+1])
+			}
+			h.ServeHTTP(rr, req)
+			if rr.Code != tc.status {
+				t.Errorf("GET with %q and %q, %d options: %d, expected %d", tc.ae, tc.header, len(opts), rr.Code, tc.status)
+			}
+			opened := root.count("/page.html")+root.count("/page.html.gz") > before
+			if len(opts) == 2 && rr.Code == 304 && opened {
+				t.Errorf("GET with %q and %q: opened a file to answer 304 with an index", tc.ae, tc.header)
+			}
+			if rr.Code == 304 && (rr.Header().Get("Vary") != "Accept-Encoding" || rr.Header().Get("Content-Encoding") != "") {
+				t.Errorf("GET with %q and %q: 304 with headers %v", tc.ae, tc.header, rr.Header())
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Counters is a Metrics which counts events, and serves the counts in the
+// Prometheus text exposition format, so that it can be scraped without
+// pulling the Prometheus client library into the program:
+//
+//	counters := gzipped.NewCounters()
+//	http.Handle("/static/", gzipped.FileServer(root, gzipped.WithMetrics(counters)))
+//	http.Handle("/metrics/gzipped", counters)
+//
+// The metrics are gzipped_served_total and gzipped_fallbacks_total, labelled
+// by encoding, gzipped_cache_lookups_total labelled by result, and
+// gzipped_not_found_total and gzipped_shed_total.
+type Counters struct {
+	mu        sync.Mutex
+	served    map[string]uint64
+	fallbacks map[string]uint64
+	hits      uint64
+	misses    uint64
+	notFound  uint64
+	shed      uint64
+}
+
+// NewCounters returns a new set of Counters, all zero.
+func NewCounters() *Counters {
+	return &Counters{served: map[string]uint64{}, fallbacks: map[string]uint64{}}
+}
+
+// Served implements Metrics.
+func (c *Counters) Served(encoding string) {
+	c.mu.Lock()
+	c.served[encoding]++
+	c.mu.Unlock()
+}
+
+// Fallback implements Metrics.
+func (c *Counters) Fallback(encoding string) {
+	c.mu.Lock()
+	c.fallbacks[encoding]++
+	c.mu.Unlock()
+}
+
+// CacheLookup implements Metrics.
+func (c *Counters) CacheLookup(hit bool) {
+	c.mu.Lock()
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	c.mu.Unlock()
+}
+
+// NotFound implements Metrics.
+func (c *Counters) NotFound() {
+	c.mu.Lock()
+	c.notFound++
+	c.mu.Unlock()
+}
+
+// Shed implements ShedMetrics.
+func (c *Counters) Shed() {
+	c.mu.Lock()
+	c.shed++
+	c.mu.Unlock()
+}
+
+// ServedCount returns how many files have been served with encoding.
+func (c *Counters) ServedCount(encoding string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.served[encoding]
+}
+
+// ServeHTTP writes the counts in the Prometheus text exposition format.
+func (c *Counters) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	served := sortedCounts(c.served)
+	fallbacks := sortedCounts(c.fallbacks)
+	hits, misses, notFound, shed := c.hits, c.misses, c.notFound, c.shed
+	c.mu.Unlock()
+
+	w.Header().Set(contentTypeHeader, "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, "# HELP gzipped_served_total Files served, by content-coding.\n# TYPE gzipped_served_total counter\n")
+	for _, kv := range served {
+		fmt.Fprintf(w, "gzipped_served_total{encoding=%q} %d\n", kv.key, kv.n)
+	}
+	fmt.Fprint(w, "# HELP gzipped_fallbacks_total Negotiated variants which couldn't be used, by content-coding.\n# TYPE gzipped_fallbacks_total counter\n")
+	for _, kv := range fallbacks {
+		fmt.Fprintf(w, "gzipped_fallbacks_total{encoding=%q} %d\n", kv.key, kv.n)
+	}
+	fmt.Fprintf(w, "# HELP gzipped_cache_lookups_total Memory cache lookups, by result.\n# TYPE gzipped_cache_lookups_total counter\n"+
+		"gzipped_cache_lookups_total{result=\"hit\"} %d\ngzipped_cache_lookups_total{result=\"miss\"} %d\n", hits, misses)
+	fmt.Fprintf(w, "# HELP gzipped_not_found_total Requests which matched no file.\n# TYPE gzipped_not_found_total counter\ngzipped_not_found_total %d\n", notFound)
+	fmt.Fprintf(w, "# HELP gzipped_shed_total Requests refused by load shedding.\n# TYPE gzipped_shed_total counter\ngzipped_shed_total %d\n", shed)
+}
+
+type count struct {
+	key string
+	n   uint64
+}
+
+func sortedCounts(m map[string]uint64) []count {
+	counts := make([]count, 0, len(m))
+	for k, n := range m {
+		counts = append(counts, count{k, n})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].key < counts[j].key })
+	return counts
+}
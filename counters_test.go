@@ -0,0 +1,48 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounters(t *testing.T) {
+	counters := NewCounters()
+	fs := FileServer(brokenFS{Dir("./testdata/")}, WithMetrics(counters), WithMemoryCache(MemoryCacheConfig{}))
+	for _, tc := range []struct{ path, ae string }{
+		{"/file.txt", ""},
+		{"/file.txt", ""},
+		{"/file.txt", "gzip"},
+		{"/page", ""},
+		{"/nonexistent.txt", "gzip"},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		fs.ServeHTTP(rr, req)
+	}
+	if n := counters.ServedCount(identityEncoding); n != 3 {
+		t.Errorf("%d identity responses counted, expected 3", n)
+	}
+
+	counters.Shed()
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	counters.ServeHTTP(rr, req)
+	for _, line := range []string{
+		`gzipped_served_total{encoding="identity"} 3`,
+		`gzipped_fallbacks_total{encoding="gzip"} 1`,
+		`gzipped_cache_lookups_total{result="hit"} 2`,
+		`gzipped_cache_lookups_total{result="miss"} 5`,
+		`gzipped_not_found_total 1`,
+		`gzipped_shed_total 1`,
+	} {
+		if !strings.Contains(rr.Body.String(), line+"\n") {
+			t.Errorf("metrics missing %q:\n%s", line, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package gzipped
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// LargeFileConfig configures WithLargeFiles.
+type LargeFileConfig struct {
+	// MinSize is the size in bytes of the smallest file which counts as
+	// large. The default is 1 MiB.
+	MinSize int64
+	// MinRate is the slowest transfer rate, in bytes per second, which a
+	// client may have and still count as legitimate. The write deadline
+	// for a large file allows for sending it at this rate. The default is
+	// 16 KiB per second.
+	MinRate int64
+	// Grace is added to the write deadline on top of the time the transfer
+	// should take. The default is ten seconds.
+	Grace time.Duration
+	// FlushHeaders sends the response headers as soon as they're ready,
+	// before any of the body, so that clients can show progress and
+	// intermediaries see the response start promptly.
+	FlushHeaders bool
+}
+
+// WithLargeFiles uses http.ResponseController to adjust how large files are
+// sent. The write deadline is extended to allow for the time the transfer
+// needs at cfg.MinRate, so that a long download isn't cut off by the
+// server's overall WriteTimeout, while clients slower than that still are.
+// Writers which don't support deadlines or flushing are left alone.
+func WithLargeFiles(cfg LargeFileConfig) Option {
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = 1 << 20
+	}
+	if cfg.MinRate <= 0 {
+		cfg.MinRate = 16 << 10
+	}
+	if cfg.Grace <= 0 {
+		cfg.Grace = 10 * time.Second
+	}
+	return func(f *fileHandler) {
+		f.large = &cfg
+	}
+}
+
+// prepareLarge applies the large file settings to a response of the given
+// size, returning the writer to send it with.
+func (f *fileHandler) prepareLarge(w http.ResponseWriter, size int64) http.ResponseWriter {
+	if size < f.large.MinSize {
+		return w
+	}
+	rc := http.NewResponseController(w)
+	transfer := time.Duration(float64(size) / float64(f.large.MinRate) * float64(time.Second))
+	// Errors mean the writer doesn't support deadlines, and there's nothing
+	// else to do about that.
+	_ = rc.SetWriteDeadline(time.Now().Add(transfer + f.large.Grace))
+	if f.large.FlushHeaders {
+		return &headerFlusher{ResponseWriter: w, rc: rc}
+	}
+	return w
+}
+
+// headerFlusher flushes the response headers as soon as they're written.
+type headerFlusher struct {
+	http.ResponseWriter
+	rc          *http.ResponseController
+	wroteHeader bool
+}
+
+func (hf *headerFlusher) WriteHeader(code int) {
+	hf.ResponseWriter.WriteHeader(code)
+	if !hf.wroteHeader && code >= 200 {
+		hf.wroteHeader = true
+		_ = hf.rc.Flush()
+	}
+}
+
+func (hf *headerFlusher) Write(p []byte) (int, error) {
+	if !hf.wroteHeader {
+		hf.WriteHeader(http.StatusOK)
+	}
+	return hf.ResponseWriter.Write(p)
+}
+
+// ReadFrom lets the underlying writer send files efficiently, if it can.
+func (hf *headerFlusher) ReadFrom(src io.Reader) (int64, error) {
+	if !hf.wroteHeader {
+		hf.WriteHeader(http.StatusOK)
+	}
+	if rf, ok := hf.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	return io.Copy(writerOnly{hf.ResponseWriter}, src)
+}
+
+// Flush passes flushes through to the underlying writer.
+func (hf *headerFlusher) Flush() {
+	_ = hf.rc.Flush()
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (hf *headerFlusher) Unwrap() http.ResponseWriter {
+	return hf.ResponseWriter
+}
@@ -0,0 +1,85 @@
+package gzipped
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// slowReadFS returns files which are slow to read.
+type slowReadFS struct {
+	FileSystem
+}
+
+type slowReadFile struct {
+	http.File
+}
+
+func (s slowReadFS) Open(name string) (http.File, error) {
+	f, err := s.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return slowReadFile{f}, nil
+}
+
+func (f slowReadFile) Read(p []byte) (int, error) {
+	time.Sleep(40 * time.Millisecond)
+	if len(p) > 16<<10 {
+		p = p[:16<<10]
+	}
+	return f.File.Read(p)
+}
+
+func TestLargeFileDeadline(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "big.bin"), make([]byte, 64<<10), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		opts   []Option
+		expect int
+	}{
+		{nil, -1},
+		{[]Option{WithLargeFiles(LargeFileConfig{MinSize: 1, MinRate: 1 << 30, Grace: 5 * time.Second})}, 64 << 10},
+	} {
+		srv := httptest.NewUnstartedServer(FileServer(slowReadFS{Dir(dir)}, tc.opts...))
+		srv.Config.WriteTimeout = 50 * time.Millisecond
+		srv.Start()
+		resp, err := http.Get(srv.URL + "/big.bin")
+		n := -1
+		if err == nil {
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err == nil {
+				n = len(body)
+			}
+		}
+		srv.Close()
+		if (tc.expect < 0 && n == 64<<10) || (tc.expect >= 0 && n != tc.expect) {
+			t.Errorf("%d options: received %d bytes", len(tc.opts), n)
+		}
+	}
+}
+
+func TestLargeFileFlushHeaders(t *testing.T) {
+	for _, flush := range []bool{false, true} {
+		h := FileServer(Dir("./testdata/"), WithLargeFiles(LargeFileConfig{MinSize: 10, FlushHeaders: flush}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/file.txt", nil))
+		if rec.Flushed != flush || rec.Code != http.StatusOK || rec.Body.Len() != 27 {
+			t.Errorf("FlushHeaders %v: flushed %v, status %d, %d bytes", flush, rec.Flushed, rec.Code, rec.Body.Len())
+		}
+	}
+	// Small files are left alone.
+	h := FileServer(Dir("./testdata/"), WithLargeFiles(LargeFileConfig{FlushHeaders: true}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/file.txt", nil))
+	if rec.Flushed {
+		t.Errorf("small file flushed")
+	}
+}
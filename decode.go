@@ -0,0 +1,54 @@
+package gzipped
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+)
+
+// The number of bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// serveDecoded sends a compressed variant to a client which won't accept its
+// encoding, by decompressing it on the fly. The decompressed length isn't known
+// in advance, so range requests aren't supported and the body is sent
+// without a Content-Length. The error is non-nil only if nothing has been
+// written to the client yet.
+func serveDecoded(w http.ResponseWriter, r *http.Request, name string, v variant) error {
+	h := w.Header()
+	addVary(h, acceptEncodingHeader)
+	mtime := v.info.ModTime()
+	if !isZeroTime(mtime) {
+		h.Set("Last-Modified", mtime.UTC().Format(http.TimeFormat))
+	}
+	if notModified(w, r, mtime) {
+		writeNotModified(w)
+		return nil
+	}
+
+	zr, err := decoders[v.encoding](v.file)
+	if err != nil {
+		return &VariantError{Name: v.name, Encoding: v.encoding, Err: err}
+	}
+	defer zr.Close()
+	br := bufio.NewReaderSize(zr, sniffLen)
+
+	// The type comes from the logical name, or failing that from the
+	// decompressed content, never from the compressed bytes.
+	if _, haveType := h[contentTypeHeader]; !haveType {
+		ctype := typeByExtension(name)
+		if ctype == "" {
+			buf, err := br.Peek(sniffLen)
+			if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+				return &VariantError{Name: v.name, Encoding: v.encoding, Err: err}
+			}
+			ctype = http.DetectContentType(buf)
+		}
+		h.Set(contentTypeHeader, ctype)
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = io.Copy(w, br)
+	}
+	return nil
+}
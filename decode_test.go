@@ -0,0 +1,68 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressedOnly(t *testing.T) {
+	fs := FileServer(Dir("./testdata/"))
+	for _, tc := range []struct {
+		path     string
+		accept   string
+		status   int
+		encoding string
+		ctype    string
+		body     string
+	}{
+		{"/app.js", "br, gzip", 200, "br", "text/javascript", ""},
+		{"/app.js", "gzip", 200, "gzip", "text/javascript", ""},
+		{"/app.js", "", 200, "", "text/javascript", "console.log(\"compressed only\");\n"},
+		{"/app.js", "deflate", 200, "", "text/javascript", "console.log(\"compressed only\");\n"},
+		{"/brotli.css", "br", 200, "br", "text/css", ""},
+		{"/brotli.css", "gzip", 406, "", "", ""},
+		{"/brotli.css", "", 406, "", "", ""},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept-Encoding", tc.accept)
+		}
+		fs.ServeHTTP(rr, req)
+		h := rr.Header()
+		if rr.Code != tc.status {
+			t.Errorf("%s (%q): status %d, expected %d", tc.path, tc.accept, rr.Code, tc.status)
+			continue
+		}
+		if ce := h.Get("Content-Encoding"); ce != tc.encoding {
+			t.Errorf("%s (%q): Content-Encoding %q, expected %q", tc.path, tc.accept, ce, tc.encoding)
+		}
+		if tc.ctype != "" && !strings.HasPrefix(h.Get("Content-Type"), tc.ctype) {
+			t.Errorf("%s (%q): Content-Type %q, expected %q", tc.path, tc.accept, h.Get("Content-Type"), tc.ctype)
+		}
+		if tc.body != "" && rr.Body.String() != tc.body {
+			t.Errorf("%s (%q): body %q, expected %q", tc.path, tc.accept, rr.Body.String(), tc.body)
+		}
+		if h.Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s (%q): missing Vary header", tc.path, tc.accept)
+		}
+	}
+}
+
+func TestSniffDecoded(t *testing.T) {
+	fs := FileServer(Dir("./testdata/"))
+	for _, accept := range []string{"gzip", "br", ""} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/page", nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		fs.ServeHTTP(rr, req)
+		ctype := rr.Header().Get("Content-Type")
+		if !strings.HasPrefix(ctype, "text/html") {
+			t.Errorf("extensionless page (%q) got Content-Type %q", accept, ctype)
+		}
+	}
+}
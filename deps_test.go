@@ -0,0 +1,26 @@
+package gzipped
+
+import (
+	"go/build"
+	"strings"
+	"testing"
+)
+
+// The core package must only depend on the standard library, with or without
+// the minimal build tag. Anything needing third-party code belongs in a
+// separate package.
+func TestStandardLibraryOnly(t *testing.T) {
+	for _, tags := range [][]string{nil, {"gzipped_minimal"}, {"gzipped_iouring"}} {
+		ctx := build.Default
+		ctx.BuildTags = tags
+		pkg, err := ctx.ImportDir(".", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, imp := range pkg.Imports {
+			if first, _, _ := strings.Cut(imp, "/"); strings.Contains(first, ".") {
+				t.Errorf("with tags %v, package imports non-standard package %s", tags, imp)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// dirListing records, for every file in a directory, which of its variants
+// exist there.
+type dirListing struct {
+	files   map[string]encodingSet // keyed by logical file name
+	expires time.Time
+}
+
+// dirIndex caches directory listings, so that which variants of a file exist
+// can be found with a single lookup rather than a stat per variant.
+type dirIndex struct {
+	ttl      time.Duration
+	perShard int
+	dirs     shardedMap[*dirListing]
+}
+
+func (l *dirListing) expired() bool {
+	return time.Now().After(l.expires)
+}
+
+// Directories with more entries than this aren't indexed, and their files are
+// looked up individually instead.
+const maxIndexedDirEntries = 10000
+
+// WithDirectoryIndex makes the handler find which variants of a file exist by
+// reading its directory once and caching the result for ttl, rather than by
+// checking for each variant on every request. It needs a FileSystem which
+// implements DirReader, as Dir and FS do; for others it has no effect.
+// Changes to a directory may take up to ttl to be noticed.
+func WithDirectoryIndex(ttl time.Duration) Option {
+	return func(f *fileHandler) {
+		if _, ok := f.root.(DirReader); ok {
+			f.dirs = &dirIndex{ttl: ttl, perShard: perShard(defaultStatMaxEntries)}
+		}
+	}
+}
+
+// lookup returns the set of variants of fpath which exist, or false if its
+// directory couldn't be indexed.
+func (d *dirIndex) lookup(root FileSystem, fpath string, encs []encoding) (encodingSet, bool) {
+	dir, file := path.Split(fpath)
+	l, ok := d.dirs.get(dir)
+	if !ok || l.expired() {
+		if l, ok = d.read(root, dir, encs); !ok {
+			return 0, false
+		}
+	}
+	return l.files[file], true
+}
+
+// read lists dir and caches the result.
+func (d *dirIndex) read(root FileSystem, dir string, encs []encoding) (*dirListing, bool) {
+	entries, err := root.(DirReader).ReadDir(dir)
+	if err != nil && len(entries) == 0 {
+		// Nothing in a directory which can't be read exists either, as far
+		// as serving it is concerned, so cache the empty listing.
+		entries = nil
+	} else if len(entries) > maxIndexedDirEntries {
+		return nil, false
+	}
+	l := &dirListing{files: make(map[string]encodingSet, len(entries)), expires: time.Now().Add(d.ttl)}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		for i := range encs {
+			if encs[i].ext == "" {
+				l.files[name] |= 1 << uint(i)
+			} else if strings.HasSuffix(name, encs[i].ext) {
+				l.files[strings.TrimSuffix(name, encs[i].ext)] |= 1 << uint(i)
+			}
+		}
+	}
+	d.dirs.put(dir, l, d.perShard, (*dirListing).expired)
+	return l, true
+}
@@ -0,0 +1,61 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	fs2 "io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// countingDirFS is a countingFS which can also list directories.
+type countingDirFS struct {
+	*countingFS
+	reads int
+}
+
+func (c *countingDirFS) ReadDir(name string) ([]fs2.DirEntry, error) {
+	c.reads++
+	return c.FileSystem.(DirReader).ReadDir(name)
+}
+
+func TestDirectoryIndex(t *testing.T) {
+	sub, err := fs2.Sub(testData, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, f := range map[string]FileSystem{"dir": Dir("./testdata/"), "fs": FS(sub)} {
+		t.Run(name, func(t *testing.T) {
+			root := &countingDirFS{countingFS: newCountingFS(f)}
+			h := FileServer(root, WithDirectoryIndex(time.Minute))
+			for _, tc := range []struct {
+				path, accept, encoding string
+				status                 int
+			}{
+				{"/file.txt", "gzip", "gzip", 200},
+				{"/file.txt", "br", "", 200},
+				{"/app.js", "br", "br", 200},
+				{"/brotli.css", "gzip", "", 406},
+				{"/nonexistent.txt", "gzip", "", 404},
+			} {
+				rr := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", tc.path, nil)
+				req.Header.Set("Accept-Encoding", tc.accept)
+				h.ServeHTTP(rr, req)
+				if rr.Code != tc.status || rr.Header().Get("Content-Encoding") != tc.encoding {
+					t.Errorf("GET %s (%s) returned %d with encoding %q", tc.path, tc.accept, rr.Code, rr.Header().Get("Content-Encoding"))
+				}
+			}
+			if root.reads != 1 {
+				t.Errorf("directory was read %d times", root.reads)
+			}
+			for k, n := range root.opens {
+				if len(k) > 7 && k[:7] == "exists:" {
+					t.Errorf("Exists(%s) was called %d times", k[7:], n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package gzipped
+
+import (
+	fs2 "io/fs"
+	"strings"
+)
+
+// WithoutDotfiles refuses requests for any path with a segment starting with
+// a dot, such as /.env, /.git/config or /css/.DS_Store, which static roots
+// often contain by accident. They're treated as not found, without the
+// FileSystem being consulted. Names in allow, such as ".well-known", are
+// still served, along with everything below them.
+func WithoutDotfiles(allow ...string) Option {
+	return func(f *fileHandler) {
+		f.hideDotfiles = true
+		f.dotfilesAllowed = append(f.dotfilesAllowed, allow...)
+	}
+}
+
+// hiddenPath reports whether fpath has a segment starting with a dot which
+// isn't allowed.
+func (f *fileHandler) hiddenPath(fpath string) bool {
+	for p := strings.TrimPrefix(fpath, "/"); p != ""; {
+		var seg string
+		seg, p = cut(p, '/')
+		if strings.HasPrefix(seg, ".") && !f.dotfileAllowed(seg) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fileHandler) dotfileAllowed(name string) bool {
+	for _, allowed := range f.dotfilesAllowed {
+		if name == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// notFoundError is returned by BestVariant for hidden paths, as a file
+// system would for a missing file.
+func notFoundError(fpath string) error {
+	return &fs2.PathError{Op: "open", Path: fpath, Err: fs2.ErrNotExist}
+}
@@ -0,0 +1,47 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithoutDotfiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{".env", ".git/config", "css/.DS_Store", ".well-known/security.txt", "css/site.css", "a..b"} {
+		fname := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fname), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fname, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := FileServer(Dir(dir), WithoutDotfiles(".well-known"))
+	for _, tc := range []struct {
+		path   string
+		status int
+	}{
+		{"/.env", 404},
+		{"/.git/config", 404},
+		{"/css/.DS_Store", 404},
+		{"/css/../.env", 404},
+		{"/.well-known/security.txt", 200},
+		{"/css/site.css", 200},
+		{"/a..b", 200},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("GET %s returned %d, expected %d", tc.path, rr.Code, tc.status)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	if _, err := BestVariant(req, Dir(dir), "/.env", WithoutDotfiles()); !os.IsNotExist(err) {
+		t.Errorf("BestVariant of a dotfile returned %v", err)
+	}
+}
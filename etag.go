@@ -0,0 +1,61 @@
+package gzipped
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithETags gives every response an ETag made from the modification time
+// and size of the file served, with a suffix naming its encoding, such as
+// "18c2f0e8a1b3c400-1a2b-br", so that caches never mistake one
+// representation of a resource for another (RFC 9110 section 8.8.3). The
+// ETag is then used to answer If-None-Match.
+//
+// If WithIndex is used, or the stat cache has the variants' details from
+// prefetching, a request whose If-None-Match matches is answered 304 Not
+// Modified without any file being opened, as is one whose If-Modified-Since
+// is no earlier than the variant's modification time.
+func WithETags() Option {
+	return func(f *fileHandler) {
+		f.etags = true
+	}
+}
+
+// makeETag returns the ETag for a variant with the given encoding. Decoded
+// variants are the identity representation, and get no suffix.
+func makeETag(mtime time.Time, size int64, enc string) string {
+	var b strings.Builder
+	b.Grow(40)
+	b.WriteByte('"')
+	b.WriteString(strconv.FormatInt(mtime.UnixNano(), 16))
+	b.WriteByte('-')
+	b.WriteString(strconv.FormatInt(size, 16))
+	b.WriteString(etagSuffix(enc))
+	b.WriteByte('"')
+	return b.String()
+}
+
+// etagSuffix returns what's appended to the opaque part of an entity tag to
+// tell apart the variant with encoding enc: a dash and its file extension,
+// such as "-gz", or nothing for the identity encoding.
+func etagSuffix(enc string) string {
+	if i := indexOfEncoding(preferredEncodings, enc); i >= 0 && preferredEncodings[i].ext != "" {
+		return "-" + strings.TrimPrefix(preferredEncodings[i].ext, ".")
+	}
+	return ""
+}
+
+// setETag sets the ETag for v, unless one has already been set.
+func (f *fileHandler) setETag(w http.ResponseWriter, v variant) {
+	h := w.Header()
+	if _, ok := h["Etag"]; ok || isZeroTime(v.info.ModTime()) {
+		return
+	}
+	enc := v.encoding
+	if v.decode {
+		enc = identityEncoding
+	}
+	h["Etag"] = []string{makeETag(v.info.ModTime(), v.info.Size(), enc)}
+}
@@ -0,0 +1,47 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestETags(t *testing.T) {
+	h := FileServer(Dir("./testdata/"), WithETags())
+	get := func(ae, inm string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/file.txt", nil)
+		req.Header.Set("Accept-Encoding", ae)
+		if inm != "" {
+			req.Header.Set("If-None-Match", inm)
+		}
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	plain, gz := get("", "").Header().Get("Etag"), get("gzip", "").Header().Get("Etag")
+	if plain == "" || plain == gz || !strings.HasSuffix(gz, `-gz"`) || strings.Count(plain, "-") != 1 {
+		t.Fatalf("ETags %s for identity and %s for gzip", plain, gz)
+	}
+	for _, tc := range []struct {
+		ae, inm string
+		status  int
+	}{
+		{"gzip", gz, http.StatusNotModified},
+		{"gzip", "W/" + gz, http.StatusNotModified},
+		{"gzip", plain, http.StatusOK},
+		{"", plain + ", " + gz, http.StatusNotModified},
+		{"", gz, http.StatusOK},
+	} {
+		if rr := get(tc.ae, tc.inm); rr.Code != tc.status {
+			t.Errorf("If-None-Match %s with Accept-Encoding %q got %d, expected %d", tc.inm, tc.ae, rr.Code, tc.status)
+		}
+	}
+
+	// Files with no modification time get no ETag.
+	rr := httptest.NewRecorder()
+	FileServer(FS(testData), WithETags()).ServeHTTP(rr, httptest.NewRequest("GET", "/testdata/file.txt", nil))
+	if etag := rr.Header().Get("Etag"); etag != "" {
+		t.Errorf("embedded file got ETag %s", etag)
+	}
+}
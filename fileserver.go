@@ -1,45 +1,97 @@
 package gzipped
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path"
 	"strconv"
 	"strings"
-
-	"github.com/kevinpollet/nego"
 )
 
-// List of encodings we would prefer to use, in order of preference, best first.
-var preferredEncodings = []string{"br", "gzip", "identity"}
+type fileHandler struct {
+	root            FileSystem
+	strict          bool
+	reporter        ErrorReporter
+	fallback        http.Handler
+	mem             *memCache
+	hot             *hotTracker
+	stats           *statCache
+	prefetch        *prefetcher
+	buffers         *bufferPools
+	dirs            *dirIndex
+	index           *Index
+	manifestName    string // served root's manifest, which isn't served
+	build           *buildValidators
+	missing         *missingReporter
+	assets          *AssetMap
+	assetRedirect   bool
+	maxRanges       int
+	rangePolicy     RangePolicy
+	slow            *slowLog
+	access          *accessLog
+	archives        *archiver
+	typeOrders      map[string][]int
+	modTimeSource   ModTimeSource
+	shed            *shedder
+	large           *LargeFileConfig
+	logger          Logger
+	indexFile       string
+	spaIndex        string
+	notFoundHandler http.Handler
+	etags           bool
+	cacheRules      []cacheRule
+	identityRanges  bool
+	skipStale       bool
+	languages       []string
+	metrics         Metrics
+	headers         []func(string, http.Header)
+	hideDotfiles    bool
+	dotfilesAllowed []string
+	anyMethod       bool
+	defaultOrder    []int
+	smallest        bool
+	compressedOnly  bool
+	digests         *reprDigests
+	preloads        map[string][]string
+	earlyHints      bool
+	contentTypes    map[string]string
+	charset         string
+	negotiations    *negotiationCache
+	redirects       bool
+	listing         *listing
+	incompressible  typePatterns
+	minSavings      *minSavings
+	mmaps           *mmapCache
+	serveHooks      []ServeHook
+	negotiator      Negotiator
+	strictIdentity  bool
+	notAcceptable   http.Handler
+	immutable       *immutableAssets
+}
 
-// File extension to use for different encodings.
-func extensionForEncoding(encname string) string {
-	switch encname {
-	case "gzip":
-		return ".gz"
-	case "br":
-		return ".br"
-	case "identity":
-		return ""
-	}
-	return ""
+// VariantError reports that a compressed variant of a file was found and
+// negotiated, but could not be opened or was unusable.
+type VariantError struct {
+	Name     string // name of the variant file
+	Encoding string // content encoding of the variant
+	Err      error  // underlying error
 }
 
-// Function to negotiate the best content encoding
-// Pulled out here so we have the option of overriding nego's behavior and so we can test
-func negotiate(r *http.Request, available []string) string {
-	return nego.NegotiateContentEncoding(r, available...)
+func (e *VariantError) Error() string {
+	return fmt.Sprintf("%s variant %s: %v", e.Encoding, e.Name, e.Err)
 }
 
-type fileHandler struct {
-	root FileSystem
+func (e *VariantError) Unwrap() error {
+	return e.Err
 }
 
 // FileServer is a drop-in replacement for Go's standard http.FileServer
-// which adds support for static resources precompressed with gzip, at
-// the cost of removing the support for directory browsing.
+// which adds support for static resources precompressed with gzip.
+// Directories aren't listed unless WithDirectoryListing is given, and are
+// served an index file only with WithIndexFile.
 //
 // If file filename.ext has a compressed version filename.ext.gz alongside
 // it, if the client indicates that it accepts gzip-compressed data, and
@@ -53,24 +105,68 @@ type fileHandler struct {
 // Compressed or not, requests are fulfilled using http.ServeContent, and
 // details like accept ranges and content-type sniffing are handled by that
 // method.
-func FileServer(root FileSystem) http.Handler {
-	return &fileHandler{root}
+//
+// As with http.FileServer, files which can't be opened because of their
+// permissions get 403 Forbidden rather than 404 Not Found, and the error is
+// reported to the error hook. FileSystem implementations should return
+// errors which match fs.ErrPermission in that case.
+//
+// The behavior of the handler can be adjusted by passing one or more
+// Option values. They're applied in order, so a later option overrides an
+// earlier one which sets the same thing, and nil options are ignored, which
+// makes it easy to build the list conditionally.
+func FileServer(root FileSystem, opts ...Option) http.Handler {
+	f := &fileHandler{root: root, incompressible: defaultIncompressible}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(f)
+		}
+	}
+	return f
+}
+
+// FileServerWithFallback is like FileServer, but rather than responding 404
+// Not Found when no file matches the request, it passes the request to next.
+// This allows the file server to sit in front of a dynamic application.
+func FileServerWithFallback(root FileSystem, next http.Handler, opts ...Option) http.Handler {
+	return FileServer(root, append(opts[:len(opts):len(opts)], WithFallback(next))...)
 }
 
 func (f *fileHandler) openAndStat(path string) (http.File, os.FileInfo, error) {
-	file, err := f.root.Open(path)
-	var info os.FileInfo
+	return f.openKnown(context.Background(), path, nil)
+}
+
+// openKnown is openAndStat for a request with the context ctx. If info is
+// already known from looking for the file, it's used rather than asking the
+// opened file.
+func (f *fileHandler) openKnown(ctx context.Context, path string, info os.FileInfo) (http.File, os.FileInfo, error) {
+	if f.mem != nil {
+		file, info, ok := f.mem.open(path)
+		if f.metrics != nil {
+			f.metrics.CacheLookup(ok)
+		}
+		if ok {
+			return file, info, nil
+		}
+	}
+	file, err := openContext(ctx, f.root, path)
 	// This slightly weird variable reuse is so we can get 100% test coverage
 	// without having to come up with a test file that can be opened, yet
 	// fails to stat.
-	if err == nil {
+	if err == nil && info == nil {
 		info, err = file.Stat()
 	}
 	if err != nil {
 		return file, nil, err
 	}
 	if info.IsDir() {
-		return file, nil, fmt.Errorf("%s is directory", path)
+		return file, nil, fmt.Errorf("%s %w", path, errIsDirectory)
+	}
+	if f.mem != nil {
+		file = f.mem.fill(path, file, info)
+	}
+	if f.mmaps != nil {
+		file = f.mmaps.open(path, file, info)
 	}
 	return file, info, nil
 }
@@ -83,71 +179,455 @@ const (
 	varyHeader            = "Vary"
 )
 
+// variant is a representation of a file which has been chosen and opened to
+// satisfy a request.
+type variant struct {
+	name     string // name of the file on the FileSystem
+	encoding string // content encoding of the file, "identity" if none
+	file     http.File
+	info     os.FileInfo
+	decode   bool // the file must be decompressed before sending
+	// shared is set on an uncompressed file which has compressed variants, so
+	// that it's one of several representations even though it's not encoded.
+	shared bool
+}
+
+// ErrNotAcceptable is returned by BestVariant when a file exists only in
+// compressed forms, none of which the client will accept, and which can't be
+// decompressed for the client either, or with WithNotAcceptable, when the
+// client refuses identity as well as the file's variants. FileServer
+// responds 406 Not Acceptable.
+var ErrNotAcceptable = errors.New("no acceptable representation")
+
 // Find the best file to serve based on the client's Accept-Encoding, and which
 // files actually exist on the filesystem. If no file was found that can satisfy
 // the request, the error field will be non-nil.
-func (f *fileHandler) findBestFile(w http.ResponseWriter, r *http.Request, fpath string) (http.File, os.FileInfo, error) {
+func (f *fileHandler) findBestFile(w http.ResponseWriter, r *http.Request, fpath string) (variant, error) {
+	encs := preferredEncodings
+	var names [maxEncodings]string
+	// FileInfo for the variants, if the FileSystem is a Stater.
+	var infos [maxEncodings]os.FileInfo
 	ae := r.Header.Get(acceptEncodingHeader)
-	if ae == "" {
-		return f.openAndStat(fpath)
+	// See what possible encodings we can send by looking for files. That's
+	// needed even without an accept header, since an uncompressed response
+	// still needs a Vary header if there are variants.
+	variantNames(fpath, encs, names[:])
+	available := f.statEncodings(fpath, encs, names[:], infos[:])
+	if ae != "" && available != 0 {
+		// Carry out standard HTTP negotiation
+		order := f.encodingOrderFor(fpath, encs, names[:], available)
+		i := f.negotiate(r, ae, encs, available, order)
+		refused := i < 0
+		if i >= 0 && encs[i].name != identityEncoding && f.identityRanges && f.identityRange(w, r, encs, available) {
+			i = -1
+		}
+		for i >= 0 && encs[i].name != identityEncoding {
+			v, err := f.openVariant(w, r, &encs[i], names[i], infos[i])
+			if err == nil && (f.skipStale && f.staleVariant(r, fpath, v, encs, available) ||
+				f.minSavings != nil && f.smallSavings(r, fpath, v, encs, names[:], infos[:], available)) {
+				// Try the next best encoding instead.
+				v.file.Close()
+				clearVariantHeaders(w)
+				if f.metrics != nil {
+					f.metrics.Fallback(encs[i].name)
+				}
+				available &^= 1 << uint(i)
+				i = f.negotiate(r, ae, encs, available, order)
+				refused = i < 0
+				continue
+			}
+			if err == nil || f.strict {
+				return v, err
+			}
+			if f.logger != nil {
+				f.debug(r, "gzipped: falling back from unusable variant", "path", fpath, "error", err)
+			}
+			if f.metrics != nil {
+				f.metrics.Fallback(encs[i].name)
+			}
+			break
+		}
+		if refused && f.strictIdentity {
+			return variant{}, ErrNotAcceptable
+		}
+	}
+
+	if f.compressedOnly {
+		if available == 0 {
+			return variant{}, &os.PathError{Op: "open", Path: fpath, Err: os.ErrNotExist}
+		}
+		return f.findCompressedOnly(r.Context(), encs, names[:], available)
 	}
-	// Got an accept header? See what possible encodings we can send by looking for files
-	var available []string
-	for _, posenc := range preferredEncodings {
-		ext := extensionForEncoding(posenc)
-		fname := fpath + ext
-		if f.root.Exists(fname) {
-			available = append(available, posenc)
+
+	// If we fail to negotiate anything, if we negotiated the identity encoding,
+	// or if all else failed, try the base file
+	file, info, err := f.openKnown(r.Context(), fpath, infos[indexOfEncoding(encs, identityEncoding)])
+	if err == nil {
+		shared := available&^(1<<uint(indexOfEncoding(encs, identityEncoding))) != 0
+		if shared {
+			addVary(w.Header(), acceptEncodingHeader)
 		}
+		return variant{name: fpath, encoding: identityEncoding, file: file, info: info, shared: shared}, nil
 	}
-	if len(available) == 0 {
-		return f.openAndStat(fpath)
+	if file != nil {
+		file.Close()
 	}
-	// Carry out standard HTTP negotiation
-	negenc := negotiate(r, available)
-	if negenc == "" || negenc == "identity" {
-		// If we fail to negotiate anything or if we negotiated the identity encoding, again try the base file
-		return f.openAndStat(fpath)
+	if errors.Is(err, os.ErrNotExist) {
+		if available != 0 {
+			return f.findCompressedOnly(r.Context(), encs, names[:], available)
+		}
 	}
-	ext := extensionForEncoding(negenc)
-	if file, info, err := f.openAndStat(fpath + ext); err == nil {
-		wHeader := w.Header()
-		wHeader[contentEncodingHeader] = []string{negenc}
-		wHeader.Add(varyHeader, acceptEncodingHeader)
+	return variant{}, err
+}
+
+// availableEncodings returns the set of encodings for which a variant of
+// fpath exists.
+func (f *fileHandler) availableEncodings(fpath string, encs []encoding, names []string) encodingSet {
+	return f.statEncodings(fpath, encs, names, nil)
+}
+
+// statEncodings is availableEncodings, but also fills infos, if it's not nil,
+// with the FileInfo of each variant when that's found out along the way.
+func (f *fileHandler) statEncodings(fpath string, encs []encoding, names []string, infos []os.FileInfo) encodingSet {
+	available := f.lookupEncodings(fpath, encs, names, infos)
+	identity := encodingSet(1) << uint(indexOfEncoding(encs, identityEncoding))
+	if f.compressedOnly {
+		return available &^ identity
+	}
+	if available&identity != 0 && available != identity && f.incompressible != nil && f.incompressibleFile(fpath) {
+		// Variants of an already compressed format are mistakes.
+		return identity
+	}
+	return available
+}
 
-		if len(r.Header[rangeHeader]) == 0 {
-			// If not a range request then we can easily set the content length which the
-			// Go standard library does not do if "Content-Encoding" is set.
-			wHeader[contentLengthHeader] = []string{strconv.FormatInt(info.Size(), 10)}
+// lookupEncodings finds the encodings for statEncodings.
+func (f *fileHandler) lookupEncodings(fpath string, encs []encoding, names []string, infos []os.FileInfo) encodingSet {
+	if f.index != nil {
+		return f.index.available(fpath, encs)
+	}
+	if f.stats != nil {
+		if m, ok := f.stats.get(fpath); ok {
+			return m.available
 		}
-		return file, info, nil
 	}
+	var available encodingSet
+	if f.dirs != nil {
+		if avail, ok := f.dirs.lookup(f.root, fpath, encs); ok {
+			return avail
+		}
+	}
+	if be, ok := f.root.(BatchExister); ok {
+		var exists [maxEncodings]bool
+		be.ExistsBatch(names[:len(encs)], exists[:len(encs)])
+		for i := range encs {
+			if exists[i] {
+				available |= 1 << uint(i)
+			}
+		}
+	} else if st, ok := f.root.(Stater); ok {
+		for i := range encs {
+			if f.compressedOnly && encs[i].name == identityEncoding {
+				continue
+			}
+			if info, err := st.Stat(names[i]); err == nil && !info.IsDir() {
+				available |= 1 << uint(i)
+				if infos != nil {
+					infos[i] = info
+				}
+			}
+		}
+	} else {
+		for i := range encs {
+			if f.compressedOnly && encs[i].name == identityEncoding {
+				continue
+			}
+			if f.root.Exists(names[i]) {
+				available |= 1 << uint(i)
+			}
+		}
+	}
+	if f.stats != nil {
+		f.stats.put(fpath, &variantMeta{available: available})
+	}
+	return available
+}
 
-	// If all else failed, fall back to base file once again
-	return f.openAndStat(fpath)
+// openVariant opens the compressed variant fname with the given encoding, and
+// sets the response headers needed to serve it. info is its FileInfo, if
+// that's already known.
+func (f *fileHandler) openVariant(w http.ResponseWriter, r *http.Request, enc *encoding, fname string, info os.FileInfo) (variant, error) {
+	file, info, err := f.openKnown(r.Context(), fname, info)
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return variant{}, &VariantError{Name: fname, Encoding: enc.name, Err: err}
+	}
+	wHeader := w.Header()
+	wHeader[contentEncodingHeader] = enc.header
+	addVary(wHeader, acceptEncodingHeader)
+
+	if len(r.Header[rangeHeader]) == 0 {
+		// If not a range request then we can easily set the content length which the
+		// Go standard library does not do if "Content-Encoding" is set.
+		wHeader[contentLengthHeader] = []string{strconv.FormatInt(info.Size(), 10)}
+	}
+	return variant{name: fname, encoding: enc.name, file: file, info: info}, nil
+}
+
+// findCompressedOnly handles a file which only exists in compressed form, and
+// for which the client didn't accept any of the encodings available. If there
+// is a variant we have a decoder for (such as gzip), we decompress it on the
+// fly, otherwise the client gets an ErrNotAcceptable.
+func (f *fileHandler) findCompressedOnly(ctx context.Context, encs []encoding, names []string, available encodingSet) (variant, error) {
+	for i := range encs {
+		if _, ok := decoders[encs[i].name]; !ok || !available.has(i) {
+			continue
+		}
+		file, info, err := f.openKnown(ctx, names[i], nil)
+		if err != nil {
+			if file != nil {
+				file.Close()
+			}
+			return variant{}, &VariantError{Name: names[i], Encoding: encs[i].name, Err: err}
+		}
+		return variant{name: names[i], encoding: encs[i].name, file: file, info: info, decode: true}, nil
+	}
+	return variant{}, ErrNotAcceptable
 }
 
 func (f *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var timer *requestTimer
+	if f.slow != nil || f.access != nil {
+		timer = f.startTimer(w, r)
+		defer timer.finish()
+		w = timer
+	}
+	if f.shed != nil {
+		if !f.shed.acquire(r.Context()) {
+			f.rejectOverload(w, r)
+			return
+		}
+		defer f.shed.release()
+	}
+	if f.checkMethod(w, r) {
+		return
+	}
 	upath := r.URL.Path
 	if !strings.HasPrefix(upath, "/") {
 		upath = "/" + upath
 		r.URL.Path = upath
 	}
+	if f.redirects && f.indexFile != "" && f.redirectIndex(w, r, upath) {
+		return
+	}
 	fpath := path.Clean(upath)
-	if strings.HasSuffix(fpath, "/") {
-		// If you wanted to put back directory browsing support, this is
-		// where you'd do it.
-		http.NotFound(w, r)
+	if f.assets != nil {
+		if fpath = f.resolveAsset(w, r, fpath); fpath == "" {
+			return
+		}
+	}
+	if strings.HasSuffix(upath, "/") {
+		switch {
+		case f.listing != nil && f.listing.wants(f, fpath):
+			f.listing.serve(f, w, r, fpath)
+			return
+		case f.indexFile != "":
+			fpath = path.Join(fpath, f.indexFile)
+		case f.spaIndex != "":
+			fpath = f.spaIndex
+		default:
+			f.notFound(w, r)
+			return
+		}
+	}
+	f.serve(w, r, fpath, timer)
+}
+
+// serve responds with the best variant of the file fpath, a cleaned path
+// with a leading slash. timer is nil unless requests are being logged.
+func (f *fileHandler) serve(w http.ResponseWriter, r *http.Request, fpath string, timer *requestTimer) {
+	if f.hideDotfiles && f.hiddenPath(fpath) || f.manifestName != "" && fpath == f.manifestName {
+		f.notFound(w, r)
+		return
+	}
+	if f.earlyHints && f.preloads != nil {
+		f.sendEarlyHints(w, r, fpath)
+	}
+	if f.languages != nil {
+		fpath = f.negotiateLanguage(w, r, fpath)
+	}
+	if f.precheckConditional(w, r, fpath) {
 		return
 	}
 
 	// Find the best acceptable file, including trying uncompressed
-	if file, info, err := f.findBestFile(w, r, fpath); err == nil {
-		http.ServeContent(w, r, fpath, info.ModTime(), file)
-		file.Close()
+	v, err := f.findBestFile(w, r, fpath)
+	if err != nil && f.redirects && f.indexFile != "" && f.redirectDirectory(w, r, err) {
+		return
+	}
+	if err != nil && f.spaRoute(fpath, err) {
+		fpath = f.spaIndex
+		v, err = f.findBestFile(w, r, fpath)
+	}
+	if timer != nil {
+		timer.found(fpath, v, err)
+	}
+	if f.logger != nil {
+		f.logSelection(r, fpath, v, err)
+	}
+	if err == nil {
+		defer v.file.Close()
+		if f.metrics != nil {
+			if v.decode {
+				f.metrics.Served(identityEncoding)
+			} else {
+				f.metrics.Served(v.encoding)
+			}
+		}
+		if f.hot != nil {
+			f.hot.hit(f, v.name)
+		}
+		if f.prefetch != nil {
+			f.prefetch.start(f, fpath)
+		}
+		if f.modTimeSource != ModTimeVariant {
+			v.info = f.variantModTime(fpath, v)
+		}
+		if f.build != nil && isZeroTime(v.info.ModTime()) {
+			v.info = f.build.apply(w, v)
+		}
+		if f.etags {
+			f.setETag(w, v)
+		}
+		if f.digests != nil {
+			f.digests.apply(f, w, r, fpath, v)
+		}
+		if f.cacheRules != nil || f.immutable != nil {
+			f.setCacheControl(w, r, fpath)
+		}
+		if f.headers != nil {
+			f.setHeaders(w, r, fpath)
+		}
+		if f.preloads != nil {
+			f.setPreloads(w, f.untranslated(fpath))
+		}
+		if f.modTimeSource == ModTimeNone {
+			// Validators have been made, so the time can go.
+			v.info = modTimeInfo{FileInfo: v.info}
+		}
+		if f.serveHooks != nil && !f.runServeHooks(w, r, v) {
+			return
+		}
+		if variantNotModified(w, r, v) {
+			writeNotModified(w)
+			return
+		}
+		if f.large != nil {
+			w = f.prepareLarge(w, v.info.Size())
+		}
+		if f.buffers != nil {
+			w = &bufferedWriter{ResponseWriter: w, pool: f.buffers.forSize(v.info.Size())}
+		}
+		if v.decode {
+			if _, haveType := w.Header()[contentTypeHeader]; !haveType && (f.contentTypes != nil || f.charset != "") {
+				if ctype := f.typeByExtension(fpath); ctype != "" {
+					w.Header()[contentTypeHeader] = contentTypeValue(ctype)
+				}
+			}
+			if err := serveDecoded(w, r, fpath, v); err != nil {
+				f.serveError(w, r, err)
+			}
+			return
+		}
+		if !seekable(v.file) {
+			v.file = &rewindFile{File: v.file}
+			f.setContentType(w, fpath, v)
+			serveStream(w, r, v)
+			return
+		}
+		if f.rangePolicy != nil && !f.rangePolicy(f.untranslated(fpath), v.info) {
+			w, r = withoutRanges(w, r, v.info.Size())
+		} else if f.maxRanges > 0 {
+			if r = f.limitRanges(w, r, v.info.Size()); r == nil {
+				return
+			}
+		}
+		// With identity ranges, every partial response is of the original,
+		// so a date in If-Range can't be mistaken.
+		if v.encoding != identityEncoding || v.shared && !f.identityRanges {
+			r = negotiatedRangeRequest(w, r, v.info.Size())
+		}
+		f.setContentType(w, fpath, v)
+		http.ServeContent(w, r, fpath, v.info.ModTime(), v.file)
+		return
+	}
+	if errors.Is(err, ErrNotAcceptable) {
+		f.serveNotAcceptable(w, r)
+		return
+	}
+	var verr *VariantError
+	if errors.As(err, &verr) {
+		f.serveError(w, r, err)
+		return
+	}
+	if errors.Is(err, os.ErrPermission) {
+		// The file exists, but we're not allowed to read it, which is
+		// worth telling the client and the operator apart from a 404.
+		f.reportError(r, err)
+		serveErrorText(w, r, "403 Forbidden", http.StatusForbidden)
 		return
 	}
 
 	// Doesn't exist, compressed or uncompressed
-	http.NotFound(w, r)
+	if f.archives != nil && f.archives.serve(f, w, r, fpath) {
+		return
+	}
+	f.notFound(w, r)
+}
+
+// setContentType sets the Content-Type from the logical file name, so that
+// ServeContent doesn't sniff the compressed bytes of a variant to find one.
+// Identity files with no known type are left for ServeContent to sniff.
+func (f *fileHandler) setContentType(w http.ResponseWriter, fpath string, v variant) {
+	h := w.Header()
+	if _, haveType := h[contentTypeHeader]; haveType {
+		return
+	}
+	ctype := f.typeByExtension(fpath)
+	if ctype == "" && f.index != nil {
+		ctype = f.addCharset(f.index.contentType(f.untranslated(fpath)))
+	}
+	if ctype == "" {
+		if v.encoding == "identity" {
+			return
+		}
+		ctype = f.sniffVariant(fpath, v)
+	}
+	h[contentTypeHeader] = contentTypeValue(ctype)
+}
+
+// serveError reports an internal error to the error hook and responds 500.
+func (f *fileHandler) serveError(w http.ResponseWriter, r *http.Request, err error) {
+	f.reportError(r, err)
+	serveErrorText(w, r, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+func (f *fileHandler) notFound(w http.ResponseWriter, r *http.Request) {
+	if f.metrics != nil {
+		f.metrics.NotFound()
+	}
+	if f.fallback != nil {
+		f.fallback.ServeHTTP(w, r)
+		return
+	}
+	if f.missing != nil {
+		f.missing.record(r)
+	}
+	if f.notFoundHandler != nil {
+		f.notFoundHandler.ServeHTTP(w, r)
+		return
+	}
+	serveErrorText(w, r, "404 page not found", http.StatusNotFound)
 }
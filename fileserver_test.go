@@ -11,13 +11,11 @@ import (
 	"net/textproto"
 	"strconv"
 	"testing"
-
-	"github.com/kevinpollet/nego"
 )
 
 // Test that the server respects client preferences
 func TestPreference(t *testing.T) {
-	req := http.Request{Header: http.Header{}}
+	all := encodingSet(1<<uint(len(preferredEncodings)) - 1)
 
 	// the client doesn't set any preferences, so we should pick br
 	for _, info := range []struct {
@@ -28,8 +26,7 @@ func TestPreference(t *testing.T) {
 		{"gzip, deflate, br", "br"},
 		{"gzip, deflate, br;q=0.5", "gzip"},
 	} {
-		req.Header.Set("Accept-Encoding", info.hdr)
-		negenc := nego.NegotiateContentEncoding(&req, preferredEncodings...)
+		negenc := preferredEncodings[negotiate(info.hdr, preferredEncodings, all)].name
 		if negenc != info.expect {
 			t.Errorf("server chose %s but we expected %s for header %s", negenc, info.expect, info.hdr)
 		}
@@ -104,7 +101,7 @@ func TestFileServer(t *testing.T) {
 			{
 				name: "OpenStat",
 				test: func(t *testing.T) {
-					fh := &fileHandler{f}
+					fh := &fileHandler{root: f}
 					_, _, err := fh.openAndStat(".")
 					if err == nil {
 						t.Errorf("openAndStat directory succeeded, should have failed")
@@ -213,3 +210,131 @@ func TestConstHeaders(t *testing.T) {
 		}
 	}
 }
+
+func TestFallback(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	fs := FileServerWithFallback(Dir("./testdata/"), next)
+	for _, tc := range []struct {
+		path   string
+		status int
+	}{
+		{"/file.txt", 200},
+		{"/nonexistent.txt", http.StatusTeapot},
+		{"/", http.StatusTeapot},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		fs.ServeHTTP(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("GET %s returned %d, expected %d", tc.path, rr.Code, tc.status)
+		}
+	}
+}
+
+func TestZstd(t *testing.T) {
+	dir := t.TempDir()
+	zst := []byte("\x28\xb5\x2f\xfd\x04\x00\xa9\x00\x00\x7a\x73\x74\x61\x6e\x64\x61\x72\x64\x20\x63\x6f\x6d\x70\x72\x65\x73\x73\x65\x64\x0a\x73\x9c\x5c\x06")
+	for name, content := range map[string][]byte{
+		"page.txt":     []byte("zstandard compressed\n"),
+		"page.txt.zst": zst,
+		"page.txt.br":  []byte("not really brotli"),
+	} {
+		if err := ioutil.WriteFile(dir+"/"+name, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs := FileServer(Dir(dir))
+	for _, tc := range []struct {
+		ae     string
+		expect string
+	}{
+		{"zstd", "zstd"},
+		{"gzip, zstd", "zstd"},
+		{"zstd, br", "br"},
+		{"zstd;q=1, br;q=0.5", "zstd"},
+		{"gzip", ""},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/page.txt", nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		fs.ServeHTTP(rr, req)
+		if got := rr.Header().Get("Content-Encoding"); got != tc.expect {
+			t.Errorf("Accept-Encoding %q: Content-Encoding %q, expected %q", tc.ae, got, tc.expect)
+		}
+		if tc.expect == "zstd" {
+			if !bytes.Equal(rr.Body.Bytes(), zst) {
+				t.Errorf("Accept-Encoding %q: served %q, expected the .zst file", tc.ae, rr.Body.Bytes())
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+				t.Errorf("zstd variant served as %q", ct)
+			}
+		}
+	}
+}
+
+// nopResponseWriter discards responses, so benchmarks measure the handler
+// rather than httptest.ResponseRecorder.
+type nopResponseWriter struct {
+	h http.Header
+}
+
+func (w *nopResponseWriter) Header() http.Header         { return w.h }
+func (w *nopResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *nopResponseWriter) WriteHeader(int)             {}
+
+func benchmarkServeHTTP(b *testing.B, urlPath string, acceptEncoding string) {
+	fs := FileServer(Dir("./testdata/"))
+	req, _ := http.NewRequest("GET", urlPath, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := &nopResponseWriter{h: http.Header{}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for k := range w.h {
+			delete(w.h, k)
+		}
+		fs.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkServeHTTPIdentity(b *testing.B) {
+	benchmarkServeHTTP(b, "/file.txt", "")
+}
+
+func BenchmarkServeHTTPGzip(b *testing.B) {
+	benchmarkServeHTTP(b, "/file.txt", "gzip, deflate, br;q=0.5")
+}
+
+func BenchmarkServeHTTPNoVariant(b *testing.B) {
+	benchmarkServeHTTP(b, "/file2.txt", "gzip, deflate, br")
+}
+
+// batchFS implements BatchExister, counting how it's used.
+type batchFS struct {
+	FileSystem
+	batches, singles int
+}
+
+func (b *batchFS) Exists(name string) bool {
+	b.singles++
+	return b.FileSystem.Exists(name)
+}
+
+func (b *batchFS) ExistsBatch(names []string, exists []bool) {
+	b.batches++
+	for i, name := range names {
+		exists[i] = b.FileSystem.Exists(name)
+	}
+}
+
+func TestBatchExister(t *testing.T) {
+	root := &batchFS{FileSystem: Dir("./testdata/")}
+	testGet(t, root, true, "/file.txt", "abcdefghijklmnopqrstuvwxyz\n")
+	if root.batches != 1 || root.singles != 0 {
+		t.Errorf("variants looked up with %d batches and %d single calls", root.batches, root.singles)
+	}
+}
@@ -1,6 +1,7 @@
 package gzipped
 
 import (
+	"context"
 	fs2 "io/fs"
 	"net/http"
 	"os"
@@ -16,21 +17,110 @@ type FileSystem interface {
 	Exists(string) bool
 }
 
+// BatchExister may be implemented by a FileSystem which can check for the
+// existence of several files at once more cheaply than one at a time. If
+// the FileSystem passed to FileServer implements it, ExistsBatch is used to
+// look for all the variants of a file together. It must set exists[i] to
+// whether names[i] exists.
+type BatchExister interface {
+	ExistsBatch(names []string, exists []bool)
+}
+
+// Stater may be implemented by a FileSystem which can find out about a file
+// without opening it. If the FileSystem passed to FileServer implements it,
+// and not BatchExister, Stat is used instead of Exists to look for variants,
+// and the FileInfo of the one served is reused rather than asking the opened
+// file for it again. The FileInfo must therefore describe the file Open
+// would return. Names are resolved the same way as for Open. Dir and the
+// FileSystems returned by FS implement it.
+type Stater interface {
+	Stat(name string) (fs2.FileInfo, error)
+}
+
+// Stat returns the FileInfo of the named file in root, using its Stat method
+// if it implements Stater, and otherwise opening the file to ask it.
+func Stat(root FileSystem, name string) (fs2.FileInfo, error) {
+	if s, ok := root.(Stater); ok {
+		return s.Stat(name)
+	}
+	file, err := root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return file.Stat()
+}
+
+// ContextOpener may be implemented by a FileSystem which is slow to open
+// files, such as one on a network file system or in object storage. If the
+// FileSystem passed to FileServer implements it, OpenContext is used instead
+// of Open to open the files served, with the request's context, so that the
+// work can stop when the client goes away or a server timeout fires. The file
+// may go on using ctx for reads, since it's only used while the request is
+// being served.
+type ContextOpener interface {
+	OpenContext(ctx context.Context, name string) (http.File, error)
+}
+
+// openContext opens name in root, with OpenContext if it's a ContextOpener.
+func openContext(ctx context.Context, root FileSystem, name string) (http.File, error) {
+	if co, ok := root.(ContextOpener); ok {
+		return co.OpenContext(ctx, name)
+	}
+	return root.Open(name)
+}
+
+// DirReader may be implemented by a FileSystem which can list directories.
+// Names are resolved the same way as for Open. Dir and the FileSystems
+// returned by FS implement it.
+type DirReader interface {
+	ReadDir(name string) ([]fs2.DirEntry, error)
+}
+
 // Dir is a replacement for the http.Dir type, and implements FileSystem.
 type Dir string
 
 // Exists tests whether a file with the specified name exists, resolved relative to the base directory.
 func (d Dir) Exists(name string) bool {
+	_, err := d.Stat(name)
+	return err == nil
+}
+
+// Stat returns the FileInfo of the named file, resolved relative to the base
+// directory.
+func (d Dir) Stat(name string) (fs2.FileInfo, error) {
+	fullName, ok := d.resolve(name)
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return os.Stat(fullName)
+}
+
+// ReadDir reads the named directory, resolved relative to the base directory.
+func (d Dir) ReadDir(name string) ([]fs2.DirEntry, error) {
+	fullName, ok := d.resolve(name)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return os.ReadDir(fullName)
+}
+
+// resolve turns a slash-separated name into an OS path under the base
+// directory, the same way http.Dir does.
+func (d Dir) resolve(name string) (string, bool) {
 	if filepath.Separator != '/' && strings.ContainsRune(name, filepath.Separator) {
-		return false
+		return "", false
 	}
 	dir := string(d)
 	if dir == "" {
 		dir = "."
 	}
-	fullName := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+name)))
-	_, err := os.Stat(fullName)
-	return err == nil
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	// Equivalent to filepath.Join, but without allocating a second time to
+	// clean the result when name is already clean.
+	return strings.TrimRight(dir, `/`+string(filepath.Separator)) + filepath.FromSlash(path.Clean(name)), true
 }
 
 // Open defers to http.Dir's Open so that gzipped.Dir implements http.FileSystem.
@@ -48,14 +138,29 @@ type fs struct {
 
 // Exists tests whether a file with the specified name exists, resolved relative to the file system.
 func (f fs) Exists(name string) bool {
+	_, err := f.Stat(name)
+	return err == nil
+}
+
+// Stat returns the FileInfo of the named file, resolved relative to the file
+// system.
+func (f fs) Stat(name string) (fs2.FileInfo, error) {
 	if filepath.Separator != '/' && strings.ContainsRune(name, filepath.Separator) {
-		return false
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
 	}
-	_, err := fs2.Stat(f.fs, strings.TrimPrefix(filepath.FromSlash(path.Clean(name)), "/"))
-	return err == nil
+	return fs2.Stat(f.fs, strings.TrimPrefix(filepath.FromSlash(path.Clean(name)), "/"))
 }
 
 // Open defers to http.FS's Open so that gzipped.fs implements http.FileSystem.
 func (f fs) Open(name string) (http.File, error) {
 	return http.FS(f.fs).Open(strings.TrimPrefix(name, "/"))
 }
+
+// ReadDir reads the named directory, resolved relative to the file system.
+func (f fs) ReadDir(name string) ([]fs2.DirEntry, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	return fs2.ReadDir(f.fs, name)
+}
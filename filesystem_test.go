@@ -0,0 +1,126 @@
+package gzipped
+
+import (
+	"context"
+	fs2 "io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+// statFS is a Stater which counts how often its files are asked for their
+// FileInfo after being opened.
+type statFS struct {
+	Dir
+	stats, fileStats int
+}
+
+func (s *statFS) Stat(name string) (fs2.FileInfo, error) {
+	s.stats++
+	return s.Dir.Stat(name)
+}
+
+func (s *statFS) Open(name string) (http.File, error) {
+	file, err := s.Dir.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &statCountingFile{File: file, fs: s}, nil
+}
+
+type statCountingFile struct {
+	http.File
+	fs *statFS
+}
+
+func (f *statCountingFile) Stat() (fs2.FileInfo, error) {
+	f.fs.fileStats++
+	return f.File.Stat()
+}
+
+func TestStater(t *testing.T) {
+	for _, tc := range []struct {
+		ae, expect string
+	}{
+		{"gzip", "gzip"},
+		{"br", ""},
+	} {
+		root := &statFS{Dir: "./testdata/"}
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/file.txt", nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		FileServer(root).ServeHTTP(rr, req)
+		if rr.Code != 200 || rr.Header().Get("Content-Encoding") != tc.expect {
+			t.Fatalf("Accept-Encoding %q: %d, Content-Encoding %q", tc.ae, rr.Code, rr.Header().Get("Content-Encoding"))
+		}
+		if root.stats != len(preferredEncodings) || root.fileStats != 0 {
+			t.Errorf("Accept-Encoding %q: %d calls to Stat and %d to File.Stat, expected %d and 0",
+				tc.ae, root.stats, root.fileStats, len(preferredEncodings))
+		}
+	}
+}
+
+func TestStat(t *testing.T) {
+	for name, root := range map[string]FileSystem{
+		"Dir":     Dir("./testdata/"),
+		"FS":      FS(fstest.MapFS{"file.txt": {Data: []byte("hello")}}),
+		"wrapped": struct{ FileSystem }{Dir("./testdata/")},
+	} {
+		info, err := Stat(root, "/file.txt")
+		if err != nil || info.IsDir() || info.Name() != "file.txt" {
+			t.Errorf("%s: Stat returned %v, %v", name, info, err)
+		}
+		if _, err := Stat(root, "/nonexistent.txt"); !os.IsNotExist(err) {
+			t.Errorf("%s: Stat of a missing file returned %v", name, err)
+		}
+	}
+}
+
+// contextFS is a ContextOpener which records the contexts it's given.
+type contextFS struct {
+	FileSystem
+	opened []context.Context
+}
+
+func (c *contextFS) OpenContext(ctx context.Context, name string) (http.File, error) {
+	c.opened = append(c.opened, ctx)
+	return c.FileSystem.Open(name)
+}
+
+type contextKey struct{}
+
+func TestContextOpener(t *testing.T) {
+	for _, tc := range []struct {
+		root FileSystem
+		ae   string
+	}{
+		{Dir("./testdata/"), "gzip"},
+		{Dir("./testdata/"), ""},
+	} {
+		root := &contextFS{FileSystem: tc.root}
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/file.txt", nil)
+		req = req.WithContext(context.WithValue(req.Context(), contextKey{}, "request"))
+		req.Header.Set("Accept-Encoding", tc.ae)
+		FileServer(root).ServeHTTP(rr, req)
+		if rr.Code != 200 {
+			t.Fatalf("Accept-Encoding %q: %d", tc.ae, rr.Code)
+		}
+		if len(root.opened) != 1 || root.opened[0].Value(contextKey{}) != "request" {
+			t.Errorf("Accept-Encoding %q: opened with %v, expected the request's context", tc.ae, root.opened)
+		}
+	}
+	// Multi passes contexts on to its layers.
+	inner := &contextFS{FileSystem: Dir("./testdata/")}
+	ctx := context.WithValue(context.Background(), contextKey{}, "multi")
+	file, err := Multi(Dir(t.TempDir()), inner).(ContextOpener).OpenContext(ctx, "/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if len(inner.opened) != 1 || inner.opened[0].Value(contextKey{}) != "multi" {
+		t.Errorf("layer opened with %v", inner.opened)
+	}
+}
@@ -0,0 +1,105 @@
+package gzipped
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Generated responses smaller than this aren't worth compressing.
+const minGeneratedCompress = 256
+
+// Encodings which generated responses can be compressed with, which are
+// those the standard library has an encoder for.
+var generatedEncodings = []encoding{
+	newEncoding("gzip", ".gz"),
+	newEncoding(identityEncoding, ""),
+}
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		zw, _ := gzip.NewWriterLevel(nil, gzip.BestCompression)
+		return zw
+	},
+}
+
+// generated is a response body produced by the handler itself, such as an
+// error page or the asset manifest, rather than read from a file. It's
+// compressed according to Accept-Encoding like a file would be.
+type generated struct {
+	ctype   string
+	body    []byte
+	gzipped []byte // nil if compression doesn't help
+	etag    string // entity tag of the identity body, if any
+}
+
+// newGenerated prepares body for serving, compressing it up front if it's
+// large enough to be worth it.
+func newGenerated(ctype string, body []byte, etag string) *generated {
+	g := &generated{ctype: ctype, body: body, etag: etag}
+	if len(body) < minGeneratedCompress {
+		return g
+	}
+	var buf bytes.Buffer
+	zw := gzipWriters.Get().(*gzip.Writer)
+	zw.Reset(&buf)
+	_, _ = zw.Write(body)
+	_ = zw.Close()
+	gzipWriters.Put(zw)
+	if buf.Len() < len(body) {
+		g.gzipped = buf.Bytes()
+	}
+	return g
+}
+
+// serve sends the body with the given status. Successful responses go
+// through http.ServeContent, so they get conditional and range request
+// support.
+func (g *generated) serve(w http.ResponseWriter, r *http.Request, status int) {
+	h := w.Header()
+	h.Set(contentTypeHeader, g.ctype)
+	body, etag := g.body, g.etag
+	if g.gzipped != nil {
+		addVary(h, acceptEncodingHeader)
+		i := negotiate(r.Header.Get(acceptEncodingHeader), generatedEncodings, 3)
+		if i >= 0 && generatedEncodings[i].name != identityEncoding {
+			body = g.gzipped
+			h[contentEncodingHeader] = generatedEncodings[i].header
+			if len(r.Header[rangeHeader]) == 0 {
+				// ServeContent doesn't set the length of encoded content.
+				h.Set(contentLengthHeader, strconv.Itoa(len(body)))
+			}
+			if etag != "" {
+				etag = etag[:len(etag)-1] + etagSuffix(generatedEncodings[i].name) + `"`
+			}
+		}
+	}
+	if etag != "" {
+		h.Set("Etag", etag)
+	}
+	if status == http.StatusOK {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+		return
+	}
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set(contentLengthHeader, strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
+
+// serveErrorText responds with a plain text error message, as http.Error
+// would, but compressed if it's long enough for that to matter.
+func serveErrorText(w http.ResponseWriter, r *http.Request, msg string, status int) {
+	h := w.Header()
+	// Any headers describing a file that was to be served no longer apply.
+	delete(h, contentEncodingHeader)
+	delete(h, contentLengthHeader)
+	delete(h, "Etag")
+	delete(h, "Last-Modified")
+	newGenerated("text/plain; charset=utf-8", []byte(msg+"\n"), "").serve(w, r, status)
+}
@@ -0,0 +1,83 @@
+package gzipped
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerated(t *testing.T) {
+	body := strings.Repeat("a generated listing line\n", 100)
+	g := newGenerated("text/plain", []byte(body), `"tag"`)
+	if g.gzipped == nil {
+		t.Fatal("large body not compressed")
+	}
+	for _, tc := range []struct {
+		ae, encoding, etag string
+	}{
+		{"gzip", "gzip", `"tag-gz"`},
+		{"br, gzip;q=0.5", "gzip", `"tag-gz"`},
+		{"", "", `"tag"`},
+		{"br", "", `"tag"`},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.ae != "" {
+			req.Header.Set("Accept-Encoding", tc.ae)
+		}
+		rec := httptest.NewRecorder()
+		g.serve(rec, req, http.StatusOK)
+		h := rec.Header()
+		if h.Get("Content-Encoding") != tc.encoding || h.Get("Vary") != "Accept-Encoding" || h.Get("Etag") != tc.etag {
+			t.Errorf("%q: Content-Encoding %q, Vary %q, ETag %q", tc.ae, h.Get("Content-Encoding"), h.Get("Vary"), h.Get("Etag"))
+		}
+		if h.Get("Content-Length") != strconv.Itoa(rec.Body.Len()) {
+			t.Errorf("%q: Content-Length %q for %d bytes", tc.ae, h.Get("Content-Length"), rec.Body.Len())
+		}
+		var r io.Reader = rec.Body
+		if tc.encoding == "gzip" {
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = zr
+		}
+		if got, _ := io.ReadAll(r); string(got) != body {
+			t.Errorf("%q: wrong body", tc.ae)
+		}
+		req.Header.Set("If-None-Match", tc.etag)
+		rec = httptest.NewRecorder()
+		g.serve(rec, req, http.StatusOK)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("%q: If-None-Match status %d", tc.ae, rec.Code)
+		}
+	}
+
+	small := newGenerated("text/plain", []byte("short"), "")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	small.serve(rec, req, http.StatusNotFound)
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Vary") != "" || rec.Body.String() != "short" {
+		t.Errorf("small body: status %d, headers %v, body %q", rec.Code, rec.Header(), rec.Body.String())
+	}
+}
+
+func TestServeErrorText(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Encoding", "br")
+	rec.Header().Set("Etag", `"file"`)
+	serveErrorText(rec, req, strings.Repeat("long error message ", 50), http.StatusInternalServerError)
+	h := rec.Header()
+	if rec.Code != http.StatusInternalServerError || h.Get("Content-Encoding") != "gzip" || h.Get("Etag") != "" {
+		t.Errorf("status %d, Content-Encoding %q, ETag %q", rec.Code, h.Get("Content-Encoding"), h.Get("Etag"))
+	}
+	if h.Get("Content-Type") != "text/plain; charset=utf-8" || h.Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("Content-Type %q", h.Get("Content-Type"))
+	}
+}
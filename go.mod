@@ -1,5 +1,3 @@
 module github.com/lpar/gzipped/v2
 
-require github.com/kevinpollet/nego v0.0.0-20200324111829-b3061ca9dd9d
-
-go 1.18
+go 1.20
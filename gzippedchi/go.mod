@@ -0,0 +1,8 @@
+module github.com/lpar/gzipped/v2/gzippedchi
+
+go 1.20
+
+require (
+	github.com/go-chi/chi/v5 v5.0.12
+	github.com/lpar/gzipped/v2 v2.0.0-20261014184714-868b424a328c
+)
@@ -0,0 +1,61 @@
+// Package gzippedchi serves files with gzipped from chi routes, taking the
+// file's path from the route's wildcard so the route's prefix needn't be
+// stripped by hand. It's a separate module, so that the gzipped package
+// itself only depends on the standard library:
+//
+//	r := chi.NewRouter()
+//	r.Handle("/static/*", gzippedchi.FileServer(gzipped.Dir("./static")))
+//
+// A request for /static/css/site.css is then served /css/site.css.
+package gzippedchi
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lpar/gzipped/v2"
+)
+
+// FileServer returns a handler serving the files in root, with opts
+// applied, as gzipped.FileServer does, at the path matched by the chi
+// route's wildcard.
+func FileServer(root gzipped.FileSystem, opts ...gzipped.Option) http.Handler {
+	return Handler(gzipped.FileServer(root, opts...))
+}
+
+// Handler adapts h, which might be a gzipped.FileServer wrapped in other
+// middleware, to a chi route, so that h sees the part of the path matched
+// by the route's trailing "*" wildcard as r.URL.Path. Routes without a
+// wildcard leave the path alone.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil || !strings.HasSuffix(rctx.RoutePattern(), "*") {
+			h.ServeHTTP(w, r)
+			return
+		}
+		name := rctx.URLParam("*")
+		if r.URL.RawPath != "" {
+			// chi routes on the escaped path when there is one.
+			var err error
+			if name, err = url.PathUnescape(name); err != nil {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+		}
+		h.ServeHTTP(w, withPath(r, name))
+	})
+}
+
+// withPath returns a shallow copy of r for the file name.
+func withPath(r *http.Request, name string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	u := *r.URL
+	u.Path = "/" + strings.TrimPrefix(name, "/")
+	u.RawPath = ""
+	r2.URL = &u
+	return r2
+}
@@ -0,0 +1,60 @@
+// Package gzippedecho serves files with gzipped from Echo routes, taking the
+// file's path from the route's wildcard so the route's prefix needn't be
+// stripped by hand. It's a separate module, so that the gzipped package
+// itself only depends on the standard library:
+//
+//	e := echo.New()
+//	e.GET("/static/*", gzippedecho.FileServer(gzipped.Dir("./static")))
+//
+// A request for /static/css/site.css is then served /css/site.css. Routes
+// for HEAD, and OPTIONS if WithAnyMethod is used, have to be added too.
+package gzippedecho
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lpar/gzipped/v2"
+)
+
+// FileServer returns an Echo handler serving the files in root, with opts
+// applied, as gzipped.FileServer does, at the path matched by the route's
+// wildcard.
+func FileServer(root gzipped.FileSystem, opts ...gzipped.Option) echo.HandlerFunc {
+	return Handler(gzipped.FileServer(root, opts...))
+}
+
+// Handler adapts h, which might be a gzipped.FileServer wrapped in other
+// middleware, to an Echo route, so that h sees the part of the path matched
+// by the route's trailing "*" wildcard as r.URL.Path. Routes without a
+// wildcard leave the path alone.
+func Handler(h http.Handler) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		r := c.Request()
+		if !strings.HasSuffix(c.Path(), "*") {
+			h.ServeHTTP(c.Response(), r)
+			return nil
+		}
+		// Echo's parameters are escaped, as its static file middleware
+		// expects.
+		name, err := url.PathUnescape(c.Param("*"))
+		if err != nil {
+			return echo.ErrBadRequest
+		}
+		h.ServeHTTP(c.Response(), withPath(r, name))
+		return nil
+	}
+}
+
+// withPath returns a shallow copy of r for the file name.
+func withPath(r *http.Request, name string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	u := *r.URL
+	u.Path = "/" + strings.TrimPrefix(name, "/")
+	u.RawPath = ""
+	r2.URL = &u
+	return r2
+}
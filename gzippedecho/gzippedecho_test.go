@@ -0,0 +1,34 @@
+package gzippedecho
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lpar/gzipped/v2"
+)
+
+func TestFileServer(t *testing.T) {
+	r := echo.New()
+	r.GET("/static/*", FileServer(gzipped.Dir("../testdata")))
+	r.GET("/file.txt", FileServer(gzipped.Dir("../testdata")))
+	for _, tc := range []struct {
+		path, ae, enc string
+		status        int
+	}{
+		{"/static/file.txt", "gzip", "gzip", 200},
+		{"/static/app.js", "br", "br", 200},
+		{"/static/%66ile.txt", "", "", 200},
+		{"/static/nonexistent.txt", "", "", 404},
+		{"/file.txt", "gzip", "gzip", 200},
+	} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		r.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Header().Get("Content-Encoding") != tc.enc {
+			t.Errorf("GET %s: %d with Content-Encoding %q", tc.path, rr.Code, rr.Header().Get("Content-Encoding"))
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Package gzippedgin serves files with gzipped from Gin routes, taking the
+// file's path from the route's catch-all parameter so the route's prefix
+// needn't be stripped by hand. It's a separate module, so that the gzipped
+// package itself only depends on the standard library:
+//
+//	r := gin.Default()
+//	r.GET("/static/*filepath", gzippedgin.FileServer(gzipped.Dir("./static")))
+//	r.HEAD("/static/*filepath", gzippedgin.FileServer(gzipped.Dir("./static")))
+//
+// A request for /static/css/site.css is then served /css/site.css.
+package gzippedgin
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lpar/gzipped/v2"
+)
+
+// FileServer returns a Gin handler serving the files in root, with opts
+// applied, as gzipped.FileServer does, at the path matched by the route's
+// catch-all parameter.
+func FileServer(root gzipped.FileSystem, opts ...gzipped.Option) gin.HandlerFunc {
+	return Handler(gzipped.FileServer(root, opts...))
+}
+
+// Handler adapts h, which might be a gzipped.FileServer wrapped in other
+// middleware, to a Gin route, so that h sees the part of the path matched
+// by the route's catch-all parameter, whatever it's called, as r.URL.Path.
+// Routes without one leave the path alone.
+func Handler(h http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		i := strings.LastIndexByte(route, '*')
+		if i < 0 {
+			h.ServeHTTP(c.Writer, c.Request)
+			return
+		}
+		h.ServeHTTP(c.Writer, withPath(c.Request, c.Param(route[i+1:])))
+	}
+}
+
+// withPath returns a shallow copy of r for the file name.
+func withPath(r *http.Request, name string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	u := *r.URL
+	u.Path = "/" + strings.TrimPrefix(name, "/")
+	u.RawPath = ""
+	r2.URL = &u
+	return r2
+}
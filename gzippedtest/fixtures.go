@@ -0,0 +1,85 @@
+// Package gzippedtest provides utilities for testing code which uses package
+// gzipped: a conformance suite for FileSystem implementations, temporary
+// roots with generated variants, and assertions about which variant is
+// served and what it decodes to.
+package gzippedtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing/fstest"
+)
+
+// Fixture contents. The brotli data is precomputed, since the standard
+// library has no brotli encoder.
+const (
+	plainText  = "plain text, with gzip and brotli variants\n"
+	identText  = "only an uncompressed version of this one\n"
+	gzipOnly   = "only a gzip version of this one\n"
+	nestedText = "nested file with a gzip variant\n"
+)
+
+var brotliOnly = []byte{
+	0x8b, 0x05, 0x80, 0x62, 0x72, 0x6f, 0x74, 0x6c, 0x69, 0x20, 0x6f, 0x6e,
+	0x6c, 0x79, 0x0a, 0x03,
+}
+
+// Fixtures returns the files which TestFileSystem expects a FileSystem to
+// contain, keyed by slash-separated name without a leading slash.
+func Fixtures() map[string][]byte {
+	return map[string][]byte{
+		"plain.txt":              []byte(plainText),
+		"plain.txt.gz":           gzipBytes(plainText),
+		"plain.txt.br":           brotliOnly, // any valid brotli data will do
+		"identity.txt":           []byte(identText),
+		"gzonly.js.gz":           gzipBytes(gzipOnly),
+		"bronly.css.br":          brotliOnly,
+		"sub/dir/nested.html":    []byte(nestedText),
+		"sub/dir/nested.html.gz": gzipBytes(nestedText),
+	}
+}
+
+// FixtureNames returns the names of the fixture files, sorted.
+func FixtureNames() []string {
+	var names []string
+	for name := range Fixtures() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FixtureFS returns the fixtures as an in-memory fs.FS.
+func FixtureFS() fstest.MapFS {
+	m := make(fstest.MapFS)
+	for name, data := range Fixtures() {
+		m[name] = &fstest.MapFile{Data: data, Mode: 0o644}
+	}
+	return m
+}
+
+// WriteFixtures writes the fixtures into the directory dir, which is created
+// if necessary.
+func WriteFixtures(dir string) error {
+	for name, data := range Fixtures() {
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(full, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gzipBytes(s string) []byte {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	zw.Write([]byte(s))
+	zw.Close()
+	return b.Bytes()
+}
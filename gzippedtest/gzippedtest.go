@@ -0,0 +1,252 @@
+package gzippedtest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	fs2 "io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/lpar/gzipped/v2"
+)
+
+// TestFileSystem checks that fsys behaves the way FileServer expects a
+// FileSystem to behave. fsys must contain exactly the files written by
+// WriteFixtures, and nothing else.
+//
+// The checks cover consistency between Exists and Open, the errors reported
+// for missing files, the FileInfo returned by Stat, seeking, directories,
+// the optional DirReader, BatchExister and Stater interfaces if implemented,
+// and content negotiation through FileServer.
+func TestFileSystem(t *testing.T, fsys gzipped.FileSystem) {
+	t.Helper()
+	fixtures := Fixtures()
+
+	t.Run("Files", func(t *testing.T) {
+		for _, name := range FixtureNames() {
+			checkFile(t, fsys, "/"+name, fixtures[name])
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		for _, name := range []string{"/missing.txt", "/plain.txt.zst", "/sub/missing", "/missing/dir/file"} {
+			if fsys.Exists(name) {
+				t.Errorf("Exists(%q) = true for missing file", name)
+			}
+			f, err := fsys.Open(name)
+			if err == nil {
+				f.Close()
+				t.Errorf("Open(%q) succeeded for missing file", name)
+				continue
+			}
+			if !errors.Is(err, fs2.ErrNotExist) {
+				t.Errorf("Open(%q) error %v does not match fs.ErrNotExist", name, err)
+			}
+		}
+	})
+
+	t.Run("Directories", func(t *testing.T) {
+		for _, name := range []string{"/", "/sub", "/sub/dir"} {
+			f, err := fsys.Open(name)
+			if err != nil {
+				// A FileSystem needn't support opening directories.
+				continue
+			}
+			info, err := f.Stat()
+			f.Close()
+			if err != nil {
+				t.Errorf("Stat of directory %q: %v", name, err)
+				continue
+			}
+			if !info.IsDir() {
+				t.Errorf("Stat of directory %q reports IsDir false", name)
+			}
+		}
+	})
+
+	if dr, ok := fsys.(gzipped.DirReader); ok {
+		t.Run("DirReader", func(t *testing.T) {
+			checkDirReader(t, dr, fixtures)
+		})
+	}
+
+	if be, ok := fsys.(gzipped.BatchExister); ok {
+		t.Run("BatchExister", func(t *testing.T) {
+			names := []string{"/plain.txt", "/missing.txt", "/plain.txt.gz", "/sub/dir/nested.html.br", "/bronly.css.br"}
+			exists := make([]bool, len(names))
+			be.ExistsBatch(names, exists)
+			for i, name := range names {
+				if want := fsys.Exists(name); exists[i] != want {
+					t.Errorf("ExistsBatch reports %q exists %v, Exists reports %v", name, exists[i], want)
+				}
+			}
+		})
+	}
+
+	if st, ok := fsys.(gzipped.Stater); ok {
+		t.Run("Stater", func(t *testing.T) {
+			checkStater(t, st, fixtures)
+		})
+	}
+
+	t.Run("FileServer", func(t *testing.T) {
+		checkFileServer(t, fsys)
+	})
+}
+
+func checkStater(t *testing.T, st gzipped.Stater, fixtures map[string][]byte) {
+	t.Helper()
+	for _, name := range FixtureNames() {
+		info, err := st.Stat("/" + name)
+		if err != nil {
+			t.Errorf("Stat(%q): %v", "/"+name, err)
+			continue
+		}
+		if info.IsDir() || info.Name() != path.Base(name) || info.Size() != int64(len(fixtures[name])) {
+			t.Errorf("Stat(%q) reports %q, size %d, directory %v", "/"+name, info.Name(), info.Size(), info.IsDir())
+		}
+	}
+	if _, err := st.Stat("/missing.txt"); !errors.Is(err, fs2.ErrNotExist) {
+		t.Errorf("Stat of missing file: error %v does not match fs.ErrNotExist", err)
+	}
+}
+
+func checkFile(t *testing.T, fsys gzipped.FileSystem, name string, want []byte) {
+	t.Helper()
+	if !fsys.Exists(name) {
+		t.Errorf("Exists(%q) = false", name)
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		t.Errorf("Open(%q): %v", name, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Errorf("Stat of %q: %v", name, err)
+		return
+	}
+	if info.IsDir() {
+		t.Errorf("Stat of %q reports a directory", name)
+	}
+	if info.Name() != path.Base(name) {
+		t.Errorf("Stat of %q reports name %q", name, info.Name())
+	}
+	if info.Size() != int64(len(want)) {
+		t.Errorf("Stat of %q reports size %d, want %d", name, info.Size(), len(want))
+	}
+	got, err := io.ReadAll(f)
+	if err != nil {
+		t.Errorf("reading %q: %v", name, err)
+		return
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("content of %q doesn't match the fixture", name)
+	}
+	// Seeking is optional, since FileServer can stream files which don't
+	// support it, but a file which claims to seek must do it correctly.
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	got, err = io.ReadAll(f)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("content of %q after seeking to start doesn't match the fixture", name)
+	}
+	if len(want) > 4 {
+		if _, err := f.Seek(-4, io.SeekEnd); err == nil {
+			got, err = io.ReadAll(f)
+			if err != nil || !bytes.Equal(got, want[len(want)-4:]) {
+				t.Errorf("content of %q after seeking from end doesn't match the fixture", name)
+			}
+		}
+	}
+}
+
+func checkDirReader(t *testing.T, dr gzipped.DirReader, fixtures map[string][]byte) {
+	t.Helper()
+	dirs := map[string]map[string]bool{}
+	for name := range fixtures {
+		dir, file := path.Split("/" + name)
+		dir = strings.TrimSuffix(dir, "/")
+		if dir == "" {
+			dir = "/"
+		}
+		if dirs[dir] == nil {
+			dirs[dir] = map[string]bool{}
+		}
+		dirs[dir][file] = true
+	}
+	dirs["/"]["sub"] = true
+	dirs["/sub"] = map[string]bool{"dir": true}
+	for dir, want := range dirs {
+		entries, err := dr.ReadDir(dir)
+		if err != nil {
+			t.Errorf("ReadDir(%q): %v", dir, err)
+			continue
+		}
+		got := map[string]bool{}
+		for _, e := range entries {
+			got[e.Name()] = true
+			if !want[e.Name()] {
+				t.Errorf("ReadDir(%q) returned unexpected entry %q", dir, e.Name())
+			}
+		}
+		for name := range want {
+			if !got[name] {
+				t.Errorf("ReadDir(%q) is missing entry %q", dir, name)
+			}
+		}
+	}
+	if _, err := dr.ReadDir("/missing"); err == nil {
+		t.Errorf("ReadDir of a missing directory succeeded")
+	}
+}
+
+func checkFileServer(t *testing.T, fsys gzipped.FileSystem) {
+	t.Helper()
+	fixtures := Fixtures()
+	tests := []struct {
+		path, acceptEncoding string
+		status               int
+		encoding             string
+		body                 []byte
+	}{
+		{"/plain.txt", "", http.StatusOK, "", fixtures["plain.txt"]},
+		{"/plain.txt", "gzip", http.StatusOK, "gzip", fixtures["plain.txt.gz"]},
+		{"/plain.txt", "br, gzip", http.StatusOK, "br", fixtures["plain.txt.br"]},
+		{"/plain.txt", "gzip;q=1, br;q=0.5", http.StatusOK, "gzip", fixtures["plain.txt.gz"]},
+		{"/identity.txt", "gzip, br", http.StatusOK, "", fixtures["identity.txt"]},
+		{"/gzonly.js", "gzip", http.StatusOK, "gzip", fixtures["gzonly.js.gz"]},
+		{"/gzonly.js", "", http.StatusOK, "", []byte(gzipOnly)},
+		{"/bronly.css", "br", http.StatusOK, "br", fixtures["bronly.css.br"]},
+		{"/bronly.css", "gzip", http.StatusNotAcceptable, "", nil},
+		{"/sub/dir/nested.html", "gzip", http.StatusOK, "gzip", fixtures["sub/dir/nested.html.gz"]},
+		{"/sub/dir/../dir/nested.html", "", http.StatusOK, "", fixtures["sub/dir/nested.html"]},
+		{"/missing.txt", "gzip", http.StatusNotFound, "", nil},
+		{"/sub/", "", http.StatusNotFound, "", nil},
+	}
+	h := gzipped.FileServer(fsys)
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("GET %s with Accept-Encoding %q: status %d, want %d", tt.path, tt.acceptEncoding, rec.Code, tt.status)
+			continue
+		}
+		if got := rec.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("GET %s with Accept-Encoding %q: Content-Encoding %q, want %q", tt.path, tt.acceptEncoding, got, tt.encoding)
+		}
+		if tt.body != nil && !bytes.Equal(rec.Body.Bytes(), tt.body) {
+			t.Errorf("GET %s with Accept-Encoding %q: body doesn't match the fixture", tt.path, tt.acceptEncoding)
+		}
+	}
+}
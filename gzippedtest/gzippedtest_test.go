@@ -0,0 +1,35 @@
+package gzippedtest
+
+import (
+	"testing"
+
+	"github.com/lpar/gzipped/v2"
+)
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteFixtures(dir); err != nil {
+		t.Fatal(err)
+	}
+	TestFileSystem(t, gzipped.Dir(dir))
+}
+
+func TestFS(t *testing.T) {
+	TestFileSystem(t, gzipped.FS(FixtureFS()))
+}
+
+func TestHelpers(t *testing.T) {
+	dir := TempRoot(t, map[string][]byte{
+		"app.js":         []byte("console.log('hello, hello, hello, hello')\n"),
+		"only.css.gz":    gzipBytes("body { color: red }\n"),
+		"sub/index.html": []byte("<p>nested</p>\n"),
+	})
+	h := gzipped.FileServer(gzipped.Dir(dir))
+	AssertBody(t, AssertVariant(t, h, "/app.js", "gzip", "gzip"), []byte("console.log('hello, hello, hello, hello')\n"))
+	AssertBody(t, AssertVariant(t, h, "/app.js", "", "identity"), []byte("console.log('hello, hello, hello, hello')\n"))
+	AssertBody(t, AssertVariant(t, h, "/only.css", "", ""), []byte("body { color: red }\n"))
+	AssertBody(t, AssertVariant(t, h, "/sub/index.html", "br, gzip", "gzip"), []byte("<p>nested</p>\n"))
+	if rec := Get(h, "/only.css.gz.gz", "gzip"); rec.Code != 404 {
+		t.Errorf("variant of a variant served with status %d", rec.Code)
+	}
+}
@@ -0,0 +1,112 @@
+package gzippedtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TempRoot writes files, keyed by slash-separated name without a leading
+// slash, into a temporary directory removed when the test ends, and returns
+// the directory for gzipped.Dir. A gzip variant is written alongside each
+// file, except files whose names end in .gz, .br or .zst, which are written
+// as they are, so a test can supply its own variants or leave an original
+// out. The standard library has no brotli or zstd encoder, so those
+// variants have to be supplied.
+func TempRoot(t testing.TB, files map[string][]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	write := func(name string, data []byte) {
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, data := range files {
+		write(name, data)
+		if !hasVariantExt(name) {
+			write(name+".gz", gzipBytes(string(data)))
+		}
+	}
+	return dir
+}
+
+func hasVariantExt(name string) bool {
+	for _, ext := range []string{".gz", ".br", ".zst"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get sends h a GET request for path with the Accept-Encoding header
+// acceptEncoding, if it isn't empty, and returns the response.
+func Get(h http.Handler, path, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// AssertVariant checks that h responds to a GET request for path with the
+// Accept-Encoding header acceptEncoding with 200 OK and the content-coding
+// encoding, which is "identity" or "" for none, and returns the response.
+func AssertVariant(t testing.TB, h http.Handler, path, acceptEncoding, encoding string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := Get(h, path, acceptEncoding)
+	if encoding == "identity" {
+		encoding = ""
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET %s with Accept-Encoding %q: status %d, want 200", path, acceptEncoding, rec.Code)
+	} else if got := rec.Header().Get("Content-Encoding"); got != encoding {
+		t.Errorf("GET %s with Accept-Encoding %q: Content-Encoding %q, want %q", path, acceptEncoding, got, encoding)
+	}
+	return rec
+}
+
+// DecodedBody returns the body of rec with its Content-Encoding removed. It
+// handles gzip and identity, which the standard library can decode.
+func DecodedBody(rec *httptest.ResponseRecorder) ([]byte, error) {
+	switch enc := rec.Header().Get("Content-Encoding"); enc {
+	case "", "identity":
+		return rec.Body.Bytes(), nil
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	default:
+		return nil, fmt.Errorf("gzippedtest: can't decode content-coding %q", enc)
+	}
+}
+
+// AssertBody checks that the body of rec, once decoded, is want, whichever
+// encoding it was sent with.
+func AssertBody(t testing.TB, rec *httptest.ResponseRecorder, want []byte) {
+	t.Helper()
+	got, err := DecodedBody(rec)
+	if err != nil {
+		t.Errorf("decoding body: %v", err)
+		return
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("decoded body %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+package gzipped
+
+import "net/http"
+
+// WithHeaders calls set for each file about to be served, with the file's
+// path and the response headers, so that headers such as
+// Content-Security-Policy, Access-Control-Allow-Origin or
+// X-Content-Type-Options can be added:
+//
+//	gzipped.WithHeaders(func(fpath string, h http.Header) {
+//		h.Set("X-Content-Type-Options", "nosniff")
+//		if strings.HasPrefix(fpath, "/fonts/") {
+//			h.Set("Access-Control-Allow-Origin", "*")
+//		}
+//	})
+//
+// The path is that of the original file, even when a compressed variant or
+// translation is served. set is called for 304 Not Modified responses as well
+// as full ones, but not for errors. The option can be given more than once,
+// and the functions are called in order.
+func WithHeaders(set func(fpath string, h http.Header)) Option {
+	return func(f *fileHandler) {
+		f.headers = append(f.headers, set)
+	}
+}
+
+// setHeaders calls the WithHeaders functions for the file fpath.
+func (f *fileHandler) setHeaders(w http.ResponseWriter, r *http.Request, fpath string) {
+	fpath = f.untranslated(fpath)
+	h := w.Header()
+	for _, set := range f.headers {
+		f.callback(r, "WithHeaders", func() {
+			set(fpath, h)
+		})
+	}
+}
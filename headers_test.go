@@ -0,0 +1,40 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithHeaders(t *testing.T) {
+	var paths []string
+	h := FileServer(Dir("./testdata/"),
+		WithHeaders(func(fpath string, h http.Header) {
+			paths = append(paths, fpath)
+			h.Set("X-Content-Type-Options", "nosniff")
+		}),
+		WithHeaders(func(fpath string, h http.Header) {
+			if fpath == "/app.js" {
+				h.Set("Access-Control-Allow-Origin", "*")
+			}
+		}))
+	for _, tc := range []struct {
+		path, ae, cors string
+		status         int
+	}{
+		{"/app.js", "br", "*", 200},
+		{"/file.txt", "gzip", "", 200},
+		{"/nonexistent.txt", "", "", 404},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Header().Get("X-Content-Type-Options") != "nosniff" || rr.Header().Get("Access-Control-Allow-Origin") != tc.cors {
+			t.Errorf("GET %s: %d with headers %v", tc.path, rr.Code, rr.Header())
+		}
+	}
+	if len(paths) != 2 || paths[0] != "/app.js" || paths[1] != "/file.txt" {
+		t.Errorf("header function called for %q", paths)
+	}
+}
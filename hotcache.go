@@ -0,0 +1,139 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HotCacheConfig configures the hot file cache enabled by WithHotCache.
+type HotCacheConfig struct {
+	// Files is the number of most frequently requested files to keep in
+	// memory.
+	Files int
+	// MaxFileSize is the size in bytes of the largest file which will be
+	// kept in memory. The default is 1 MiB.
+	MaxFileSize int64
+	// Interval is how often hit counts are ranked and the cache contents
+	// replaced. The default is one minute.
+	Interval time.Duration
+}
+
+// WithHotCache counts how often each file is served, and periodically loads
+// the most frequently requested files into memory, so that the cache adapts
+// to real traffic rather than needing a list of files to pre-warm. Counts
+// are per variant, so it's the encodings actually being sent which get
+// cached.
+//
+// Cached files are reloaded if they have changed each time the cache is
+// refreshed, so changes on disk may take up to Interval to be seen.
+func WithHotCache(cfg HotCacheConfig) Option {
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = 1 << 20
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	return func(f *fileHandler) {
+		if f.mem == nil {
+			f.mem = &memCache{}
+		}
+		f.hot = &hotTracker{cfg: cfg, hits: make(map[string]uint64)}
+	}
+}
+
+// hotTracker counts file hits and promotes the hottest files to the memory
+// cache.
+type hotTracker struct {
+	cfg     HotCacheConfig
+	mu      sync.Mutex
+	hits    map[string]uint64
+	next    time.Time
+	running int32
+	cached  map[string]bool // files currently in the cache because they're hot
+	wg      sync.WaitGroup  // for tests to wait for promotion to finish
+}
+
+// The number of distinct files which will be counted per interval, as a
+// multiple of the number of files to cache, so that scans of many distinct
+// paths can't grow the count table without bound.
+const hotTrackFactor = 16
+
+// hit records a request for the named file, and starts a refresh of the
+// memory cache in the background if one is due.
+func (h *hotTracker) hit(f *fileHandler, name string) {
+	now := time.Now()
+	var counts map[string]uint64
+	h.mu.Lock()
+	if _, ok := h.hits[name]; ok || len(h.hits) < h.cfg.Files*hotTrackFactor+1 {
+		h.hits[name]++
+	}
+	if !now.Before(h.next) && atomic.CompareAndSwapInt32(&h.running, 0, 1) {
+		h.next = now.Add(h.cfg.Interval)
+		counts = h.hits
+		h.hits = make(map[string]uint64, len(counts))
+	}
+	h.mu.Unlock()
+	if counts != nil {
+		h.wg.Add(1)
+		go h.promote(f, counts)
+	}
+}
+
+// promote replaces the hot files in the memory cache with the files which
+// were requested most often in counts. Files in the cache for other reasons
+// are left alone.
+func (h *hotTracker) promote(f *fileHandler, counts map[string]uint64) {
+	defer h.wg.Done()
+	defer atomic.StoreInt32(&h.running, 0)
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	cached := make(map[string]bool, h.cfg.Files)
+	for _, name := range names {
+		if len(cached) >= h.cfg.Files {
+			break
+		}
+		// Keep the bytes we already have if the file hasn't changed.
+		if old, ok := f.mem.get(name); ok && unchanged(f.root, name, old.info) {
+			cached[name] = true
+			continue
+		}
+		e, err := f.mem.load(f.root, name, h.cfg.MaxFileSize)
+		if err != nil {
+			continue
+		}
+		if f.mem.add(name, e) {
+			cached[name] = true
+		}
+	}
+	for name := range h.cached {
+		if !cached[name] {
+			f.mem.remove(name)
+		}
+	}
+	h.cached = cached
+}
+
+// unchanged reports whether the named file still has the size and
+// modification time recorded in info.
+func unchanged(root FileSystem, name string, info os.FileInfo) bool {
+	file, err := root.Open(name)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	cur, err := file.Stat()
+	return err == nil && cur.ModTime().Equal(info.ModTime()) && cur.Size() == info.Size()
+}
@@ -0,0 +1,92 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingFS counts calls to Open and Exists for each file name.
+type countingFS struct {
+	FileSystem
+	mu    sync.Mutex
+	opens map[string]int
+}
+
+func newCountingFS(root FileSystem) *countingFS {
+	return &countingFS{FileSystem: root, opens: make(map[string]int)}
+}
+
+func (c *countingFS) Open(name string) (http.File, error) {
+	c.mu.Lock()
+	c.opens[name]++
+	c.mu.Unlock()
+	return c.FileSystem.Open(name)
+}
+
+func (c *countingFS) Exists(name string) bool {
+	c.mu.Lock()
+	c.opens["exists:"+name]++
+	c.mu.Unlock()
+	return c.FileSystem.Exists(name)
+}
+
+// count returns the number of times name was opened. Prefix the name with
+// "exists:" to get the number of Exists calls instead.
+func (c *countingFS) count(name string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.opens[name]
+}
+
+func TestHotCache(t *testing.T) {
+	root := newCountingFS(Dir("./testdata/"))
+	fs := FileServer(root, WithHotCache(HotCacheConfig{Files: 1, Interval: time.Hour})).(*fileHandler)
+	get := func(path, ae string) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", ae)
+		fs.ServeHTTP(rr, req)
+		if rr.Code != 200 {
+			t.Fatalf("GET %s returned %d", path, rr.Code)
+		}
+	}
+
+	// The first request triggers a ranking of one hit, which promotes the
+	// variant actually served.
+	get("/file.txt", "gzip")
+	fs.hot.wg.Wait()
+	if _, ok := fs.mem.get("/file.txt.gz"); !ok {
+		t.Fatal("hottest variant wasn't promoted to the memory cache")
+	}
+	if _, ok := fs.mem.get("/file.txt"); ok {
+		t.Error("identity variant was cached without being requested")
+	}
+	before := root.count("/file.txt.gz")
+	for i := 0; i < 5; i++ {
+		get("/file.txt", "gzip")
+	}
+	if n := root.count("/file.txt.gz"); n != before {
+		t.Errorf("cached file was opened %d more times", n-before)
+	}
+
+	// When the next interval comes round, the ranking changes.
+	for i := 0; i < 10; i++ {
+		get("/file2.txt", "gzip")
+	}
+	fs.hot.mu.Lock()
+	fs.hot.next = time.Time{}
+	fs.hot.mu.Unlock()
+	get("/file2.txt", "gzip")
+	fs.hot.wg.Wait()
+	if _, ok := fs.mem.get("/file2.txt"); !ok {
+		t.Error("new hottest file wasn't promoted")
+	}
+	if _, ok := fs.mem.get("/file.txt.gz"); ok {
+		t.Error("cold file wasn't evicted")
+	}
+}
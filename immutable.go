@@ -0,0 +1,52 @@
+package gzipped
+
+import (
+	"path"
+	"regexp"
+)
+
+// DefaultFingerprint is the pattern WithImmutableAssets recognizes
+// content-hashed file names by when it's given none: eight or more
+// lower-case hex digits between dots, as in app.3f9c2a1b.js.
+const DefaultFingerprint = `\.[0-9a-f]{8,}\.`
+
+// Cache-Control value for fingerprinted files, which can be cached for a
+// year without ever being revalidated, since a change of content is a
+// change of name.
+var immutableCacheControl = []string{"public, max-age=31536000, immutable"}
+
+// immutableAssets is the configuration of WithImmutableAssets.
+type immutableAssets struct {
+	fingerprint *regexp.Regexp
+	otherwise   []string // nil for no header
+}
+
+// WithImmutableAssets sends "Cache-Control: public, max-age=31536000,
+// immutable" with files whose names are content-hashed, as recognized by
+// fingerprint, or DefaultFingerprint if it's nil, and otherwise, if it's not
+// empty, with files which WithCacheControl has no rule for:
+//
+//	gzipped.WithImmutableAssets(nil, "public, max-age=300")
+//
+// The pattern is matched against the last element of the request's path,
+// not the name of the file served, so a logical name served a hashed file
+// by WithAssetMap isn't taken to be immutable. Fingerprinted names take
+// precedence over WithCacheControl rules.
+func WithImmutableAssets(fingerprint *regexp.Regexp, otherwise string) Option {
+	if fingerprint == nil {
+		fingerprint = regexp.MustCompile(DefaultFingerprint)
+	}
+	ia := &immutableAssets{fingerprint: fingerprint}
+	if otherwise != "" {
+		ia.otherwise = []string{otherwise}
+	}
+	return func(f *fileHandler) {
+		f.immutable = ia
+	}
+}
+
+// fingerprinted reports whether the URL path upath names a content-hashed
+// file.
+func (ia *immutableAssets) fingerprinted(upath string) bool {
+	return ia.fingerprint.MatchString(path.Base(upath))
+}
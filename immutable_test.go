@@ -0,0 +1,45 @@
+package gzipped
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestWithImmutableAssets(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app.3f9c2a1b.js", "app-BxK3nq2a.js", "page.html", "logo.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("content"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		opts   []Option
+		path   string
+		expect string
+	}{
+		{[]Option{WithImmutableAssets(nil, "public, max-age=300")}, "/app.3f9c2a1b.js", "public, max-age=31536000, immutable"},
+		{[]Option{WithImmutableAssets(nil, "public, max-age=300")}, "/page.html", "public, max-age=300"},
+		{[]Option{WithImmutableAssets(nil, "")}, "/page.html", ""},
+		{[]Option{WithImmutableAssets(nil, "")}, "/app-BxK3nq2a.js", ""},
+		{[]Option{WithImmutableAssets(regexp.MustCompile(`-[0-9A-Za-z_-]{8}\.`), "")}, "/app-BxK3nq2a.js", "public, max-age=31536000, immutable"},
+		// WithCacheControl rules come between the two.
+		{[]Option{WithImmutableAssets(nil, "no-cache"), WithCacheControl("*.png", "max-age=3600")}, "/logo.png", "max-age=3600"},
+		{[]Option{WithImmutableAssets(nil, "no-cache"), WithCacheControl("*.js", "max-age=3600")}, "/app.3f9c2a1b.js", "public, max-age=31536000, immutable"},
+		{[]Option{WithImmutableAssets(nil, "no-cache"), WithCacheControl("*.png", "max-age=3600")}, "/page.html", "no-cache"},
+	} {
+		rr := httptest.NewRecorder()
+		FileServer(Dir(dir), tc.opts...).ServeHTTP(rr, httptest.NewRequest("GET", tc.path, nil))
+		if got := rr.Header().Get("Cache-Control"); rr.Code != 200 || got != tc.expect {
+			t.Errorf("GET %s: %d with Cache-Control %q, expected %q", tc.path, rr.Code, got, tc.expect)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	FileServer(Dir(dir), WithImmutableAssets(nil, "no-cache")).ServeHTTP(rr, httptest.NewRequest("GET", "/missing.3f9c2a1b.js", nil))
+	if rr.Code != 404 || rr.Header().Get("Cache-Control") != "" {
+		t.Errorf("missing file: %d with Cache-Control %q", rr.Code, rr.Header().Get("Cache-Control"))
+	}
+}
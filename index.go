@@ -0,0 +1,229 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IndexedVariant describes one variant of a file in an Index.
+type IndexedVariant struct {
+	Name     string    // name of the variant file
+	Encoding string    // content encoding, "identity" for the original
+	Size     int64     // size in bytes
+	ModTime  time.Time // modification time
+	SHA256   [32]byte  // hash of the variant's content
+}
+
+// indexEntry holds the variants of a single logical file.
+type indexEntry struct {
+	variants []IndexedVariant
+	ctype    string // content type from a manifest, if it gave one
+}
+
+type indexSnapshot struct {
+	files map[string]*indexEntry
+}
+
+// Index is an in-memory snapshot of every file in a FileSystem and its
+// variants, built by walking the whole tree. Passed to FileServer with
+// WithIndex, it answers which variants of a file exist without any
+// FileSystem access, which suits read-only deployments and embedded files.
+//
+// The snapshot is immutable; call Rebuild to take a new one when the files
+// change. An Index is safe for concurrent use.
+type Index struct {
+	root     FileSystem
+	manifest string       // the manifest the index is loaded from, if any
+	current  atomic.Value // *indexSnapshot
+	mu       sync.Mutex   // serializes rebuilds
+}
+
+// BuildIndex walks root, which must implement DirReader, and returns an
+// index of its files. Every file is read in full to compute its hash.
+func BuildIndex(root FileSystem) (*Index, error) {
+	idx := &Index{root: root}
+	if err := idx.Rebuild(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Rebuild walks the FileSystem again and replaces the index's contents, or
+// for an index from LoadManifest, rereads the manifest. Requests already
+// being served carry on using the old snapshot. If the walk fails, the old
+// snapshot is kept and the error returned.
+func (idx *Index) Rebuild() error {
+	if idx.manifest != "" {
+		snap, err := readManifest(idx.root, idx.manifest)
+		if err != nil {
+			return err
+		}
+		idx.current.Store(snap)
+		return nil
+	}
+	dr, ok := idx.root.(DirReader)
+	if !ok {
+		return errors.New("gzipped: FileSystem can't list directories, so can't be indexed")
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	snap := &indexSnapshot{files: make(map[string]*indexEntry)}
+	if err := idx.walk(dr, "/", snap); err != nil {
+		return err
+	}
+	for _, e := range snap.files {
+		sort.Slice(e.variants, func(i, j int) bool {
+			return e.variants[i].Name < e.variants[j].Name
+		})
+	}
+	idx.current.Store(snap)
+	return nil
+}
+
+func (idx *Index) walk(dr DirReader, dir string, snap *indexSnapshot) error {
+	entries, err := dr.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, de := range entries {
+		name := path.Join(dir, de.Name())
+		if de.IsDir() {
+			if err := idx.walk(dr, name, snap); err != nil {
+				return err
+			}
+			continue
+		}
+		v, err := idx.hashFile(name)
+		if err != nil {
+			return err
+		}
+		// The file is a variant of the logical file found by removing an
+		// encoding extension, and is also the identity variant of itself.
+		for _, enc := range preferredEncodings {
+			logical := name
+			if enc.ext != "" {
+				if !strings.HasSuffix(name, enc.ext) {
+					continue
+				}
+				logical = strings.TrimSuffix(name, enc.ext)
+			}
+			e := snap.files[logical]
+			if e == nil {
+				e = &indexEntry{}
+				snap.files[logical] = e
+			}
+			v.Encoding = enc.name
+			e.variants = append(e.variants, v)
+		}
+	}
+	return nil
+}
+
+func (idx *Index) hashFile(name string) (IndexedVariant, error) {
+	file, err := idx.root.Open(name)
+	if err != nil {
+		return IndexedVariant{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return IndexedVariant{}, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return IndexedVariant{}, err
+	}
+	v := IndexedVariant{Name: name, Size: info.Size(), ModTime: info.ModTime()}
+	copy(v.SHA256[:], h.Sum(nil))
+	return v, nil
+}
+
+func (idx *Index) snapshot() *indexSnapshot {
+	return idx.current.Load().(*indexSnapshot)
+}
+
+// Variants returns the variants of the logical file fpath recorded in the
+// index, or nil if there are none.
+func (idx *Index) Variants(fpath string) []IndexedVariant {
+	if e, ok := idx.snapshot().files[fpath]; ok {
+		return append([]IndexedVariant(nil), e.variants...)
+	}
+	return nil
+}
+
+// Len returns the number of logical files in the index.
+func (idx *Index) Len() int {
+	return len(idx.snapshot().files)
+}
+
+// available returns the set of encodings in encs for which the index has a
+// variant of fpath.
+func (idx *Index) available(fpath string, encs []encoding) encodingSet {
+	e, ok := idx.snapshot().files[fpath]
+	if !ok {
+		return 0
+	}
+	var available encodingSet
+	for _, v := range e.variants {
+		if i := indexOfEncoding(encs, v.Encoding); i >= 0 {
+			available |= 1 << uint(i)
+		}
+	}
+	return available
+}
+
+// modTime returns the modification time of the variant of fpath with the
+// given encoding, if it's in the index.
+func (idx *Index) modTime(fpath, encoding string) (time.Time, bool) {
+	v, ok := idx.variant(fpath, encoding)
+	return v.ModTime, ok
+}
+
+// stat returns the size and modification time of the variant of fpath with
+// the given encoding, if it's in the index.
+func (idx *Index) stat(fpath, encoding string) (int64, time.Time, bool) {
+	v, ok := idx.variant(fpath, encoding)
+	return v.Size, v.ModTime, ok
+}
+
+// contentType returns the content type the manifest the index was loaded
+// from gives fpath, or "" if it gives none.
+func (idx *Index) contentType(fpath string) string {
+	if e, ok := idx.snapshot().files[fpath]; ok {
+		return e.ctype
+	}
+	return ""
+}
+
+// variant returns the variant of fpath with the given encoding, if it's in
+// the index.
+func (idx *Index) variant(fpath, encoding string) (IndexedVariant, bool) {
+	if e, ok := idx.snapshot().files[fpath]; ok {
+		for _, v := range e.variants {
+			if v.Encoding == encoding {
+				return v, true
+			}
+		}
+	}
+	return IndexedVariant{}, false
+}
+
+// WithIndex makes the handler use idx to find which variants of each file
+// exist, rather than asking the FileSystem. Files which aren't in the index
+// are opened from the FileSystem as usual, but without any of their
+// variants, so the index must be rebuilt for files added later to be served
+// compressed.
+func WithIndex(idx *Index) Option {
+	return func(f *fileHandler) {
+		f.index = idx
+	}
+}
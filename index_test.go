@@ -0,0 +1,116 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a/site.css", "body{}")
+	write("a/site.css.br", "not really brotli")
+
+	root := newCountingFS(Dir(dir))
+	if _, err := BuildIndex(root); err == nil {
+		t.Error("indexed a FileSystem which can't list directories")
+	}
+	idx, err := BuildIndex(Dir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := idx.Variants("/a/site.css")
+	if len(vs) != 2 || vs[0].Name != "/a/site.css" || vs[1].Encoding != "br" {
+		t.Fatalf("indexed variants %+v", vs)
+	}
+	if vs[0].SHA256 != sha256.Sum256([]byte("body{}")) || vs[0].Size != 6 {
+		t.Errorf("wrong size or hash for %s", vs[0].Name)
+	}
+
+	root.FileSystem = Dir(dir)
+	h := FileServer(root, WithIndex(idx))
+	get := func(ae string) string {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/a/site.css", nil)
+		req.Header.Set("Accept-Encoding", ae)
+		h.ServeHTTP(rr, req)
+		return rr.Header().Get("Content-Encoding")
+	}
+	if enc := get("br"); enc != "br" {
+		t.Errorf("served encoding %q, expected br", enc)
+	}
+
+	// New variants aren't seen until the index is rebuilt.
+	write("a/site.css.gz", "not really gzip")
+	if enc := get("gzip"); enc != "" {
+		t.Errorf("served unindexed encoding %q", enc)
+	}
+	if err := idx.Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+	if enc := get("gzip"); enc != "gzip" {
+		t.Errorf("served encoding %q after rebuild, expected gzip", enc)
+	}
+
+	// Unindexed files are served from the FileSystem without variants.
+	write("late.txt", "added late")
+	write("late.txt.gz", "not really gzip")
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/late.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "added late" || rr.Header().Get("Content-Encoding") != "" {
+		t.Errorf("unindexed file gave %d %q %q", rr.Code, rr.Header().Get("Content-Encoding"), rr.Body.String())
+	}
+	for k := range root.opens {
+		if strings.HasPrefix(k, "exists:") {
+			t.Errorf("indexed handler called %s", k)
+		}
+	}
+}
+
+func TestIndexETagPrecheck(t *testing.T) {
+	idx, err := BuildIndex(Dir("./testdata/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := newCountingFS(Dir("./testdata/"))
+	h := FileServer(root, WithIndex(idx), WithETags())
+	get := func(inm string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/file.txt", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("If-None-Match", inm)
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	etag := get("").Header().Get("Etag")
+	opens := root.count("/file.txt.gz")
+	if opens == 0 {
+		t.Fatal("gzip variant wasn't opened")
+	}
+	rr := get(etag)
+	if rr.Code != http.StatusNotModified || rr.Header().Get("Etag") != etag || rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("revalidation got %d, ETag %s, Vary %q", rr.Code, rr.Header().Get("Etag"), rr.Header().Get("Vary"))
+	}
+	if root.count("/file.txt.gz") != opens {
+		t.Error("file opened to answer If-None-Match")
+	}
+	if rr := get(`"stale"`); rr.Code != http.StatusOK {
+		t.Errorf("stale ETag got %d", rr.Code)
+	}
+}
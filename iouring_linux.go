@@ -0,0 +1,517 @@
+//go:build linux && gzipped_iouring
+
+package gzipped
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// This file implements an experimental FileSystem which performs its file
+// operations through a Linux io_uring, so that the opens, stats and reads
+// needed to serve a request are submitted to the kernel in batches rather
+// than as individual system calls. It's only built with the gzipped_iouring
+// build tag.
+
+// io_uring constants, from linux/io_uring.h.
+const (
+	sysIOURingSetup = 425
+	sysIOURingEnter = 426
+
+	iouringOffSQRing = 0
+	iouringOffCQRing = 0x8000000
+	iouringOffSQEs   = 0x10000000
+
+	iouringFeatSingleMmap = 1 << 0
+	iouringEnterGetEvents = 1 << 0
+	iouringOpOpenat       = 18
+	iouringOpClose        = 19
+	iouringOpStatx        = 21
+	iouringOpRead         = 22
+	atFdCwd               = -100
+	statxBasicStats       = 0x7ff
+	sqeSize               = 64
+	cqeSize               = 16
+	statxSize             = 256
+	defaultURingEntries   = 64
+	uringOpenFlags        = syscall.O_RDONLY | syscall.O_CLOEXEC
+)
+
+type iouringSQRingOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type iouringCQRingOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type iouringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFd uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  iouringSQRingOffsets
+	cqOff                                                                  iouringCQRingOffsets
+}
+
+// sqe is a submission queue entry.
+type sqe struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	opFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+// cqe is a completion queue entry.
+type cqe struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// uring is a single io_uring instance. Operations are submitted and waited
+// for synchronously, one batch at a time.
+type uring struct {
+	mu      sync.Mutex
+	fd      int
+	sqRing  []byte
+	cqRing  []byte
+	sqeMem  []byte
+	params  iouringParams
+	entries uint32
+	scratch []byte
+}
+
+func newURing(entries uint32) (*uring, error) {
+	r := &uring{}
+	fd, _, errno := syscall.Syscall(sysIOURingSetup, uintptr(entries), uintptr(unsafe.Pointer(&r.params)), 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("io_uring_setup", errno)
+	}
+	r.fd = int(fd)
+	p := &r.params
+	r.entries = p.sqEntries
+	sqLen := int(p.sqOff.array + p.sqEntries*4)
+	cqLen := int(p.cqOff.cqes + p.cqEntries*cqeSize)
+	if p.features&iouringFeatSingleMmap != 0 && cqLen > sqLen {
+		sqLen = cqLen
+	}
+	var err error
+	r.sqRing, err = syscall.Mmap(r.fd, iouringOffSQRing, sqLen, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		r.close()
+		return nil, os.NewSyscallError("mmap", err)
+	}
+	if p.features&iouringFeatSingleMmap != 0 {
+		r.cqRing = r.sqRing
+	} else {
+		r.cqRing, err = syscall.Mmap(r.fd, iouringOffCQRing, cqLen, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+		if err != nil {
+			r.close()
+			return nil, os.NewSyscallError("mmap", err)
+		}
+	}
+	r.sqeMem, err = syscall.Mmap(r.fd, iouringOffSQEs, int(p.sqEntries*sqeSize), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		r.close()
+		return nil, os.NewSyscallError("mmap", err)
+	}
+	return r, nil
+}
+
+func (r *uring) close() error {
+	if r.sqeMem != nil {
+		syscall.Munmap(r.sqeMem)
+	}
+	if r.cqRing != nil && &r.cqRing[0] != &r.sqRing[0] {
+		syscall.Munmap(r.cqRing)
+	}
+	if r.sqRing != nil {
+		syscall.Munmap(r.sqRing)
+	}
+	return syscall.Close(r.fd)
+}
+
+func ringWord(mem []byte, off uint32) *uint32 {
+	return (*uint32)(unsafe.Pointer(&mem[off]))
+}
+
+// uringReq is one operation to be carried out through the ring.
+type uringReq struct {
+	opcode uint8
+	fd     int32  // file descriptor, or directory for path-based operations
+	path   string // path for openat and statx
+	flags  uint32 // open flags
+	buf    []byte // destination for reads
+	off    int64  // file offset for reads
+	res    int32  // result, following the kernel convention of -errno on failure
+	stat   [statxSize]byte
+}
+
+// The largest read submitted as a single operation. Longer reads return a
+// short count, which io.Reader allows.
+const maxURingRead = 1 << 20
+
+// run carries out the requests and waits for all of them to complete.
+func (r *uring) run(reqs []uringReq) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(reqs) > 0 {
+		n := len(reqs)
+		if n > int(r.entries) {
+			n = int(r.entries)
+		}
+		if err := r.runBatch(reqs[:n]); err != nil {
+			return err
+		}
+		reqs = reqs[n:]
+	}
+	return nil
+}
+
+// scratchFor grows the scratch buffer to at least n bytes. All memory the
+// kernel reads or writes is in the scratch buffer, which lives on the heap
+// and so never moves, rather than in caller-supplied memory which might be
+// on a goroutine stack.
+func (r *uring) scratchFor(n int) []byte {
+	if len(r.scratch) < n {
+		r.scratch = make([]byte, n)
+	}
+	return r.scratch
+}
+
+func align8(n int) int {
+	return (n + 7) &^ 7
+}
+
+func (r *uring) runBatch(reqs []uringReq) error {
+	// Lay out paths, statx buffers and read buffers in the scratch area.
+	offs := make([]int, len(reqs))
+	size := 0
+	for i := range reqs {
+		offs[i] = size
+		switch reqs[i].opcode {
+		case iouringOpOpenat:
+			size += align8(len(reqs[i].path) + 1)
+		case iouringOpStatx:
+			size += statxSize + align8(len(reqs[i].path)+1)
+		case iouringOpRead:
+			if len(reqs[i].buf) > maxURingRead {
+				reqs[i].buf = reqs[i].buf[:maxURingRead]
+			}
+			size += align8(len(reqs[i].buf))
+		}
+	}
+	scratch := r.scratchFor(size + 8)
+	addr := func(off int) uint64 {
+		return uint64(uintptr(unsafe.Pointer(&scratch[off])))
+	}
+
+	p := &r.params
+	sqTail := ringWord(r.sqRing, p.sqOff.tail)
+	sqMask := *ringWord(r.sqRing, p.sqOff.ringMask)
+	tail := atomic.LoadUint32(sqTail)
+	for i := range reqs {
+		req := &reqs[i]
+		e := sqe{opcode: req.opcode, fd: req.fd, userData: uint64(i)}
+		switch req.opcode {
+		case iouringOpOpenat:
+			copy(scratch[offs[i]:], req.path)
+			scratch[offs[i]+len(req.path)] = 0
+			e.addr = addr(offs[i])
+			e.opFlags = req.flags
+		case iouringOpStatx:
+			po := offs[i] + statxSize
+			copy(scratch[po:], req.path)
+			scratch[po+len(req.path)] = 0
+			e.addr = addr(po)
+			e.len = statxBasicStats
+			e.off = addr(offs[i])
+		case iouringOpRead:
+			e.addr = addr(offs[i])
+			e.len = uint32(len(req.buf))
+			e.off = uint64(req.off)
+		}
+		idx := tail & sqMask
+		*(*sqe)(unsafe.Pointer(&r.sqeMem[idx*sqeSize])) = e
+		*ringWord(r.sqRing, p.sqOff.array+idx*4) = idx
+		tail++
+	}
+	atomic.StoreUint32(sqTail, tail)
+
+	cqHead := ringWord(r.cqRing, p.cqOff.head)
+	cqTail := ringWord(r.cqRing, p.cqOff.tail)
+	cqMask := *ringWord(r.cqRing, p.cqOff.ringMask)
+	toSubmit, done := len(reqs), 0
+	for done < len(reqs) {
+		_, _, errno := syscall.Syscall6(sysIOURingEnter, uintptr(r.fd), uintptr(toSubmit), uintptr(len(reqs)-done), iouringEnterGetEvents, 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return os.NewSyscallError("io_uring_enter", errno)
+		}
+		toSubmit = 0
+		head := atomic.LoadUint32(cqHead)
+		for head != atomic.LoadUint32(cqTail) {
+			c := (*cqe)(unsafe.Pointer(&r.cqRing[p.cqOff.cqes+(head&cqMask)*cqeSize]))
+			if c.userData < uint64(len(reqs)) {
+				reqs[c.userData].res = c.res
+				done++
+			}
+			head++
+		}
+		atomic.StoreUint32(cqHead, head)
+	}
+
+	// Copy results out of the scratch area.
+	for i := range reqs {
+		req := &reqs[i]
+		switch {
+		case req.res < 0:
+		case req.opcode == iouringOpStatx:
+			copy(req.stat[:], scratch[offs[i]:])
+		case req.opcode == iouringOpRead:
+			copy(req.buf, scratch[offs[i]:offs[i]+int(req.res)])
+		}
+	}
+	return nil
+}
+
+func errnoResult(op, name string, res int32) error {
+	return &os.PathError{Op: op, Path: name, Err: syscall.Errno(-res)}
+}
+
+// URingDir is an experimental FileSystem for a directory, like Dir, but
+// which carries out its file operations using io_uring. It's only available
+// on Linux when built with the gzipped_iouring tag.
+type URingDir struct {
+	root  string
+	dirfd int
+	ring  *uring
+}
+
+// NewURingDir opens dir for serving through an io_uring with the given
+// number of submission queue entries, or a default if entries is 0. The
+// URingDir should be closed when no longer needed.
+func NewURingDir(dir string, entries uint32) (*URingDir, error) {
+	if entries == 0 {
+		entries = defaultURingEntries
+	}
+	if dir == "" {
+		dir = "."
+	}
+	dirfd, err := syscall.Open(dir, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: dir, Err: err}
+	}
+	ring, err := newURing(entries)
+	if err != nil {
+		syscall.Close(dirfd)
+		return nil, err
+	}
+	return &URingDir{root: dir, dirfd: dirfd, ring: ring}, nil
+}
+
+// Close releases the io_uring and the directory handle.
+func (d *URingDir) Close() error {
+	err := d.ring.close()
+	if cerr := syscall.Close(d.dirfd); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// relative resolves name like http.Dir does, returning a path relative to
+// the root directory.
+func (d *URingDir) relative(name string) (string, bool) {
+	if strings.ContainsRune(name, 0) {
+		return "", false
+	}
+	rel := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if rel == "" {
+		rel = "."
+	}
+	return rel, true
+}
+
+// Exists tests whether a file with the specified name exists, resolved relative to the base directory.
+func (d *URingDir) Exists(name string) bool {
+	exists := []bool{false}
+	d.ExistsBatch([]string{name}, exists)
+	return exists[0]
+}
+
+// ExistsBatch checks for all of the named files with a single submission to
+// the kernel, setting the corresponding elements of exists.
+func (d *URingDir) ExistsBatch(names []string, exists []bool) {
+	reqs := make([]uringReq, 0, len(names))
+	idx := make([]int, 0, len(names))
+	for i, name := range names {
+		exists[i] = false
+		if rel, ok := d.relative(name); ok {
+			reqs = append(reqs, uringReq{opcode: iouringOpStatx, fd: int32(d.dirfd), path: rel})
+			idx = append(idx, i)
+		}
+	}
+	if err := d.ring.run(reqs); err != nil {
+		return
+	}
+	for j, i := range idx {
+		exists[i] = reqs[j].res >= 0
+	}
+}
+
+// Open opens the named file, and stats it, in one submission to the kernel.
+func (d *URingDir) Open(name string) (http.File, error) {
+	rel, ok := d.relative(name)
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrInvalid}
+	}
+	reqs := []uringReq{
+		{opcode: iouringOpOpenat, fd: int32(d.dirfd), path: rel, flags: uringOpenFlags},
+		{opcode: iouringOpStatx, fd: int32(d.dirfd), path: rel},
+	}
+	if err := d.ring.run(reqs); err != nil {
+		return nil, err
+	}
+	if reqs[0].res < 0 {
+		return nil, errnoResult("open", name, reqs[0].res)
+	}
+	f := &uringFile{dir: d, fd: reqs[0].res, name: name}
+	if reqs[1].res < 0 {
+		f.Close()
+		return nil, errnoResult("statx", name, reqs[1].res)
+	}
+	f.info = statxToFileInfo(path.Base(path.Clean("/"+name)), &reqs[1].stat)
+	return f, nil
+}
+
+// uringFile is a file opened by a URingDir, whose reads go through the ring.
+type uringFile struct {
+	dir  *URingDir
+	fd   int32
+	name string
+	off  int64
+	info os.FileInfo
+}
+
+func (f *uringFile) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	reqs := []uringReq{{opcode: iouringOpRead, fd: f.fd, buf: b, off: f.off}}
+	if err := f.dir.ring.run(reqs); err != nil {
+		return 0, err
+	}
+	n := reqs[0].res
+	if n < 0 {
+		return 0, errnoResult("read", f.name, n)
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+	f.off += int64(n)
+	return int(n), nil
+}
+
+func (f *uringFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.info.Size()
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *uringFile) Close() error {
+	reqs := []uringReq{{opcode: iouringOpClose, fd: f.fd}}
+	if err := f.dir.ring.run(reqs); err != nil {
+		return err
+	}
+	if reqs[0].res < 0 {
+		return errnoResult("close", f.name, reqs[0].res)
+	}
+	return nil
+}
+
+// Readdir isn't done through the ring; it's rarely needed when serving.
+func (f *uringFile) Readdir(count int) ([]os.FileInfo, error) {
+	dir, err := os.Open(path.Join(f.dir.root, path.Clean("/"+f.name)))
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+	return dir.Readdir(count)
+}
+
+func (f *uringFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+// statxInfo is an os.FileInfo decoded from a struct statx.
+type statxInfo struct {
+	name  string
+	size  int64
+	mode  os.FileMode
+	mtime time.Time
+}
+
+func (s *statxInfo) Name() string       { return s.name }
+func (s *statxInfo) Size() int64        { return s.size }
+func (s *statxInfo) Mode() os.FileMode  { return s.mode }
+func (s *statxInfo) ModTime() time.Time { return s.mtime }
+func (s *statxInfo) IsDir() bool        { return s.mode.IsDir() }
+func (s *statxInfo) Sys() interface{}   { return nil }
+
+// Offsets of the fields of struct statx which we use.
+const (
+	statxModeOffset  = 28
+	statxSizeOffset  = 40
+	statxMtimeOffset = 112
+)
+
+func statxToFileInfo(name string, buf *[statxSize]byte) os.FileInfo {
+	mode := uint32(*(*uint16)(unsafe.Pointer(&buf[statxModeOffset])))
+	size := *(*int64)(unsafe.Pointer(&buf[statxSizeOffset]))
+	sec := *(*int64)(unsafe.Pointer(&buf[statxMtimeOffset]))
+	nsec := *(*uint32)(unsafe.Pointer(&buf[statxMtimeOffset+8]))
+	fm := os.FileMode(mode & 0777)
+	switch mode & syscall.S_IFMT {
+	case syscall.S_IFDIR:
+		fm |= os.ModeDir
+	case syscall.S_IFLNK:
+		fm |= os.ModeSymlink
+	case syscall.S_IFREG:
+	default:
+		fm |= os.ModeIrregular
+	}
+	return &statxInfo{name: name, size: size, mode: fm, mtime: time.Unix(sec, int64(nsec))}
+}
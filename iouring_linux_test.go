@@ -0,0 +1,52 @@
+//go:build linux && gzipped_iouring
+
+package gzipped
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func TestURingDir(t *testing.T) {
+	d, err := NewURingDir("./testdata", 8)
+	if err != nil {
+		t.Skipf("io_uring unavailable: %v", err)
+	}
+	defer d.Close()
+
+	exists := make([]bool, 3)
+	d.ExistsBatch([]string{"/file.txt", "/file.txt.gz", "/file.txt.br"}, exists)
+	if !exists[0] || !exists[1] || exists[2] {
+		t.Errorf("ExistsBatch returned %v", exists)
+	}
+	if d.Exists("/../../etc/passwd") {
+		t.Error("path escaped the root directory")
+	}
+
+	f, err := d.Open("/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, _ := f.Stat()
+	expect, _ := os.ReadFile("./testdata/file.txt")
+	if info.Size() != int64(len(expect)) || info.IsDir() {
+		t.Errorf("Stat returned size %d, dir %v", info.Size(), info.IsDir())
+	}
+	body, err := io.ReadAll(f)
+	if err != nil || string(body) != string(expect) {
+		t.Errorf("read %q, %v", body, err)
+	}
+	if _, err := d.Open("/updog"); !os.IsNotExist(err) {
+		t.Errorf("opening nonexistent file returned %v", err)
+	}
+
+	// The whole handler works on top of it.
+	for _, tt := range []TestCase{
+		{"Get", func(t *testing.T) { testGet(t, d, false, "/file.txt", "zyxwvutsrqponmlkjihgfedcba\n") }},
+		{"GzipGet", func(t *testing.T) { testGet(t, d, true, "/file.txt", "abcdefghijklmnopqrstuvwxyz\n") }},
+	} {
+		t.Run(tt.name, tt.test)
+	}
+}
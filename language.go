@@ -0,0 +1,128 @@
+package gzipped
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	acceptLanguageHeader  = "Accept-Language"
+	contentLanguageHeader = "Content-Language"
+)
+
+// WithLanguages negotiates the language of files as well as their encoding.
+// langs are the language tags files may be suffixed with, such as "en" and
+// "de" for about.html.en and about.html.de, most preferred first. A request
+// for /about.html is served the available translation the client's
+// Accept-Language likes best, or the first available in the order of langs
+// if it accepts none of them, with a Content-Language header. Each
+// translation's compressed variants, like about.html.de.br, are then
+// negotiated as usual.
+//
+// Files with no translations are served as they would be otherwise.
+func WithLanguages(langs ...string) Option {
+	tags := make([]string, 0, len(langs))
+	for _, lang := range langs {
+		if lang = strings.ToLower(strings.TrimSpace(lang)); lang != "" {
+			tags = append(tags, lang)
+		}
+	}
+	return func(f *fileHandler) {
+		f.languages = tags
+	}
+}
+
+// negotiateLanguage picks the translation of fpath to serve, and returns its
+// file name, having set the response headers for it. If fpath has no
+// translations, it's returned unchanged.
+func (f *fileHandler) negotiateLanguage(w http.ResponseWriter, r *http.Request, fpath string) string {
+	encs := preferredEncodings
+	var names [maxEncodings]string
+	best, bestQ := -1, -1
+	al := r.Header.Get(acceptLanguageHeader)
+	if len(al) > maxAcceptEncodingLen {
+		al = ""
+	}
+	for i, lang := range f.languages {
+		lpath := fpath + "." + lang
+		variantNames(lpath, encs, names[:])
+		if f.availableEncodings(lpath, encs, names[:]) == 0 {
+			continue
+		}
+		if q := languageQ(al, lang); q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	if best < 0 {
+		return fpath
+	}
+	lang := f.languages[best]
+	h := w.Header()
+	h[contentLanguageHeader] = []string{lang}
+	addVary(h, acceptLanguageHeader)
+	// The type has to come from the untranslated name, as the language
+	// suffix hides the extension.
+	if ctype := f.typeByExtension(fpath); ctype != "" {
+		h[contentTypeHeader] = contentTypeValue(ctype)
+	}
+	return fpath + "." + lang
+}
+
+// languageQ returns the q-value in thousandths the Accept-Language header
+// value al gives the language tag lang, or 0 if it doesn't mention it. A
+// range matches a tag when either is a prefix of the other ending at a
+// hyphen, so "de" matches "de-ch" and "de-ch" falls back to "de", and the
+// most specific range wins.
+func languageQ(al, lang string) int {
+	q, matched := 0, -1
+	for n := 0; al != "" && n < maxAcceptEncodingCodings; n++ {
+		var elem string
+		elem, al = cut(al, ',')
+		rng, params := cut(elem, ';')
+		rng = strings.ToLower(trimOWS(rng))
+		if rng == "" {
+			continue
+		}
+		rq := 1000
+		if param := trimOWS(params); len(param) >= 2 && (param[0] == 'q' || param[0] == 'Q') && param[1] == '=' {
+			rq = parseQ(param[2:])
+		}
+		var specificity int
+		switch {
+		case rng == "*":
+			specificity = 0
+		case rng == lang:
+			specificity = len(rng) + 1
+		case strings.HasPrefix(lang, rng+"-"), strings.HasPrefix(rng, lang+"-"):
+			specificity = len(rng)
+			if len(lang) < specificity {
+				specificity = len(lang)
+			}
+		default:
+			continue
+		}
+		if specificity > matched {
+			q, matched = rq, specificity
+		}
+	}
+	return q
+}
+
+// untranslated returns fpath without any language suffix, for matching
+// against patterns and finding its type.
+func (f *fileHandler) untranslated(fpath string) string {
+	if f.languages == nil {
+		return fpath
+	}
+	ext := path.Ext(fpath)
+	if ext == "" {
+		return fpath
+	}
+	for _, lang := range f.languages {
+		if ext[1:] == lang {
+			return strings.TrimSuffix(fpath, ext)
+		}
+	}
+	return fpath
+}
@@ -0,0 +1,69 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestLanguageQ(t *testing.T) {
+	for _, tc := range []struct {
+		al, lang string
+		expect   int
+	}{
+		{"", "en", 0},
+		{"en", "en", 1000},
+		{"de;q=0.5, en", "de", 500},
+		{"de", "de-ch", 1000},
+		{"de-CH", "de", 1000},
+		{"de-ch;q=0.8, de;q=0.2", "de-ch", 800},
+		{"de-ch;q=0.8, de;q=0.2", "de", 200},
+		{"*;q=0.1, fr", "en", 100},
+		{"fr, *;q=0", "en", 0},
+		{"english", "en", 0},
+	} {
+		if got := languageQ(tc.al, tc.lang); got != tc.expect {
+			t.Errorf("languageQ(%q, %q) = %d, expected %d", tc.al, tc.lang, got, tc.expect)
+		}
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"about.html.en", "about.html.de", "about.html.de.br", "help.html.de", "plain.html"} {
+		if err := os.WriteFile(dir+"/"+name, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := FileServer(Dir(dir), WithLanguages("en", "de"), WithCacheControl("*.html", "no-cache"))
+	for _, tc := range []struct {
+		path, al, ae, body, lang string
+	}{
+		{"/about.html", "", "", "about.html.en", "en"},
+		{"/about.html", "de-AT, en;q=0.5", "", "about.html.de", "de"},
+		{"/about.html", "de", "br", "about.html.de.br", "de"},
+		{"/about.html", "fr", "", "about.html.en", "en"},
+		{"/help.html", "en", "", "help.html.de", "de"},
+		{"/plain.html", "de", "", "plain.html", ""},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Language", tc.al)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		hdr := rr.Header()
+		if rr.Code != http.StatusOK || rr.Body.String() != tc.body || hdr.Get("Content-Language") != tc.lang {
+			t.Errorf("GET %s (%q): %d %q in %q, expected %q in %q", tc.path, tc.al, rr.Code, rr.Body.String(), hdr.Get("Content-Language"), tc.body, tc.lang)
+		}
+		if ct := hdr.Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("GET %s (%q): Content-Type %q", tc.path, tc.al, ct)
+		}
+		if cc := hdr.Get("Cache-Control"); cc != "no-cache" {
+			t.Errorf("GET %s (%q): Cache-Control %q", tc.path, tc.al, cc)
+		}
+		if tc.lang != "" && hdr.Values("Vary")[0] != "Accept-Language" {
+			t.Errorf("GET %s (%q): Vary %q", tc.path, tc.al, hdr.Values("Vary"))
+		}
+	}
+}
@@ -0,0 +1,141 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DirectoryListing is the data a directory listing template is executed
+// with.
+type DirectoryListing struct {
+	Path    string // URL path of the directory, ending in "/"
+	Entries []DirectoryEntry
+}
+
+// DirectoryEntry is a file or subdirectory in a DirectoryListing. The
+// compressed variants of a file aren't listed separately, since the file's
+// URL serves them.
+type DirectoryEntry struct {
+	Name  string // file name, with "/" appended for directories
+	URL   string // escaped URL, relative to the directory
+	IsDir bool
+	// Size of the uncompressed file, or -1 if it only exists compressed.
+	Size    int64
+	ModTime time.Time
+	// Encodings are the content-codings of the file's compressed variants.
+	Encodings []string
+}
+
+// DefaultListingTemplate is the template WithDirectoryListing uses if it's
+// given nil, which lists the entries as links, as http.FileServer does.
+var DefaultListingTemplate = template.Must(template.New("listing").Parse(`<!doctype html>
+<meta name="viewport" content="width=device-width">
+<title>Index of {{.Path}}</title>
+<h1>Index of {{.Path}}</h1>
+<pre>
+{{range .Entries}}<a href="{{.URL}}">{{.Name}}</a>
+{{end}}</pre>
+`))
+
+// WithDirectoryListing lists the contents of directories for requests for
+// them, URLs ending in "/", rendered through tmpl with a DirectoryListing.
+// If WithIndexFile is used too, directories with an index file are served
+// it instead. The listing is compressed on the fly for clients which accept
+// gzip, when it's large enough for that to help. The FileSystem must
+// implement DirReader; others get 404 Not Found as usual. Files hidden by
+// WithoutDotfiles aren't listed. A nil tmpl means DefaultListingTemplate.
+func WithDirectoryListing(tmpl *template.Template) Option {
+	if tmpl == nil {
+		tmpl = DefaultListingTemplate
+	}
+	return func(f *fileHandler) {
+		f.listing = &listing{tmpl: tmpl}
+	}
+}
+
+// listing renders directory listings.
+type listing struct {
+	tmpl *template.Template
+}
+
+// wants reports whether the directory dir should be listed rather than
+// served its index file.
+func (l *listing) wants(f *fileHandler, dir string) bool {
+	if f.indexFile == "" {
+		return true
+	}
+	encs := preferredEncodings
+	var names [maxEncodings]string
+	index := path.Join(dir, f.indexFile)
+	variantNames(index, encs, names[:])
+	return f.availableEncodings(index, encs, names[:]) == 0
+}
+
+// serve responds with the listing of dir, a cleaned path.
+func (l *listing) serve(f *fileHandler, w http.ResponseWriter, r *http.Request, dir string) {
+	dr, ok := f.root.(DirReader)
+	if !ok || (f.hideDotfiles && f.hiddenPath(dir)) {
+		f.notFound(w, r)
+		return
+	}
+	des, err := dr.ReadDir(dir)
+	if err != nil {
+		// Most likely it isn't a directory at all.
+		f.notFound(w, r)
+		return
+	}
+	entries := make(map[string]*DirectoryEntry, len(des))
+	for _, de := range des {
+		name := de.Name()
+		if f.hideDotfiles && strings.HasPrefix(name, ".") && !f.dotfileAllowed(name) {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		if de.IsDir() {
+			entries[name+"/"] = &DirectoryEntry{Name: name + "/", IsDir: true, Size: -1, ModTime: info.ModTime()}
+			continue
+		}
+		orig, enc := name, ""
+		for _, e := range preferredEncodings {
+			if e.ext != "" && strings.HasSuffix(name, e.ext) && len(name) > len(e.ext) {
+				orig, enc = strings.TrimSuffix(name, e.ext), e.name
+				break
+			}
+		}
+		e, ok := entries[orig]
+		if !ok {
+			e = &DirectoryEntry{Name: orig, Size: -1, ModTime: info.ModTime()}
+			entries[orig] = e
+		}
+		if enc != "" {
+			e.Encodings = append(e.Encodings, enc)
+			continue
+		}
+		e.Size, e.ModTime = info.Size(), info.ModTime()
+	}
+	data := DirectoryListing{Path: r.URL.Path, Entries: make([]DirectoryEntry, 0, len(entries))}
+	for _, e := range entries {
+		e.URL = (&url.URL{Path: e.Name}).String()
+		data.Entries = append(data.Entries, *e)
+	}
+	sort.Slice(data.Entries, func(i, j int) bool {
+		return data.Entries[i].Name < data.Entries[j].Name
+	})
+	var buf bytes.Buffer
+	if err := l.tmpl.Execute(&buf, data); err != nil {
+		f.serveError(w, r, err)
+		return
+	}
+	newGenerated("text/html; charset=utf-8", buf.Bytes(), "").serve(w, r, http.StatusOK)
+}
@@ -0,0 +1,85 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"compress/gzip"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDirectoryListing(t *testing.T) {
+	dir := t.TempDir()
+	for _, d := range []string{"/sub", "/withindex", "/.git"} {
+		if err := os.Mkdir(dir+d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range map[string]string{
+		"/a b.txt":              "hello",
+		"/a b.txt.gz":           "not really gzip",
+		"/a b.txt.br":           "not really brotli",
+		"/only.css.br":          "not really brotli",
+		"/.env":                 "SECRET=1",
+		"/withindex/index.html": "<p>index</p>",
+	} {
+		if err := os.WriteFile(dir+name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tmpl := template.Must(template.New("").Parse(
+		`{{.Path}}:{{range .Entries}} {{.Name}}={{.URL}},{{.Size}},{{.IsDir}}{{range .Encodings}},{{.}}{{end}};{{end}}`))
+	h := FileServer(Dir(dir), WithDirectoryListing(tmpl), WithIndexFile("index.html"), WithoutDotfiles())
+	get := func(url, ae string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("Accept-Encoding", ae)
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/", "")
+	expect := "/: a b.txt=a%20b.txt,5,false,br,gzip; only.css=only.css,-1,false,br; sub/=sub/,-1,true; withindex/=withindex/,-1,true;"
+	if rr.Code != 200 || rr.Body.String() != expect {
+		t.Errorf("listing of /: %d %q, expected %q", rr.Code, rr.Body.String(), expect)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("listing served as %q", ct)
+	}
+	if rr := get("/withindex/", ""); rr.Body.String() != "<p>index</p>" {
+		t.Errorf("directory with an index file served %q", rr.Body.String())
+	}
+	if rr := get("/sub/", ""); rr.Code != 200 || rr.Body.String() != "/sub/:" {
+		t.Errorf("empty directory: %d %q", rr.Code, rr.Body.String())
+	}
+	for _, url := range []string{"/.git/", "/missing/", "/a b.txt/"} {
+		if rr := get(url, ""); rr.Code != http.StatusNotFound {
+			t.Errorf("GET %s: %d, expected 404", url, rr.Code)
+		}
+	}
+
+	// Long listings are compressed.
+	for i := 0; i < 40; i++ {
+		if err := os.WriteFile(dir+"/sub/"+strings.Repeat("x", i+1)+".txt", nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h = FileServer(Dir(dir), WithDirectoryListing(nil))
+	rr = get("/sub/", "gzip")
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("long listing not compressed: %v", rr.Header())
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(zr)
+	if !strings.Contains(string(body), "<title>Index of /sub/</title>") || !strings.Contains(string(body), `<a href="xxx.txt">xxx.txt</a>`) {
+		t.Errorf("default listing:\n%s", body)
+	}
+}
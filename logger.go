@@ -0,0 +1,38 @@
+package gzipped
+
+import "net/http"
+
+// Logger receives diagnostic messages about how the handler chose what to
+// serve. Its method has the signature of (*slog.Logger).Debug, so a
+// *slog.Logger can be passed straight to WithLogger; args are alternating
+// keys and values.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+}
+
+// WithLogger sends diagnostics about variant selection to l at debug level:
+// which file was chosen for each request and why, and variants which were
+// negotiated but couldn't be used. Nothing is logged by default.
+func WithLogger(l Logger) Option {
+	return func(f *fileHandler) {
+		f.logger = l
+	}
+}
+
+// logSelection logs the outcome of findBestFile for r.
+func (f *fileHandler) logSelection(r *http.Request, fpath string, v variant, err error) {
+	ae := r.Header.Get(acceptEncodingHeader)
+	if err != nil {
+		f.debug(r, "gzipped: no file to serve", "path", fpath, "accept_encoding", ae, "error", err)
+		return
+	}
+	f.debug(r, "gzipped: selected variant", "path", fpath, "accept_encoding", ae,
+		"file", v.name, "encoding", v.encoding, "decode", v.decode)
+}
+
+// debug logs msg and args for r at debug level.
+func (f *fileHandler) debug(r *http.Request, msg string, args ...interface{}) {
+	f.callback(r, "Logger.Debug", func() {
+		f.logger.Debug(msg, args...)
+	})
+}
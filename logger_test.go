@@ -0,0 +1,45 @@
+package gzipped
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// recordingLogger keeps each message with its args formatted as key=value.
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) {
+	line := msg
+	for i := 0; i+1 < len(args); i += 2 {
+		line += fmt.Sprintf(" %v=%v", args[i], args[i+1])
+	}
+	l.lines = append(l.lines, line)
+}
+
+func TestLogger(t *testing.T) {
+	log := &recordingLogger{}
+	fs := FileServer(brokenFS{Dir("./testdata/")}, WithLogger(log))
+	for _, p := range []string{"/file.txt", "/nonexistent.txt"} {
+		req, _ := http.NewRequest("GET", p, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		fs.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	expect := []string{
+		"gzipped: falling back from unusable variant path=/file.txt error=",
+		"gzipped: selected variant path=/file.txt accept_encoding=gzip file=/file.txt encoding=identity decode=false",
+		"gzipped: no file to serve path=/nonexistent.txt accept_encoding=gzip error=",
+	}
+	if len(log.lines) != len(expect) {
+		t.Fatalf("logged %q", log.lines)
+	}
+	for i, line := range log.lines {
+		if !strings.HasPrefix(line, expect[i]) {
+			t.Errorf("logged %q, expected %q...", line, expect[i])
+		}
+	}
+}
@@ -0,0 +1,214 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	fs2 "io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Manifest lists the files in an Index and their variants, in the form the
+// manifest endpoint serves as JSON.
+type Manifest struct {
+	Files []ManifestFile `json:"files"`
+}
+
+// ManifestFile is a logical file in a Manifest.
+type ManifestFile struct {
+	Path        string            `json:"path"`
+	ContentType string            `json:"contentType,omitempty"`
+	Variants    []ManifestVariant `json:"variants"`
+}
+
+// ManifestVariant is one variant of a file in a Manifest.
+type ManifestVariant struct {
+	Name     string    `json:"name"`
+	Encoding string    `json:"encoding"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"modTime"`
+	SHA256   string    `json:"sha256"` // hex encoded
+}
+
+// Manifest returns the contents of the index's current snapshot, sorted by
+// path.
+func (idx *Index) Manifest() Manifest {
+	return idx.snapshot().manifest()
+}
+
+func (snap *indexSnapshot) manifest() Manifest {
+	m := Manifest{Files: make([]ManifestFile, 0, len(snap.files))}
+	for p, e := range snap.files {
+		mf := ManifestFile{Path: p, ContentType: e.ctype, Variants: make([]ManifestVariant, len(e.variants))}
+		if mf.ContentType == "" {
+			mf.ContentType = typeByExtension(p)
+		}
+		for i, v := range e.variants {
+			mf.Variants[i] = ManifestVariant{
+				Name:     v.Name,
+				Encoding: v.Encoding,
+				Size:     v.Size,
+				ModTime:  v.ModTime.UTC(),
+				SHA256:   hex.EncodeToString(v.SHA256[:]),
+			}
+		}
+		m.Files = append(m.Files, mf)
+	}
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+	return m
+}
+
+// DefaultManifestName is the name WithManifest looks for when given "".
+const DefaultManifestName = "/.gzipped.manifest.json"
+
+// LoadManifest returns an Index of the files in root made from the manifest
+// in the file name, in the JSON form of Manifest, rather than by walking and
+// hashing the files. Such a manifest can be generated before deployment by
+// cmd/gzipped-manifest, or fetched from the manifest endpoint. Rebuild
+// rereads the manifest.
+func LoadManifest(root FileSystem, name string) (*Index, error) {
+	idx := &Index{root: root, manifest: name}
+	if err := idx.Rebuild(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// readManifest reads the manifest file name from root into a snapshot.
+func readManifest(root FileSystem, name string) (*indexSnapshot, error) {
+	file, err := root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var m Manifest
+	if err := json.NewDecoder(file).Decode(&m); err != nil {
+		return nil, fmt.Errorf("gzipped: manifest %s: %w", name, err)
+	}
+	snap := &indexSnapshot{files: make(map[string]*indexEntry, len(m.Files))}
+	for _, mf := range m.Files {
+		e := &indexEntry{ctype: mf.ContentType, variants: make([]IndexedVariant, len(mf.Variants))}
+		for i, mv := range mf.Variants {
+			v := IndexedVariant{Name: mv.Name, Encoding: mv.Encoding, Size: mv.Size, ModTime: mv.ModTime}
+			if mv.SHA256 != "" {
+				if n, err := hex.Decode(v.SHA256[:], []byte(mv.SHA256)); err != nil || n != len(v.SHA256) {
+					return nil, fmt.Errorf("gzipped: manifest %s: invalid hash for %s", name, mv.Name)
+				}
+			}
+			e.variants[i] = v
+		}
+		snap.files[path.Clean("/"+mf.Path)] = e
+	}
+	return snap, nil
+}
+
+// WithManifest makes the handler use the manifest in the file name, or
+// DefaultManifestName if name is "", to find which variants of each file
+// exist and what type they are, as with WithIndex and LoadManifest. This
+// saves looking for variants on every request, which is worthwhile for
+// network file systems and backends with slow Stat.
+//
+// If there's no such file the handler asks the FileSystem as usual. If the
+// manifest can't be read, the error is reported to the error hook or
+// reporter, which must be set by an earlier option to hear about it.
+// Requests for the manifest itself get 404 Not Found, so that the file list
+// is only published through ManifestHandler.
+func WithManifest(name string) Option {
+	if name == "" {
+		name = DefaultManifestName
+	}
+	name = path.Clean("/" + name)
+	return func(f *fileHandler) {
+		f.manifestName = name
+		idx, err := LoadManifest(f.root, name)
+		switch {
+		case err == nil:
+			f.index = idx
+		case !errors.Is(err, fs2.ErrNotExist):
+			f.reportError(nil, err)
+		}
+	}
+}
+
+// ManifestHandler returns a handler which serves the manifest of idx as JSON,
+// to requests for which authorized returns true. Unauthorized requests get
+// 401 Unauthorized if they carry no credentials, 403 Forbidden otherwise. If
+// authorized is nil, every request is refused.
+//
+// The response has an ETag derived from its content, so tools polling the
+// endpoint get 304 Not Modified until the index is rebuilt with changes.
+func ManifestHandler(idx *Index, authorized func(*http.Request) bool) http.Handler {
+	return &manifestHandler{idx: idx, authorized: authorized}
+}
+
+// BearerToken returns an authorization function for ManifestHandler which
+// accepts requests with the header "Authorization: Bearer <token>".
+func BearerToken(token string) func(*http.Request) bool {
+	want := []byte("Bearer " + token)
+	return func(r *http.Request) bool {
+		got := []byte(r.Header.Get("Authorization"))
+		return token != "" && subtle.ConstantTimeCompare(got, want) == 1
+	}
+}
+
+type manifestHandler struct {
+	idx        *Index
+	authorized func(*http.Request) bool
+
+	mu   sync.Mutex
+	snap *indexSnapshot // snapshot gen was encoded from
+	gen  *generated
+}
+
+func (h *manifestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		serveErrorText(w, r, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if h.authorized == nil || !h.authorized(r) {
+		if r.Header.Get("Authorization") == "" {
+			serveErrorText(w, r, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		} else {
+			serveErrorText(w, r, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		}
+		return
+	}
+	gen, err := h.encode()
+	if err != nil {
+		serveErrorText(w, r, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	gen.serve(w, r, http.StatusOK)
+}
+
+// encode returns the JSON for the current snapshot, reusing the previous
+// encoding if the snapshot hasn't changed.
+func (h *manifestHandler) encode() (*generated, error) {
+	snap := h.idx.snapshot()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if snap == h.snap {
+		return h.gen, nil
+	}
+	body, err := json.Marshal(snap.manifest())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	h.snap = snap
+	h.gen = newGenerated("application/json", body, `"`+hex.EncodeToString(sum[:16])+`"`)
+	return h.gen, nil
+}
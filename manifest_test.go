@@ -0,0 +1,171 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestManifestHandler(t *testing.T) {
+	idx, err := BuildIndex(Dir("./testdata/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := ManifestHandler(idx, BearerToken("secret"))
+	get := func(method, auth, inm string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/manifest.json", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		if inm != "" {
+			req.Header.Set("If-None-Match", inm)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get(http.MethodGet, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no credentials: status %d", rec.Code)
+	}
+	if rec := get(http.MethodGet, "Bearer wrong", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("wrong credentials: status %d", rec.Code)
+	}
+	if rec := get(http.MethodPost, "Bearer secret", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status %d", rec.Code)
+	}
+
+	rec := get(http.MethodGet, "Bearer secret", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	var m Manifest
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, f := range m.Files {
+		if f.Path != "/file.txt" {
+			continue
+		}
+		found = true
+		if len(f.Variants) != 2 {
+			t.Fatalf("variants of /file.txt: %+v", f.Variants)
+		}
+		for _, v := range f.Variants {
+			if v.Name == "/file.txt" {
+				sum := sha256.Sum256([]byte("zyxwvutsrqponmlkjihgfedcba\n"))
+				if v.Encoding != "identity" || v.Size != 27 || v.SHA256 != hex.EncodeToString(sum[:]) {
+					t.Errorf("wrong manifest entry %+v", v)
+				}
+			} else if v.Name != "/file.txt.gz" || v.Encoding != "gzip" {
+				t.Errorf("unexpected variant %+v", v)
+			}
+		}
+	}
+	if !found {
+		t.Error("/file.txt missing from manifest")
+	}
+
+	etag := rec.Header().Get("Etag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if rec := get(http.MethodGet, "Bearer secret", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: status %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	ManifestHandler(idx, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("nil authorization: status %d", rec.Code)
+	}
+}
+
+func TestWithManifest(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"blob":    "raw blob",
+		"blob.gz": "not really gzip",
+		".gzipped.manifest.json": `{"files": [
+			{"path": "/blob", "contentType": "application/x-blob", "variants": [
+				{"name": "/blob", "encoding": "identity", "size": 8},
+				{"name": "/blob.gz", "encoding": "gzip", "size": 15}
+			]}
+		]}`,
+	} {
+		if err := os.WriteFile(dir+"/"+name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := newCountingFS(Dir(dir))
+	h := FileServer(root, WithManifest(""))
+	for _, tc := range []struct {
+		ae, encoding, body string
+	}{
+		{"gzip", "gzip", "not really gzip"},
+		{"", "", "raw blob"},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/blob", nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		if rr.Header().Get("Content-Encoding") != tc.encoding || rr.Body.String() != tc.body {
+			t.Errorf("GET /blob (%q): %q %q", tc.ae, rr.Header().Get("Content-Encoding"), rr.Body.String())
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/x-blob" {
+			t.Errorf("GET /blob (%q): Content-Type %q", tc.ae, ct)
+		}
+	}
+	if n := root.count("exists:/blob.gz"); n != 0 {
+		t.Errorf("variant looked for %d times despite the manifest", n)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, DefaultManifestName, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("GET %s: %d, expected 404", DefaultManifestName, rr.Code)
+	}
+
+	// Without a manifest, the FileSystem is asked as usual.
+	var errs []error
+	h = FileServer(Dir("./testdata/"), WithErrorHook(func(r *http.Request, err error) { errs = append(errs, err) }), WithManifest(""))
+	if h.(*fileHandler).index != nil || len(errs) != 0 {
+		t.Errorf("missing manifest gave index %v and errors %v", h.(*fileHandler).index, errs)
+	}
+	if err := os.WriteFile(dir+"/bad.json", []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	FileServer(Dir(dir), WithErrorHook(func(r *http.Request, err error) { errs = append(errs, err) }), WithManifest("/bad.json"))
+	if len(errs) != 1 {
+		t.Errorf("invalid manifest reported %v", errs)
+	}
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	idx, err := BuildIndex(Dir("./testdata/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(idx.Manifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/manifest.json", body, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadManifest(Dir(dir), "/manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Manifest(), idx.Manifest()) {
+		t.Errorf("loaded manifest %+v differs from %+v", loaded.Manifest(), idx.Manifest())
+	}
+}
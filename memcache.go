@@ -0,0 +1,305 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// memEntry is the content of a file held in memory.
+type memEntry struct {
+	data []byte
+	info os.FileInfo
+	// Entries whose data is off the Go heap have to be freed explicitly,
+	// once they've been removed from the cache and the last reader has
+	// finished with them. Heap entries are left to the garbage collector.
+	offHeap bool
+	refs    int32 // the cache's reference plus one per open memFile
+	// When the entry was last opened, by the cache's clock, for LRU
+	// eviction.
+	used int64
+	// When the entry must be reloaded, or zero for never.
+	expires time.Time
+}
+
+// acquire takes a reference to the entry for a new reader. It must be called
+// with the cache lock held, so that the entry can't be freed in between.
+func (e *memEntry) acquire() {
+	if e.offHeap {
+		atomic.AddInt32(&e.refs, 1)
+	}
+}
+
+// release drops a reference, freeing off-heap data when none are left.
+func (e *memEntry) release() {
+	if e.offHeap && atomic.AddInt32(&e.refs, -1) == 0 {
+		freeOffHeap(e.data)
+	}
+}
+
+// memFile is an http.File reading from a memEntry.
+type memFile struct {
+	*bytes.Reader
+	entry  *memEntry
+	closed bool
+}
+
+func (m *memFile) Close() error {
+	if !m.closed {
+		m.closed = true
+		m.entry.release()
+	}
+	return nil
+}
+
+func (m *memFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, errors.New("not a directory")
+}
+
+func (m *memFile) Stat() (os.FileInfo, error) {
+	return m.entry.info, nil
+}
+
+// memCache holds the contents of files in memory, keyed by their names on
+// the FileSystem. Since each variant of a file has its own name, entries are
+// effectively keyed by path and encoding.
+type memCache struct {
+	budget int64 // maximum total bytes of content, 0 for no limit
+	// Files of at least this many bytes are stored off the Go heap, if
+	// that's supported; 0 means never.
+	offHeapMin int64
+	// Set by WithMemoryCache, for files to be cached as they're served,
+	// evicting the least recently used when over budget.
+	lru   *MemoryCacheConfig
+	clock int64 // incremented on each open, for LRU
+	mu    sync.RWMutex
+	files map[string]*memEntry
+	used  int64
+}
+
+func (c *memCache) get(name string) (*memEntry, bool) {
+	c.mu.RLock()
+	e, ok := c.files[name]
+	c.mu.RUnlock()
+	return e, ok
+}
+
+// open returns a reader for the named file's content, if it's cached.
+func (c *memCache) open(name string) (http.File, os.FileInfo, bool) {
+	c.mu.RLock()
+	e, ok := c.files[name]
+	if ok && !e.expires.IsZero() && time.Now().After(e.expires) {
+		ok = false
+	}
+	if ok {
+		e.acquire()
+		if c.lru != nil {
+			atomic.StoreInt64(&e.used, atomic.AddInt64(&c.clock, 1))
+		}
+	}
+	c.mu.RUnlock()
+	if !ok {
+		return nil, nil, false
+	}
+	return &memFile{Reader: bytes.NewReader(e.data), entry: e}, e.info, true
+}
+
+// add puts a file in the cache, replacing any existing entry, unless that
+// would take the cache over budget. Whether or not it's added, the cache
+// takes ownership of the entry.
+func (c *memCache) add(name string, e *memEntry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	used := c.used + int64(len(e.data))
+	old, replacing := c.files[name]
+	if replacing {
+		if old == e {
+			return true
+		}
+		used -= int64(len(old.data))
+	}
+	if c.budget > 0 && used > c.budget && c.lru != nil && int64(len(e.data)) <= c.budget {
+		used -= c.evict(name, used-c.budget)
+	}
+	if c.budget > 0 && used > c.budget {
+		e.release()
+		return false
+	}
+	if c.files == nil {
+		c.files = make(map[string]*memEntry)
+	}
+	if replacing {
+		old.release()
+	}
+	c.files[name] = e
+	c.used = used
+	return true
+}
+
+// evict removes the least recently used entries other than keep until at
+// least need bytes have been freed, and returns the number freed. It must be
+// called with the write lock held.
+func (c *memCache) evict(keep string, need int64) int64 {
+	names := make([]string, 0, len(c.files))
+	for name := range c.files {
+		if name != keep {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return atomic.LoadInt64(&c.files[names[i]].used) < atomic.LoadInt64(&c.files[names[j]].used)
+	})
+	var freed int64
+	for _, name := range names {
+		if freed >= need {
+			break
+		}
+		old := c.files[name]
+		freed += int64(len(old.data))
+		delete(c.files, name)
+		old.release()
+	}
+	c.used -= freed
+	return freed
+}
+
+func (c *memCache) remove(name string) {
+	c.mu.Lock()
+	if old, ok := c.files[name]; ok {
+		c.used -= int64(len(old.data))
+		delete(c.files, name)
+		old.release()
+	}
+	c.mu.Unlock()
+}
+
+var errTooLarge = errors.New("file is too large to cache")
+
+// load reads a whole file into a new memEntry, provided it's no bigger than
+// maxSize bytes.
+func (c *memCache) load(root FileSystem, name string, maxSize int64) (*memEntry, error) {
+	file, err := root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, errors.New(name + " is directory")
+	}
+	size := info.Size()
+	if size > maxSize {
+		return nil, errTooLarge
+	}
+	return c.read(name, file, info)
+}
+
+// read reads the whole of file, which info describes, into a new memEntry.
+func (c *memCache) read(name string, file http.File, info os.FileInfo) (*memEntry, error) {
+	size := info.Size()
+	e := &memEntry{info: info, refs: 1}
+	if c.offHeapMin > 0 && size >= c.offHeapMin {
+		e.data, e.offHeap = allocOffHeap(int(size))
+	}
+	if !e.offHeap {
+		e.data = make([]byte, size)
+	}
+	// The file has to be exactly the size it claimed.
+	if _, err := io.ReadFull(file, e.data); err != nil {
+		e.release()
+		return nil, err
+	}
+	var probe [1]byte
+	if n, _ := file.Read(probe[:]); n != 0 {
+		e.release()
+		return nil, errors.New(name + " changed size while being cached")
+	}
+	return e, nil
+}
+
+// fill caches a file which has just been opened to be served, if the cache
+// is being filled that way and the file is small enough, and returns a file
+// reading the cached copy in place of file. Otherwise, or if the file can't
+// be read, file is returned, at its start.
+func (c *memCache) fill(name string, file http.File, info os.FileInfo) http.File {
+	if c.lru == nil || info.Size() > c.lru.MaxFileSize {
+		return file
+	}
+	e, err := c.read(name, file, info)
+	if err != nil {
+		file.Seek(0, io.SeekStart)
+		return file
+	}
+	file.Close()
+	e.used = atomic.AddInt64(&c.clock, 1)
+	if c.lru.TTL > 0 {
+		e.expires = time.Now().Add(c.lru.TTL)
+	}
+	// One reference for the reader, and one for the cache, which add takes
+	// over whether or not it keeps the entry.
+	e.refs++
+	c.add(name, e)
+	return &memFile{Reader: bytes.NewReader(e.data), entry: e}
+}
+
+// MemoryCacheConfig configures WithMemoryCache. Zero values mean the
+// defaults.
+type MemoryCacheConfig struct {
+	// The most bytes of file content to hold, 64 MiB by default.
+	Budget int64
+	// The largest file to cache, 256 KiB by default.
+	MaxFileSize int64
+	// How long a cached file is used before it's read again, to pick up
+	// changes. By default cached files are used until evicted.
+	TTL time.Duration
+}
+
+// WithMemoryCache keeps the contents of small files in memory as they're
+// served, so that frequently requested assets like script chunks and style
+// sheets don't have to be read from the FileSystem each time. Each variant
+// is cached separately, so the cache is effectively keyed by path and
+// encoding. When the budget is reached, the least recently used files are
+// evicted to make room.
+//
+// The budget is shared with WithHotCache and WithPrefetch, if they're used
+// too, and files they load can be evicted in the same way.
+func WithMemoryCache(cfg MemoryCacheConfig) Option {
+	if cfg.Budget <= 0 {
+		cfg.Budget = 64 << 20
+	}
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = 256 << 10
+	}
+	return func(f *fileHandler) {
+		if f.mem == nil {
+			f.mem = &memCache{}
+		}
+		f.mem.budget = cfg.Budget
+		f.mem.lru = &cfg
+	}
+}
+
+// WithOffHeapCache stores cached files of at least minSize bytes in memory
+// allocated directly from the operating system, outside the Go heap, so that
+// very large caches don't lengthen garbage collection. It applies to the
+// memory caches enabled by the other options, and has no effect on platforms
+// without anonymous memory mapping, where the Go heap is used as usual.
+func WithOffHeapCache(minSize int64) Option {
+	return func(f *fileHandler) {
+		if f.mem == nil {
+			f.mem = &memCache{}
+		}
+		f.mem.offHeapMin = minSize
+	}
+}
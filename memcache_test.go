@@ -0,0 +1,104 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestOffHeapCache(t *testing.T) {
+	b, supported := allocOffHeap(1)
+	if supported {
+		freeOffHeap(b)
+	}
+	c := &memCache{offHeapMin: 21}
+	root := Dir("./testdata/")
+	for name, offHeap := range map[string]bool{
+		"/file.txt":  supported, // 27 bytes
+		"/file2.txt": false,     // 20 bytes
+	} {
+		e, err := c.load(root, name, 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.offHeap != offHeap {
+			t.Errorf("%s: offHeap is %v, expected %v", name, e.offHeap, offHeap)
+		}
+		c.add(name, e)
+	}
+
+	// A reader keeps an off-heap entry alive after it's evicted.
+	file, _, ok := c.open("/file.txt")
+	if !ok {
+		t.Fatal("cached file not found")
+	}
+	e, _ := c.get("/file.txt")
+	c.remove("/file.txt")
+	if supported && e.refs != 1 {
+		t.Errorf("evicted entry with open reader has %d references", e.refs)
+	}
+	body, err := io.ReadAll(file)
+	if err != nil || string(body) != "zyxwvutsrqponmlkjihgfedcba\n" {
+		t.Errorf("read %q, %v from evicted entry", body, err)
+	}
+	file.Close()
+	file.Close()
+	if e.refs != 0 && supported {
+		t.Errorf("closed entry has %d references", e.refs)
+	}
+	if _, _, ok := c.open("/file.txt"); ok {
+		t.Error("removed file still in cache")
+	}
+	if c.used != 20 {
+		t.Errorf("cache reports %d bytes used, expected 20", c.used)
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.js", "b.js", "c.js", "big.js"} {
+		content := name + "!"
+		if name == "big.js" {
+			content = "0123456789abcdef"
+		}
+		if err := os.WriteFile(dir+"/"+name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := newCountingFS(Dir(dir))
+	h := FileServer(root, WithMemoryCache(MemoryCacheConfig{Budget: 10, MaxFileSize: 8}))
+	get := func(p string) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", p, nil)
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || rr.Body.Len() == 0 {
+			t.Fatalf("GET %s: %d %q", p, rr.Code, rr.Body.String())
+		}
+	}
+	// Each file is 5 bytes, so two fit in the budget. After a, b, a, c the
+	// least recently used is b.
+	for _, p := range []string{"/a.js", "/b.js", "/a.js", "/c.js", "/a.js", "/c.js", "/b.js", "/big.js", "/big.js"} {
+		get(p)
+	}
+	for name, expect := range map[string]int{"/a.js": 1, "/b.js": 2, "/c.js": 1, "/big.js": 2} {
+		if n := root.count(name); n != expect {
+			t.Errorf("%s opened %d times, expected %d", name, n, expect)
+		}
+	}
+
+	// Entries expire after the TTL.
+	root = newCountingFS(Dir(dir))
+	h = FileServer(root, WithMemoryCache(MemoryCacheConfig{TTL: time.Nanosecond}))
+	get("/a.js")
+	time.Sleep(time.Millisecond)
+	get("/a.js")
+	if n := root.count("/a.js"); n != 2 {
+		t.Errorf("expired entry: opened %d times, expected 2", n)
+	}
+}
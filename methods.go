@@ -0,0 +1,35 @@
+package gzipped
+
+import "net/http"
+
+// Value of the Allow header, allocated once up front.
+var allowedMethods = []string{"GET, HEAD, OPTIONS"}
+
+// WithAnyMethod serves files in response to requests of any method, as
+// earlier versions did, rather than answering OPTIONS with the allowed
+// methods and methods other than GET and HEAD with 405 Method Not Allowed.
+func WithAnyMethod() Option {
+	return func(f *fileHandler) {
+		f.anyMethod = true
+	}
+}
+
+// checkMethod responds to requests whose method isn't GET or HEAD, and
+// reports whether it did. If there's a fallback handler they're left for it,
+// as they're presumably meant for the application behind it.
+func (f *fileHandler) checkMethod(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || f.anyMethod {
+		return false
+	}
+	if f.fallback != nil {
+		f.fallback.ServeHTTP(w, r)
+		return true
+	}
+	w.Header()["Allow"] = allowedMethods
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+	serveErrorText(w, r, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return true
+}
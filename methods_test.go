@@ -0,0 +1,42 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMethods(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	for _, tc := range []struct {
+		name         string
+		h            http.Handler
+		method, path string
+		status       int
+		allow        string
+	}{
+		{"default", FileServer(Dir("./testdata/")), "GET", "/file.txt", 200, ""},
+		{"default", FileServer(Dir("./testdata/")), "HEAD", "/file.txt", 200, ""},
+		{"default", FileServer(Dir("./testdata/")), "OPTIONS", "/file.txt", 204, "GET, HEAD, OPTIONS"},
+		{"default", FileServer(Dir("./testdata/")), "POST", "/file.txt", 405, "GET, HEAD, OPTIONS"},
+		{"default", FileServer(Dir("./testdata/")), "DELETE", "/", 405, "GET, HEAD, OPTIONS"},
+		{"any", FileServer(Dir("./testdata/"), WithAnyMethod()), "POST", "/file.txt", 200, ""},
+		{"fallback", FileServerWithFallback(Dir("./testdata/"), next), "POST", "/file.txt", http.StatusTeapot, ""},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		tc.h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Header().Get("Allow") != tc.allow {
+			t.Errorf("%s: %s %s returned %d with Allow %q, expected %d %q", tc.name, tc.method, tc.path, rr.Code, rr.Header().Get("Allow"), tc.status, tc.allow)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/route", nil)
+	ServeFile(rr, req, Dir("./testdata/"), "/file.txt")
+	if rr.Code != 405 {
+		t.Errorf("ServeFile answered PUT with %d", rr.Code)
+	}
+}
@@ -0,0 +1,34 @@
+package gzipped
+
+// Metrics is told what the handler does with each request, to count how
+// often each encoding is actually served. Its methods are called from the
+// goroutines serving requests, so must be safe for concurrent use.
+// NewCounters returns one which can be scraped by Prometheus.
+type Metrics interface {
+	// Served is called for each file served, with the content-coding it
+	// was sent with, "identity" if none.
+	Served(encoding string)
+	// Fallback is called when a variant with the given encoding was
+	// negotiated but couldn't be used, so another was tried instead.
+	Fallback(encoding string)
+	// CacheLookup is called each time the memory cache is consulted for a
+	// file, reporting whether it was there.
+	CacheLookup(hit bool)
+	// NotFound is called for each request which matches no file.
+	NotFound()
+}
+
+// ShedMetrics may be implemented by a Metrics which also counts requests
+// shed by WithLoadShedding. Counters implements it.
+type ShedMetrics interface {
+	// Shed is called for each request answered 503 Service Unavailable
+	// because the handler was too busy to serve it.
+	Shed()
+}
+
+// WithMetrics reports what the handler serves to m.
+func WithMetrics(m Metrics) Option {
+	return func(f *fileHandler) {
+		f.metrics = m
+	}
+}
@@ -0,0 +1,208 @@
+package gzipped
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+const contentTypeHeader = "Content-Type"
+
+// A decoder wraps a reader of compressed data to return the decompressed
+// data. Decoders are used to look inside compressed variants, for example to
+// sniff their content type.
+type decoder func(io.Reader) (io.ReadCloser, error)
+
+// Decoders for the content encodings we can look inside. The standard
+// library has no brotli decoder.
+var decoders = map[string]decoder{
+	"gzip": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+}
+
+// Built-in MIME types for extensions which are missing from the MIME
+// databases of many systems, or which older Go versions lack. These take
+// priority over mime.TypeByExtension, so a file gets the same type whatever
+// system it's served from.
+var builtinTypes = map[string]string{
+	".avif":        "image/avif",
+	".heic":        "image/heic",
+	".heif":        "image/heif",
+	".jxl":         "image/jxl",
+	".map":         "application/json",
+	".mjs":         "text/javascript; charset=utf-8",
+	".otf":         "font/otf",
+	".ttf":         "font/ttf",
+	".wasm":        "application/wasm",
+	".webm":        "video/webm",
+	".webmanifest": "application/manifest+json",
+	".webp":        "image/webp",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+}
+
+// typeByExtension returns the MIME type for the logical (uncompressed) file
+// name, or "" if the extension doesn't have a known type.
+func typeByExtension(name string) string {
+	ext := path.Ext(name)
+	if ctype, ok := builtinTypes[strings.ToLower(ext)]; ok {
+		return ctype
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// WithContentTypes sets the MIME types of files with the given extensions,
+// overriding the built-in types and the system's MIME database:
+//
+//	gzipped.WithContentTypes(map[string]string{
+//		".md":  "text/markdown; charset=utf-8",
+//		".glb":  "model/gltf-binary",
+//	})
+//
+// The extensions are matched without regard to case, and the leading dot
+// may be left out. The empty extension gives the type of files with no
+// extension, like LICENSE, whose compressed variants would otherwise have
+// their decompressed content sniffed. Calling it again adds to the types
+// already given.
+func WithContentTypes(types map[string]string) Option {
+	m := make(map[string]string, len(types))
+	for ext, ctype := range types {
+		if ext != "" && !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		m[strings.ToLower(ext)] = ctype
+	}
+	return func(f *fileHandler) {
+		if f.contentTypes == nil {
+			f.contentTypes = make(map[string]string, len(m))
+		}
+		for ext, ctype := range m {
+			f.contentTypes[ext] = ctype
+		}
+	}
+}
+
+// WithCharset adds a charset parameter, such as "utf-8", to the types of
+// text files which don't have one, since it can't be detected from
+// compressed bytes. Text files are those with text/* types, JavaScript, and
+// JSON and XML types, including those like image/svg+xml. Types which
+// already name a charset are left alone.
+func WithCharset(charset string) Option {
+	return func(f *fileHandler) {
+		f.charset = charset
+	}
+}
+
+// typeByExtension returns the MIME type for the logical file name, using
+// the types given to WithContentTypes if there are any, and with the charset
+// of WithCharset.
+func (f *fileHandler) typeByExtension(name string) string {
+	if f.contentTypes != nil {
+		if ctype, ok := f.contentTypes[strings.ToLower(path.Ext(name))]; ok {
+			return f.addCharset(ctype)
+		}
+	}
+	return f.addCharset(typeByExtension(name))
+}
+
+// addCharset adds the charset of WithCharset to ctype, if it's a text type
+// without one.
+func (f *fileHandler) addCharset(ctype string) string {
+	if f.charset == "" || ctype == "" || strings.Contains(strings.ToLower(ctype), "charset=") {
+		return ctype
+	}
+	media, _ := cut(ctype, ';')
+	media = strings.ToLower(strings.TrimSpace(media))
+	switch {
+	case strings.HasPrefix(media, "text/"),
+		media == "application/javascript", media == "application/json", media == "application/xml",
+		strings.HasSuffix(media, "+json"), strings.HasSuffix(media, "+xml"):
+		return ctype + "; charset=" + f.charset
+	}
+	return ctype
+}
+
+// Header values for content types, so that setting Content-Type doesn't
+// allocate on every request. The set of types is bounded by the extension
+// tables and what http.DetectContentType can return. The slices are shared,
+// so nothing may modify them in place.
+var contentTypeValues sync.Map // map[string][]string
+
+func contentTypeValue(ctype string) []string {
+	if v, ok := contentTypeValues.Load(ctype); ok {
+		return v.([]string)
+	}
+	v, _ := contentTypeValues.LoadOrStore(ctype, []string{ctype})
+	return v.([]string)
+}
+
+// sniffVariant works out the content type of a compressed variant whose
+// logical name has no known type. Sniffing the compressed bytes would just
+// produce application/x-gzip or similar, so instead we sniff the start of the
+// decompressed content, either by decompressing the variant itself, or by
+// looking at the identity file or another variant we can decompress. If all
+// of those fail, the content is reported as application/octet-stream.
+func (f *fileHandler) sniffVariant(fpath string, v variant) string {
+	if dec, ok := decoders[v.encoding]; ok {
+		var r io.Reader = v.file
+		if _, ok := v.file.(*rewindFile); ok {
+			// Don't read so much that it can't be rewound.
+			r = io.LimitReader(r, maxRewind)
+		}
+		ctype, err := sniffDecoded(r, dec)
+		if _, serr := v.file.Seek(0, io.SeekStart); err == nil && serr == nil {
+			return ctype
+		}
+	}
+	if file, _, err := f.openAndStat(fpath); err == nil {
+		ctype, err := sniffDecoded(file, nil)
+		file.Close()
+		if err == nil {
+			return ctype
+		}
+	} else if file != nil {
+		file.Close()
+	}
+	for _, enc := range preferredEncodings {
+		dec, ok := decoders[enc.name]
+		fname := fpath + enc.ext
+		if !ok || enc.name == v.encoding || !f.root.Exists(fname) {
+			continue
+		}
+		file, _, err := f.openAndStat(fname)
+		if err == nil {
+			ctype, err := sniffDecoded(file, dec)
+			file.Close()
+			if err == nil {
+				return ctype
+			}
+		} else if file != nil {
+			file.Close()
+		}
+	}
+	return "application/octet-stream"
+}
+
+// sniffDecoded detects the content type of the data read from r, after
+// decompressing it with dec if it's not nil.
+func sniffDecoded(r io.Reader, dec decoder) (string, error) {
+	if dec != nil {
+		dr, err := dec(r)
+		if err != nil {
+			return "", err
+		}
+		defer dr.Close()
+		r = dr
+	}
+	var buf [sniffLen]byte
+	n, err := io.ReadFull(r, buf[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
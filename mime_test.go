@@ -0,0 +1,123 @@
+package gzipped
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestTypeByExtension(t *testing.T) {
+	for name, expect := range map[string]string{
+		"/app.wasm":          "application/wasm",
+		"/module.MJS":        "text/javascript; charset=utf-8",
+		"/img/photo.avif":    "image/avif",
+		"/fonts/x.woff2":     "font/woff2",
+		"/site.webmanifest":  "application/manifest+json",
+		"/IMG_0001.heic":     "image/heic",
+		"/style.css":         "text/css; charset=utf-8",
+		"/no-extension-here": "",
+	} {
+		if got := typeByExtension(name); got != expect {
+			t.Errorf("typeByExtension(%q) = %q, expected %q", name, got, expect)
+		}
+	}
+}
+
+func TestContentTypes(t *testing.T) {
+	dir := t.TempDir()
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("# Notes"))
+	zw.Close()
+	if err := os.WriteFile(dir+"/notes.md.gz", gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/file.TXT", []byte("text"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := FileServer(Dir(dir),
+		WithContentTypes(map[string]string{"md": "text/markdown; charset=utf-8"}),
+		WithContentTypes(map[string]string{".txt": "text/x-custom"}))
+	for _, tc := range []struct{ path, ae, expect string }{
+		{"/notes.md", "gzip", "text/markdown; charset=utf-8"},
+		{"/notes.md", "", "text/markdown; charset=utf-8"},
+		{"/file.TXT", "", "text/x-custom"},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		if ct := rr.Header().Get("Content-Type"); rr.Code != 200 || ct != tc.expect {
+			t.Errorf("GET %s (%q): %d as %q, expected %q", tc.path, tc.ae, rr.Code, ct, tc.expect)
+		}
+	}
+}
+
+func TestCharset(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"data.json":    "{}",
+		"data.json.gz": "not really gzip",
+		"icon.svg":     "<svg/>",
+		"photo.png":    "png",
+		"page.html":    "<p>hi</p>",
+		"x.custom":     "custom",
+	} {
+		if err := os.WriteFile(dir+"/"+name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := FileServer(Dir(dir), WithCharset("utf-8"),
+		WithContentTypes(map[string]string{".custom": "text/x-custom; charset=iso-8859-1"}))
+	for _, tc := range []struct{ path, ae, expect string }{
+		{"/data.json", "", "application/json; charset=utf-8"},
+		{"/data.json", "gzip", "application/json; charset=utf-8"},
+		{"/icon.svg", "", "image/svg+xml; charset=utf-8"},
+		{"/photo.png", "", "image/png"},
+		{"/page.html", "", "text/html; charset=utf-8"},
+		{"/x.custom", "", "text/x-custom; charset=iso-8859-1"},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		if ct := rr.Header().Get("Content-Type"); ct != tc.expect {
+			t.Errorf("GET %s (%q): Content-Type %q, expected %q", tc.path, tc.ae, ct, tc.expect)
+		}
+	}
+}
+
+// Compressed variants of files with no extension get the type of their
+// decompressed content, or the one given for the empty extension, rather
+// than that of the compressed bytes.
+func TestExtensionlessVariant(t *testing.T) {
+	dir := t.TempDir()
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("Permission is hereby granted, free of charge\n"))
+	zw.Close()
+	if err := os.WriteFile(dir+"/LICENSE.gz", gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		opts   []Option
+		expect string
+	}{
+		{nil, "text/plain; charset=utf-8"},
+		{[]Option{WithContentTypes(map[string]string{"": "text/markdown; charset=utf-8"})}, "text/markdown; charset=utf-8"},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/LICENSE", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		FileServer(Dir(dir), tc.opts...).ServeHTTP(rr, req)
+		if rr.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("LICENSE.gz not served: %d %v", rr.Code, rr.Header())
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != tc.expect {
+			t.Errorf("Content-Type %q, expected %q", ct, tc.expect)
+		}
+	}
+}
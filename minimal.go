@@ -0,0 +1,97 @@
+//go:build gzipped_minimal
+
+package gzipped
+
+// Building with -tags gzipped_minimal leaves out the optional caches,
+// indexes and reporting subsystems, along with their options, for
+// deployments which only need precompressed files served and care about
+// binary size. What's left here are empty stand-ins for the types the core
+// handler refers to; the handler never has one, so their methods are never
+// called.
+
+import (
+	"net/http"
+	"os"
+	"time"
+)
+
+type memCache struct{}
+
+type memFile struct{ http.File }
+
+func (*memCache) open(string) (http.File, os.FileInfo, bool) { return nil, nil, false }
+
+func (*memCache) fill(_ string, file http.File, _ os.FileInfo) http.File { return file }
+
+type mmapCache struct{}
+
+func (*mmapCache) open(_ string, file http.File, _ os.FileInfo) http.File { return file }
+
+type hotTracker struct{}
+
+func (*hotTracker) hit(*fileHandler, string) {}
+
+type variantMeta struct {
+	available encodingSet
+}
+
+func (*variantMeta) info(int) os.FileInfo { return nil }
+
+type negotiationCache struct{}
+
+func (*negotiationCache) negotiate(ae string, encs []encoding, available encodingSet, order []int) int {
+	return negotiateOrder(ae, encs, available, order)
+}
+
+type listing struct{}
+
+func (*listing) wants(*fileHandler, string) bool { return false }
+
+func (*listing) serve(*fileHandler, http.ResponseWriter, *http.Request, string) {}
+
+type statCache struct{}
+
+func (*statCache) get(string) (*variantMeta, bool) { return nil, false }
+func (*statCache) put(string, *variantMeta)        {}
+
+type prefetcher struct{}
+
+func (*prefetcher) start(*fileHandler, string) {}
+
+type reprDigests struct{}
+
+func (*reprDigests) apply(*fileHandler, http.ResponseWriter, *http.Request, string, variant) {}
+
+type dirIndex struct{}
+
+func (*dirIndex) lookup(FileSystem, string, []encoding) (encodingSet, bool) { return 0, false }
+
+// Index is not available in minimal builds.
+type Index struct{}
+
+func (*Index) available(string, []encoding) encodingSet { return 0 }
+
+func (*Index) modTime(string, string) (time.Time, bool) { return time.Time{}, false }
+
+func (*Index) stat(string, string) (int64, time.Time, bool) { return 0, time.Time{}, false }
+
+func (*Index) contentType(string) string { return "" }
+
+type buildValidators struct{}
+
+func (*buildValidators) apply(_ http.ResponseWriter, v variant) os.FileInfo { return v.info }
+
+type missingReporter struct{}
+
+func (*missingReporter) record(*http.Request) {}
+
+type archiver struct{}
+
+func (*archiver) serve(*fileHandler, http.ResponseWriter, *http.Request, string) bool { return false }
+
+// AssetMap is not available in minimal builds.
+type AssetMap struct{}
+
+func (*fileHandler) resolveAsset(_ http.ResponseWriter, _ *http.Request, fpath string) string {
+	return fpath
+}
@@ -0,0 +1,171 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MissingAsset is a path which was requested but not found, as reported by
+// WithMissingAssetReporter.
+type MissingAsset struct {
+	Path      string    `json:"path"`
+	Count     int       `json:"count"`             // requests in the batch
+	Referer   string    `json:"referer,omitempty"` // from the first request
+	FirstSeen time.Time `json:"firstSeen"`
+}
+
+// MissingAssetConfig configures WithMissingAssetReporter.
+type MissingAssetConfig struct {
+	// Report is called with each batch of missing assets, sorted by path.
+	// It's called from its own goroutine, one batch at a time. If it
+	// panics, the panic is passed to the error reporter as a *PanicError.
+	Report func([]MissingAsset)
+	// Extensions lists the file extensions, including the dot, which count
+	// as assets. The default covers scripts, style sheets, images and fonts.
+	Extensions []string
+	// Interval is how long misses are collected before being reported. The
+	// default is one minute.
+	Interval time.Duration
+	// MaxPaths is the most distinct paths collected per batch; misses for
+	// other paths are dropped until the next batch. The default is 1000.
+	MaxPaths int
+}
+
+var defaultAssetExtensions = []string{
+	".js", ".mjs", ".css", ".map", ".wasm",
+	".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".avif", ".ico",
+	".woff", ".woff2", ".ttf", ".otf",
+}
+
+// WithMissingAssetReporter collects requests for assets which the handler
+// answers with 404 Not Found, and reports them in batches, so that broken
+// references in pages are noticed soon after a deploy. Requests passed to a
+// fallback handler aren't collected, since the fallback may serve them.
+func WithMissingAssetReporter(cfg MissingAssetConfig) Option {
+	if cfg.Extensions == nil {
+		cfg.Extensions = defaultAssetExtensions
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	if cfg.MaxPaths <= 0 {
+		cfg.MaxPaths = 1000
+	}
+	exts := make(map[string]bool, len(cfg.Extensions))
+	for _, ext := range cfg.Extensions {
+		exts[strings.ToLower(ext)] = true
+	}
+	m := &missingReporter{cfg: cfg, exts: exts}
+	return func(f *fileHandler) {
+		m.owner = f
+		f.missing = m
+	}
+}
+
+// missingReporter batches up missing assets.
+type missingReporter struct {
+	owner *fileHandler // for reporting panics in Report
+	cfg   MissingAssetConfig
+	exts  map[string]bool
+	mu    sync.Mutex
+	batch map[string]*MissingAsset
+	send  sync.Mutex     // held while a batch is being reported
+	wg    sync.WaitGroup // for tests to wait for reports
+}
+
+// record notes a request which got 404 Not Found, if it was for an asset.
+func (m *missingReporter) record(r *http.Request) {
+	p := r.URL.Path
+	if !m.exts[strings.ToLower(path.Ext(p))] {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.batch == nil {
+		m.batch = make(map[string]*MissingAsset)
+		m.wg.Add(1)
+		time.AfterFunc(m.cfg.Interval, m.flush)
+	}
+	if a, ok := m.batch[p]; ok {
+		a.Count++
+		return
+	}
+	if len(m.batch) >= m.cfg.MaxPaths {
+		return
+	}
+	m.batch[p] = &MissingAsset{Path: p, Count: 1, Referer: r.Referer(), FirstSeen: time.Now()}
+}
+
+// flush reports the current batch.
+func (m *missingReporter) flush() {
+	defer m.wg.Done()
+	m.mu.Lock()
+	batch := m.batch
+	m.batch = nil
+	m.mu.Unlock()
+	assets := make([]MissingAsset, 0, len(batch))
+	for _, a := range batch {
+		assets = append(assets, *a)
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Path < assets[j].Path
+	})
+	m.send.Lock()
+	defer m.send.Unlock()
+	m.owner.callback(nil, "MissingAssetConfig.Report", func() {
+		m.cfg.Report(assets)
+	})
+}
+
+// Webhook posts batches of missing assets to a URL, as a JSON object with
+// the assets in an array named "missing". Use its Report method as the
+// Report function of a MissingAssetConfig.
+type Webhook struct {
+	URL string
+	// Client is used to make the requests. The default is a client with a
+	// ten second timeout.
+	Client *http.Client
+	// OnError, if set, is called when a batch can't be delivered.
+	OnError func(error)
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// Report posts assets to the webhook.
+func (wh Webhook) Report(assets []MissingAsset) {
+	err := wh.post(assets)
+	if err != nil && wh.OnError != nil {
+		wh.OnError(err)
+	}
+}
+
+func (wh Webhook) post(assets []MissingAsset) error {
+	body, err := json.Marshal(struct {
+		Missing []MissingAsset `json:"missing"`
+	}{assets})
+	if err != nil {
+		return err
+	}
+	client := wh.Client
+	if client == nil {
+		client = webhookClient
+	}
+	resp, err := client.Post(wh.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("gzipped: missing asset webhook returned %s", resp.Status)
+	}
+	return nil
+}
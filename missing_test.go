@@ -0,0 +1,114 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMissingAssetReporter(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]MissingAsset
+	opt := WithMissingAssetReporter(MissingAssetConfig{
+		Report: func(assets []MissingAsset) {
+			mu.Lock()
+			batches = append(batches, assets)
+			mu.Unlock()
+		},
+		Interval: 10 * time.Millisecond,
+		MaxPaths: 2,
+	})
+	h := FileServer(Dir("./testdata/"), opt).(*fileHandler)
+	for _, p := range []string{"/missing.js", "/file.txt", "/missing.js", "/page.html", "/img/x.PNG", "/third.css"} {
+		req := httptest.NewRequest(http.MethodGet, p, nil)
+		req.Header.Set("Referer", "https://example.com"+p+"?ref")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	h.missing.wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 1 {
+		t.Fatalf("got %d batches, expected 1", len(batches))
+	}
+	b := batches[0]
+	if len(b) != 2 || b[0].Path != "/img/x.PNG" || b[1].Path != "/missing.js" {
+		t.Fatalf("reported %+v", b)
+	}
+	if b[1].Count != 2 || b[1].Referer != "https://example.com/missing.js?ref" || b[1].FirstSeen.IsZero() {
+		t.Errorf("wrong details %+v", b[1])
+	}
+}
+
+func TestMissingAssetFallback(t *testing.T) {
+	called := false
+	opt := WithMissingAssetReporter(MissingAssetConfig{
+		Report:   func([]MissingAsset) { called = true },
+		Interval: time.Millisecond,
+	})
+	h := FileServerWithFallback(Dir("./testdata/"), http.NotFoundHandler(), opt).(*fileHandler)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing.js", nil))
+	h.missing.wg.Wait()
+	if called {
+		t.Error("reported a request passed to the fallback handler")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got struct {
+		Missing []MissingAsset `json:"missing"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("webhook request %s with type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		if len(got.Missing) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	var errs []error
+	wh := Webhook{URL: srv.URL, OnError: func(err error) { errs = append(errs, err) }}
+	wh.Report([]MissingAsset{{Path: "/a.js", Count: 3}})
+	if len(errs) != 0 || len(got.Missing) != 1 || got.Missing[0].Path != "/a.js" || got.Missing[0].Count != 3 {
+		t.Errorf("webhook received %+v, errors %v", got, errs)
+	}
+	wh.Report([]MissingAsset{})
+	if len(errs) != 1 {
+		t.Errorf("error status not reported")
+	}
+}
+
+func TestCallbackPanic(t *testing.T) {
+	rep := &recordingReporter{}
+	h := FileServer(Dir("./testdata/"), WithErrorReporter(rep), WithMissingAssetReporter(MissingAssetConfig{
+		Report:   func([]MissingAsset) { panic(os.ErrClosed) },
+		Interval: time.Millisecond,
+	})).(*fileHandler)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing.js", nil))
+	h.missing.wg.Wait()
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if len(rep.errs) != 1 || rep.reqs[0] != nil {
+		t.Fatalf("reported %v for requests %v", rep.errs, rep.reqs)
+	}
+	var perr *PanicError
+	if !errors.As(rep.errs[0], &perr) || perr.Callback != "MissingAssetConfig.Report" || len(perr.Stack) == 0 {
+		t.Errorf("reported %v, expected a PanicError", rep.errs[0])
+	}
+	if !errors.Is(rep.errs[0], os.ErrClosed) {
+		t.Errorf("PanicError doesn't unwrap to the panic value")
+	}
+}
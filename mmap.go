@@ -0,0 +1,106 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+)
+
+// The most files WithMmap keeps mapped.
+const maxMmaps = 1024
+
+// WithMmap serves files of at least minSize bytes, such as videos and wasm
+// bundles, from memory mappings of them rather than by reading them, so that
+// repeated range requests for a large file are copied straight from the page
+// cache without a read system call per chunk. Mappings are kept between
+// requests, up to a limit, and remade when a file's size or modification
+// time changes.
+//
+// Only files from a FileSystem which opens *os.File, such as Dir, can be
+// mapped; others, and small files, are read as usual, as is everything on
+// platforms without mmap. A mapped file which is truncated in place makes
+// the process crash when the missing part is read, so files should be
+// replaced by renaming new ones over them, as deployment tools usually do.
+func WithMmap(minSize int64) Option {
+	if minSize < 1 {
+		minSize = 1
+	}
+	return func(f *fileHandler) {
+		f.mmaps = &mmapCache{minSize: minSize, limit: perShard(maxMmaps)}
+	}
+}
+
+// mmapCache holds memory mappings of files, keyed by their names on the
+// FileSystem. The entries are off-heap memEntries, so the mapping is undone
+// once it's left the cache and the last reader has closed it.
+type mmapCache struct {
+	minSize int64
+	limit   int
+	maps    shardedMap[*memEntry]
+}
+
+// fder is implemented by *os.File.
+type fder interface {
+	Fd() uintptr
+}
+
+// open returns a file reading a mapping of file, which has just been opened
+// to be served and which info describes, if it's large enough to be worth
+// mapping and can be. Otherwise file is returned.
+func (c *mmapCache) open(name string, file http.File, info os.FileInfo) http.File {
+	if info.Size() < c.minSize || int64(int(info.Size())) != info.Size() {
+		return file
+	}
+	fd, ok := file.(fder)
+	if !ok {
+		return file
+	}
+	sh := c.maps.shard(name)
+	sh.mu.RLock()
+	e, ok := sh.m[name]
+	if ok && e.info.Size() == info.Size() && e.info.ModTime().Equal(info.ModTime()) {
+		e.acquire()
+	} else {
+		e = nil
+	}
+	sh.mu.RUnlock()
+	if e == nil {
+		// info may have come from a stat of the name, before it was opened,
+		// and mapping past the end of the file would be fatal.
+		if cur, err := file.Stat(); err != nil || cur.Size() != info.Size() {
+			return file
+		}
+		data, ok := mapFile(fd.Fd(), int(info.Size()))
+		if !ok {
+			return file
+		}
+		// One reference for the reader, and one for the cache.
+		e = &memEntry{data: data, info: info, offHeap: true, refs: 2}
+		c.add(name, e)
+	}
+	file.Close()
+	return &memFile{Reader: bytes.NewReader(e.data), entry: e}
+}
+
+// add puts e in the cache, replacing any mapping of an older version of the
+// file, or if the shard's full, another file's mapping.
+func (c *mmapCache) add(name string, e *memEntry) {
+	sh := c.maps.shard(name)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.m == nil {
+		sh.m = make(map[string]*memEntry)
+	}
+	if old, ok := sh.m[name]; ok {
+		old.release()
+	} else if len(sh.m) >= c.limit {
+		for k, old := range sh.m {
+			delete(sh.m, k)
+			old.release()
+			break
+		}
+	}
+	sh.m[name] = e
+}
@@ -0,0 +1,8 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !gzipped_minimal
+
+package gzipped
+
+// mapFile isn't supported on this platform, so files are read as usual.
+func mapFile(fd uintptr, size int) ([]byte, bool) {
+	return nil, false
+}
@@ -0,0 +1,88 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMmap(t *testing.T) {
+	dir := t.TempDir()
+	big := bytes.Repeat([]byte("0123456789"), 1000)
+	for name, data := range map[string][]byte{"big.bin": big, "big.bin.gz": big[:5000], "small.txt": []byte("small")} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := FileServer(Dir(dir), WithMmap(1000))
+	c := h.(*fileHandler).mmaps
+	get := func(path, ae, rng string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", ae)
+		req.Header.Set("Range", rng)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	for i := 0; i < 2; i++ {
+		if rec := get("/big.bin", "", "bytes=10-14"); rec.Code != http.StatusPartialContent || rec.Body.String() != "01234" {
+			t.Errorf("identity range: %d %q", rec.Code, rec.Body.String())
+		}
+		if rec := get("/big.bin", "gzip", "bytes=4995-"); rec.Code != http.StatusPartialContent || rec.Body.String() != "56789" {
+			t.Errorf("gzip range: %d %q", rec.Code, rec.Body.String())
+		}
+		if rec := get("/small.txt", "", ""); rec.Body.String() != "small" {
+			t.Errorf("small file: %q", rec.Body.String())
+		}
+	}
+	if !mmapSupported(t) {
+		return
+	}
+	e, ok := c.maps.get("/big.bin")
+	if !ok {
+		t.Fatal("big.bin wasn't mapped")
+	}
+	if _, ok := c.maps.get("/small.txt"); ok {
+		t.Error("small.txt was mapped")
+	}
+	if e.refs != 1 {
+		t.Errorf("mapping has %d references with no readers", e.refs)
+	}
+
+	// Replacing the file makes a new mapping.
+	tmp := filepath.Join(dir, "new")
+	if err := os.WriteFile(tmp, bytes.Repeat([]byte("abcdefghij"), 200), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(tmp, later, later)
+	if err := os.Rename(tmp, filepath.Join(dir, "big.bin")); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get("/big.bin", "", "bytes=10-14"); rec.Body.String() != "abcde" {
+		t.Errorf("replaced file: %q", rec.Body.String())
+	}
+	if e2, _ := c.maps.get("/big.bin"); e2 == e || e.refs != 0 {
+		t.Errorf("old mapping kept, with %d references", e.refs)
+	}
+}
+
+// mmapSupported reports whether files can be mapped on this platform.
+func mmapSupported(t *testing.T) bool {
+	f, err := os.Open("testdata/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, ok := mapFile(f.Fd(), 1)
+	if ok {
+		freeOffHeap(b)
+	}
+	return ok
+}
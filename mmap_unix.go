@@ -0,0 +1,18 @@
+//go:build (darwin || dragonfly || freebsd || linux || netbsd || openbsd) && !gzipped_minimal
+
+package gzipped
+
+import "syscall"
+
+// mapFile maps the first size bytes of the open file fd read-only, reporting
+// whether it succeeded. The mapping is undone with freeOffHeap.
+func mapFile(fd uintptr, size int) ([]byte, bool) {
+	if size == 0 {
+		return nil, false
+	}
+	b, err := syscall.Mmap(int(fd), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
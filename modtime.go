@@ -0,0 +1,80 @@
+package gzipped
+
+import (
+	"os"
+	"time"
+)
+
+// ModTimeSource selects where the modification time of a compressed variant,
+// used for Last-Modified and conditional requests, comes from.
+type ModTimeSource int
+
+const (
+	// ModTimeVariant uses the variant file's own modification time. This is
+	// the default.
+	ModTimeVariant ModTimeSource = iota
+	// ModTimeOriginal uses the modification time of the uncompressed file,
+	// if there is one, so that every representation has the same
+	// Last-Modified and recompressing files doesn't make clients download
+	// them again.
+	ModTimeOriginal
+	// ModTimeNewest uses whichever is newer of the uncompressed file and the
+	// variant.
+	ModTimeNewest
+	// ModTimeNone sends no Last-Modified for any file, compressed or not, and
+	// so ignores If-Modified-Since and If-Unmodified-Since, for deployments
+	// whose responses shouldn't depend on when files were written. ETags
+	// from WithETags are still made from the files' own modification times.
+	ModTimeNone
+)
+
+// WithModTimeSource sets where the modification times of compressed variants
+// come from.
+func WithModTimeSource(src ModTimeSource) Option {
+	return func(f *fileHandler) {
+		f.modTimeSource = src
+	}
+}
+
+// variantModTime returns the FileInfo to serve v with, according to the
+// modification time policy.
+func (f *fileHandler) variantModTime(fpath string, v variant) os.FileInfo {
+	if f.modTimeSource == ModTimeVariant || f.modTimeSource == ModTimeNone || v.encoding == identityEncoding {
+		return v.info
+	}
+	orig, ok := f.originalModTime(fpath)
+	if !ok {
+		return v.info
+	}
+	if f.modTimeSource == ModTimeNewest && orig.Before(v.info.ModTime()) {
+		return v.info
+	}
+	return modTimeInfo{FileInfo: v.info, modTime: orig}
+}
+
+// originalModTime finds the modification time of the uncompressed file fpath.
+func (f *fileHandler) originalModTime(fpath string) (time.Time, bool) {
+	if f.index != nil {
+		return f.index.modTime(fpath, identityEncoding)
+	}
+	file, err := f.root.Open(fpath)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
+// modTimeInfo overrides the modification time of a file.
+type modTimeInfo struct {
+	os.FileInfo
+	modTime time.Time
+}
+
+func (i modTimeInfo) ModTime() time.Time {
+	return i.modTime
+}
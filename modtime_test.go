@@ -0,0 +1,102 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestModTimeSource(t *testing.T) {
+	dir := t.TempDir()
+	older := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, mtime := range map[string]time.Time{
+		"orig.txt": older, "orig.txt.gz": newer, // recompressed later
+		"stale.txt": newer, "stale.txt.gz": older, // original edited later
+		"only.txt.gz": newer,
+	} {
+		full := filepath.Join(dir, name)
+		if err := os.WriteFile(full, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(full, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx, err := BuildIndex(Dir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		src    ModTimeSource
+		path   string
+		expect time.Time
+	}{
+		{ModTimeVariant, "/orig.txt", newer},
+		{ModTimeOriginal, "/orig.txt", older},
+		{ModTimeOriginal, "/stale.txt", newer},
+		{ModTimeNewest, "/orig.txt", newer},
+		{ModTimeNewest, "/stale.txt", newer},
+		{ModTimeOriginal, "/only.txt", newer},
+		{ModTimeNone, "/orig.txt", time.Time{}},
+		{ModTimeNone, "/only.txt", time.Time{}},
+	} {
+		for _, opts := range [][]Option{{WithModTimeSource(tc.src)}, {WithModTimeSource(tc.src), WithIndex(idx)}} {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			FileServer(Dir(dir), opts...).ServeHTTP(rec, req)
+			if rec.Header().Get("Content-Encoding") != "gzip" {
+				t.Fatalf("%s not served compressed", tc.path)
+			}
+			expect := ""
+			if !tc.expect.IsZero() {
+				expect = tc.expect.Format(http.TimeFormat)
+			}
+			if got := rec.Header().Get("Last-Modified"); got != expect {
+				t.Errorf("source %d, %s (%d options): Last-Modified %q, expected %q", tc.src, tc.path, len(opts), got, expect)
+			}
+		}
+	}
+}
+
+func TestModTimeNone(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "a.txt.gz"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := FileServer(Dir(dir), WithModTimeSource(ModTimeNone), WithETags())
+	for _, ae := range []string{"gzip", ""} {
+		req := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+		req.Header.Set("Accept-Encoding", ae)
+		req.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%q: If-Modified-Since answered %d", ae, rec.Code)
+		}
+		etag := rec.Header().Get("Etag")
+		if etag == "" {
+			t.Fatalf("%q: no ETag", ae)
+		}
+		req.Header.Del("If-Modified-Since")
+		req.Header.Set("If-None-Match", etag)
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("%q: If-None-Match answered %d", ae, rec.Code)
+		}
+	}
+	v, err := BestVariant(httptest.NewRequest(http.MethodGet, "/a.txt", nil), Dir(dir), "a.txt", WithModTimeSource(ModTimeNone))
+	if err != nil || !v.ModTime.IsZero() {
+		t.Errorf("BestVariant: %v, %v", v.ModTime, err)
+	}
+}
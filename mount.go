@@ -0,0 +1,71 @@
+package gzipped
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Mount returns a handler serving the files in root, with opts applied as
+// for FileServer, under the URL path prefix, such as "/static", for a
+// ServeMux or router which passes on the whole request path:
+//
+//	http.Handle("/static/", gzipped.Mount("/static", gzipped.Dir("./static")))
+//
+// The request path is cleaned before the prefix is removed, so
+// /static//app.js and /static/x/../app.js are both served /app.js, and
+// paths only sharing the prefix's first characters, such as /statics/app.js,
+// are not found, rather than served /s/app.js as with http.StripPrefix. A
+// request for the prefix itself is redirected to it with a trailing slash, so
+// that relative links in its index page work. The escaped form of the path
+// is dropped once the prefix is stripped, so percent-encoded characters in
+// the prefix or file names don't stop files being found.
+func Mount(prefix string, root FileSystem, opts ...Option) http.Handler {
+	return &mount{
+		prefix: strings.TrimSuffix(path.Clean("/"+prefix), "/"),
+		f:      FileServer(root, opts...).(*fileHandler),
+	}
+}
+
+// mount is the handler returned by Mount.
+type mount struct {
+	prefix string // cleaned, without a trailing slash; empty for the root
+	f      *fileHandler
+}
+
+func (m *mount) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upath := r.URL.Path
+	if !strings.HasPrefix(upath, "/") {
+		upath = "/" + upath
+	}
+	cpath := path.Clean(upath)
+	if strings.HasSuffix(upath, "/") && cpath != "/" {
+		cpath += "/"
+	}
+	rest := cpath
+	if m.prefix != "" {
+		switch {
+		case cpath == m.prefix:
+			if upath == cpath {
+				localRedirect(w, r, path.Base(m.prefix)+"/")
+			} else {
+				// A relative redirect from a path with dot segments could
+				// go anywhere.
+				localRedirect(w, r, m.prefix+"/")
+			}
+			return
+		case strings.HasPrefix(cpath, m.prefix+"/"):
+			rest = cpath[len(m.prefix):]
+		default:
+			m.f.notFound(w, r)
+			return
+		}
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	u := *r.URL
+	u.Path = rest
+	u.RawPath = ""
+	r2.URL = &u
+	m.f.ServeHTTP(w, r2)
+}
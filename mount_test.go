@@ -0,0 +1,40 @@
+package gzipped
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	h := Mount("/static/", Dir("./testdata/"), WithIndexFile("file.txt"))
+	for _, tc := range []struct {
+		path, enc string
+		status    int
+		location  string
+	}{
+		{"/static/file.txt", "gzip", 200, ""},
+		{"/static//file.txt", "gzip", 200, ""},
+		{"/static/x/../file.txt", "gzip", 200, ""},
+		{"/static/%66ile.txt", "gzip", 200, ""},
+		{"/static/", "gzip", 200, ""},
+		{"/static", "", 301, "static/?q=1"},
+		{"/static/.", "", 301, "/static/?q=1"},
+		{"/staticfile.txt", "", 404, ""},
+		{"/static/../file.txt", "", 404, ""},
+		{"/file.txt", "", 404, ""},
+	} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", tc.path+"?q=1", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Header().Get("Content-Encoding") != tc.enc || rr.Header().Get("Location") != tc.location {
+			t.Errorf("GET %s: %d with headers %v", tc.path, rr.Code, rr.Header())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	Mount("/", Dir("./testdata/")).ServeHTTP(rr, httptest.NewRequest("GET", "/file2.txt", nil))
+	if rr.Code != 200 {
+		t.Errorf("mounted at the root: %d", rr.Code)
+	}
+}
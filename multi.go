@@ -0,0 +1,123 @@
+package gzipped
+
+import (
+	"context"
+	"errors"
+	fs2 "io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Multi returns a FileSystem which layers the given FileSystems, earlier
+// ones over later ones, such as an override directory over an embed.FS of
+// defaults:
+//
+//	root := gzipped.Multi(gzipped.Dir("/etc/myapp/static"), gzipped.FS(defaults))
+//
+// A file and its compressed variants are always taken from the same layer:
+// the first with any of them. So an override of app.js hides app.js.gz and
+// app.js.br in the layers under it, rather than the stale defaults being
+// negotiated in its place.
+//
+// Multi implements DirReader, merging the listings of the layers which do.
+func Multi(layers ...FileSystem) FileSystem {
+	return multiFS(append([]FileSystem(nil), layers...))
+}
+
+type multiFS []FileSystem
+
+// layer returns the index of the layer which has name or another
+// representation of the same file, or -1 if none does.
+func (m multiFS) layer(name string) int {
+	encs := preferredEncodings
+	var names [maxEncodings]string
+	variantNames(originalName(name, encs), encs, names[:])
+	for i, l := range m {
+		if l.Exists(name) {
+			return i
+		}
+		for _, n := range names[:len(encs)] {
+			if n != name && l.Exists(n) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// originalName returns name without any variant extension.
+func originalName(name string, encs []encoding) string {
+	for _, enc := range encs {
+		if enc.ext != "" && strings.HasSuffix(name, enc.ext) {
+			return strings.TrimSuffix(name, enc.ext)
+		}
+	}
+	return name
+}
+
+// Exists reports whether name exists in the layer its file is taken from.
+func (m multiFS) Exists(name string) bool {
+	i := m.layer(name)
+	return i >= 0 && m[i].Exists(name)
+}
+
+// Open opens name from the layer its file is taken from. Directories, which
+// have no variants, are opened from the first layer which has them.
+func (m multiFS) Open(name string) (http.File, error) {
+	return m.OpenContext(context.Background(), name)
+}
+
+// OpenContext is Open, passing ctx on to the layers which implement
+// ContextOpener.
+func (m multiFS) OpenContext(ctx context.Context, name string) (http.File, error) {
+	if i := m.layer(name); i >= 0 {
+		return openContext(ctx, m[i], name)
+	}
+	for _, l := range m {
+		file, err := openContext(ctx, l, name)
+		if !errors.Is(err, os.ErrNotExist) {
+			return file, err
+		}
+	}
+	return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+}
+
+// ReadDir merges the listings of name from the layers which implement
+// DirReader, leaving out files hidden by another layer.
+func (m multiFS) ReadDir(name string) ([]fs2.DirEntry, error) {
+	seen := map[string]bool{}
+	var entries []fs2.DirEntry
+	var firstErr error
+	found := false
+	for i, l := range m {
+		dr, ok := l.(DirReader)
+		if !ok {
+			continue
+		}
+		des, err := dr.ReadDir(name)
+		if err != nil {
+			if firstErr == nil && !errors.Is(err, os.ErrNotExist) {
+				firstErr = err
+			}
+			continue
+		}
+		found = true
+		for _, de := range des {
+			if !seen[de.Name()] && (de.IsDir() || m.layer(path.Join("/", name, de.Name())) == i) {
+				seen[de.Name()] = true
+				entries = append(entries, de)
+			}
+		}
+	}
+	if !found {
+		if firstErr == nil {
+			firstErr = &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+		}
+		return nil, firstErr
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
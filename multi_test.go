@@ -0,0 +1,60 @@
+package gzipped
+
+import (
+	fs2 "io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestMulti(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/file.txt", []byte("override\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := fs2.Sub(testData, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := Multi(Dir(dir), FS(sub))
+	h := FileServer(root)
+	for _, tc := range []struct {
+		path, ae, encoding, body string
+		status                   int
+	}{
+		{"/file.txt", "gzip", "", "override\n", 200},
+		{"/file2.txt", "gzip", "", "1234567890987654321\n", 200},
+		{"/app.js", "br", "br", "", 200},
+		{"/nonexistent.txt", "gzip", "", "", 404},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Header().Get("Content-Encoding") != tc.encoding || (tc.body != "" && rr.Body.String() != tc.body) {
+			t.Errorf("GET %s (%q): %d %q %q", tc.path, tc.ae, rr.Code, rr.Header().Get("Content-Encoding"), rr.Body.String())
+		}
+	}
+	if root.Exists("/file.txt.gz") {
+		t.Error("variant hidden by an override reported as existing")
+	}
+
+	entries, err := root.(DirReader).ReadDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, de := range entries {
+		names = append(names, de.Name())
+	}
+	expect := []string{"app.js.br", "app.js.gz", "brotli.css.br", "file.txt", "file2.txt", "page.br", "page.gz"}
+	if len(names) != len(expect) {
+		t.Fatalf("ReadDir returned %q, expected %q", names, expect)
+	}
+	for i := range names {
+		if names[i] != expect[i] {
+			t.Fatalf("ReadDir returned %q, expected %q", names, expect)
+		}
+	}
+}
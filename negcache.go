@@ -0,0 +1,82 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"strings"
+	"sync"
+)
+
+// The default number of negotiation results WithNegotiationCache keeps.
+const defaultNegotiationEntries = 1024
+
+// WithNegotiationCache remembers the outcome of content negotiation for each
+// combination of Accept-Encoding header and set of available variants, so
+// that the header isn't parsed again on every request. Most traffic comes
+// from a handful of browsers sending a handful of header values, so even a
+// small cache catches nearly everything. maxEntries limits how many outcomes
+// are kept, 1024 if it's zero or less; when the cache fills up, part of it is
+// emptied to make room.
+//
+// Headers are compared exactly, so "gzip, br" and "br,gzip" are cached
+// separately. With WithSmallestVariant, ties are broken by the sizes of each
+// file's variants, and the cache isn't used.
+func WithNegotiationCache(maxEntries int) Option {
+	if maxEntries <= 0 {
+		maxEntries = defaultNegotiationEntries
+	}
+	return func(f *fileHandler) {
+		f.negotiations = &negotiationCache{limit: perShard(maxEntries)}
+	}
+}
+
+// negotiationKey identifies a negotiation. The encodings and tie-breaking
+// order are identified by their first elements, since they're only ever
+// replaced, never modified.
+type negotiationKey struct {
+	ae        string
+	available encodingSet
+	encs      *encoding
+	order     *int
+}
+
+// negotiationCache maps negotiations to the index of the encoding chosen.
+type negotiationCache struct {
+	limit  int
+	shards [cacheShards]negotiationShard
+}
+
+type negotiationShard struct {
+	mu sync.RWMutex
+	m  map[negotiationKey]int
+	_  [32]byte
+}
+
+// negotiate returns negotiateOrder(ae, encs, available, order), from the
+// cache if it's there.
+func (c *negotiationCache) negotiate(ae string, encs []encoding, available encodingSet, order []int) int {
+	if len(ae) > maxAcceptEncodingLen || len(encs) == 0 {
+		return negotiateOrder(ae, encs, available, order)
+	}
+	key := negotiationKey{ae: ae, available: available, encs: &encs[0]}
+	if len(order) > 0 {
+		key.order = &order[0]
+	}
+	sh := &c.shards[fnv32(ae)%cacheShards]
+	sh.mu.RLock()
+	i, ok := sh.m[key]
+	sh.mu.RUnlock()
+	if ok {
+		return i
+	}
+	i = negotiateOrder(ae, encs, available, order)
+	// Copy the header, so the cache doesn't keep the request alive.
+	key.ae = strings.Clone(ae)
+	sh.mu.Lock()
+	if sh.m == nil || len(sh.m) >= c.limit {
+		sh.m = make(map[negotiationKey]int)
+	}
+	sh.m[key] = i
+	sh.mu.Unlock()
+	return i
+}
@@ -0,0 +1,85 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiationCache(t *testing.T) {
+	c := &negotiationCache{limit: 2}
+	encs := preferredEncodings
+	all := encodingSet(1<<uint(len(encs)) - 1)
+	order := encodingOrder(encs, []string{"gzip"})
+	for n := 0; n < 3; n++ {
+		for _, tc := range []struct {
+			ae        string
+			available encodingSet
+			order     []int
+		}{
+			{"gzip, deflate, br", all, nil},
+			{"gzip, deflate, br", all, order},
+			{"gzip, deflate, br", all &^ 1, nil},
+			{"gzip;q=0.5, br", all, nil},
+			{"identity;q=0", 1 << uint(len(encs)-1), nil},
+			{"*", all, order},
+		} {
+			expect := negotiateOrder(tc.ae, encs, tc.available, tc.order)
+			if got := c.negotiate(tc.ae, encs, tc.available, tc.order); got != expect {
+				t.Errorf("%q, %b, %v: got %d, expected %d", tc.ae, tc.available, tc.order, got, expect)
+			}
+		}
+	}
+	for i := range c.shards {
+		if n := len(c.shards[i].m); n > c.limit {
+			t.Errorf("shard %d has %d entries, limit %d", i, n, c.limit)
+		}
+	}
+}
+
+func TestWithNegotiationCache(t *testing.T) {
+	h := FileServer(Dir("./testdata/"), WithNegotiationCache(0)).(*fileHandler)
+	for n := 0; n < 2; n++ {
+		for _, tc := range []struct{ ae, expect string }{
+			{"gzip, br", "gzip"},
+			{"br", ""},
+			{"gzip;q=0", ""},
+		} {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/file.txt", nil)
+			req.Header.Set("Accept-Encoding", tc.ae)
+			h.ServeHTTP(rr, req)
+			if got := rr.Header().Get("Content-Encoding"); got != tc.expect {
+				t.Errorf("Accept-Encoding %q: Content-Encoding %q, expected %q", tc.ae, got, tc.expect)
+			}
+		}
+	}
+	entries := 0
+	for i := range h.negotiations.shards {
+		entries += len(h.negotiations.shards[i].m)
+	}
+	if entries != 3 {
+		t.Errorf("%d negotiations cached, expected 3", entries)
+	}
+}
+
+func BenchmarkNegotiationCache(b *testing.B) {
+	const ae = "gzip, deflate, br, zstd"
+	encs := preferredEncodings
+	all := encodingSet(1<<uint(len(encs)) - 1)
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			negotiateOrder(ae, encs, all, nil)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		c := &negotiationCache{limit: 16}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c.negotiate(ae, encs, all, nil)
+		}
+	})
+}
@@ -0,0 +1,290 @@
+package gzipped
+
+import (
+	"net/http"
+	"strings"
+)
+
+// encoding describes a content encoding which files may be precompressed with.
+type encoding struct {
+	name string // content-coding token, as used in Accept-Encoding
+	ext  string // extension appended to the file name of variants
+	// Content-Encoding header value, allocated once up front rather than on
+	// every request. Nothing may modify it in place.
+	header []string
+}
+
+func newEncoding(name, ext string) encoding {
+	return encoding{name: name, ext: ext, header: []string{name}}
+}
+
+const identityEncoding = "identity"
+
+// List of encodings we would prefer to use, in order of preference, best first,
+// with identity last. It's replaced by RegisterEncoding and UnregisterEncoding.
+var preferredEncodings = []encoding{
+	newEncoding("br", ".br"),
+	newEncoding("zstd", ".zst"),
+	newEncoding("gzip", ".gz"),
+	newEncoding(identityEncoding, ""),
+}
+
+// The maximum number of encodings which can be negotiated between, set by the
+// width of encodingSet.
+const maxEncodings = 32
+
+// encodingSet records which members of a list of encodings are available, as
+// a bitmask indexed by position in the list.
+type encodingSet uint32
+
+func (s encodingSet) has(i int) bool {
+	return s&(1<<uint(i)) != 0
+}
+
+// indexOfEncoding returns the position of the named encoding in encs, or -1.
+func indexOfEncoding(encs []encoding, name string) int {
+	for i := range encs {
+		if encs[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// variantNames fills names with the file name of each encoding's variant of
+// fpath. The names are all slices of one string, so there's a single
+// allocation however many encodings there are.
+func variantNames(fpath string, encs []encoding, names []string) {
+	n := 0
+	for i := range encs {
+		n += len(fpath) + len(encs[i].ext)
+	}
+	var b strings.Builder
+	b.Grow(n)
+	for i := range encs {
+		if encs[i].ext != "" {
+			b.WriteString(fpath)
+			b.WriteString(encs[i].ext)
+		}
+	}
+	s := b.String()
+	for i := range encs {
+		if encs[i].ext == "" {
+			names[i] = fpath
+			continue
+		}
+		n = len(fpath) + len(encs[i].ext)
+		names[i], s = s[:n], s[n:]
+	}
+}
+
+// Limits on the Accept-Encoding header, so that a hostile client can't make
+// negotiation do unbounded work or pick something unexpected. Real clients
+// send a handful of codings in well under a hundred bytes.
+const (
+	// Headers longer than this are ignored altogether, as if absent, so the
+	// identity encoding is used.
+	maxAcceptEncodingLen = 1024
+	// Codings after this many list elements are ignored.
+	maxAcceptEncodingCodings = 16
+	// Codings with more than this many parameters are ignored as malformed.
+	maxCodingParams = 4
+)
+
+// negotiate picks the best of the available encodings according to the
+// Accept-Encoding header value ae, returning its index in encs. The client's
+// q-values decide, and ties are broken by the order of encs, i.e. by server
+// preference. If none of the available encodings is acceptable, the index of
+// identity is returned unless the client has explicitly refused it, since
+// identity is always acceptable otherwise (RFC 9110 section 12.5.3). If even
+// that is ruled out, the result is -1.
+//
+// Negotiation scans the header in place, and doesn't allocate. Headers which
+// exceed the limits above are handled deterministically: one that is too long
+// is ignored, and elements past the maximum count are ignored.
+func negotiate(ae string, encs []encoding, available encodingSet) int {
+	return negotiateOrder(ae, encs, available, nil)
+}
+
+// Negotiate picks the content-coding to send in response to r out of
+// available, the names of the codings the response could be sent with, most
+// preferred first, using the same rules as FileServer. The coding with the
+// highest q-value in r's Accept-Encoding header wins, and ties go to the one
+// earliest in available. Matching is case-insensitive, and the name is
+// returned as it appears in available, except that identity is always
+// returned in lower case.
+//
+// Identity needn't be listed, as it's always acceptable unless the client
+// refuses it (RFC 9110 section 12.5.3): it's returned when the client
+// accepts none of available. Listing it puts it in order of preference
+// against the others. If the client refuses everything, including identity,
+// the result is "", and the response should be 406 Not Acceptable.
+//
+// Headers longer than 1024 bytes are ignored, as if absent, and only the
+// first 16 codings a header lists are considered. Codings past the 31st in
+// available are ignored.
+func Negotiate(r *http.Request, available []string) string {
+	var encs [maxEncodings]encoding
+	var set encodingSet
+	n, haveIdentity := 0, false
+	for _, name := range available {
+		if n == maxEncodings-1 {
+			break
+		}
+		if name == "" {
+			continue
+		}
+		if strings.EqualFold(name, identityEncoding) {
+			name, haveIdentity = identityEncoding, true
+		}
+		encs[n] = encoding{name: name}
+		set |= 1 << uint(n)
+		n++
+	}
+	if !haveIdentity {
+		// Unavailable, so only chosen as the last resort.
+		encs[n] = encoding{name: identityEncoding}
+		n++
+	}
+	i := negotiate(r.Header.Get(acceptEncodingHeader), encs[:n], set)
+	if i < 0 {
+		return ""
+	}
+	return encs[i].name
+}
+
+// negotiateOrder is negotiate, but with ties broken by order, a permutation
+// of the indexes of encs, rather than by the order of encs itself. A nil
+// order means the order of encs.
+func negotiateOrder(ae string, encs []encoding, available encodingSet, order []int) int {
+	if len(ae) > maxAcceptEncodingLen {
+		return indexOfEncoding(encs, identityEncoding)
+	}
+	// q-values in thousandths, or -1 if the client didn't mention it.
+	var qs [maxEncodings]int
+	for i := range encs {
+		qs[i] = -1
+	}
+	star, identQ := -1, -1
+	for n := 0; ae != "" && n < maxAcceptEncodingCodings; n++ {
+		var elem string
+		elem, ae = cut(ae, ',')
+		name, params := cut(elem, ';')
+		name = trimOWS(name)
+		if name == "" {
+			continue
+		}
+		q := 1000
+		nparams := 0
+		for params != "" && nparams <= maxCodingParams {
+			var param string
+			param, params = cut(params, ';')
+			param = trimOWS(param)
+			nparams++
+			if len(param) >= 2 && (param[0] == 'q' || param[0] == 'Q') && param[1] == '=' {
+				q = parseQ(param[2:])
+			}
+		}
+		if nparams > maxCodingParams {
+			continue
+		}
+		if name == "*" {
+			star = q
+			continue
+		}
+		if strings.EqualFold(name, identityEncoding) {
+			identQ = q
+		}
+		for i := range encs {
+			if strings.EqualFold(name, encs[i].name) {
+				qs[i] = q
+			}
+		}
+	}
+
+	best, bestQ := -1, 0
+	for k := range encs {
+		i := k
+		if order != nil {
+			i = order[k]
+		}
+		if !available.has(i) {
+			continue
+		}
+		q := qs[i]
+		if q < 0 {
+			q = star
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	if best >= 0 {
+		return best
+	}
+	if identQ < 0 {
+		identQ = star
+	}
+	if identQ != 0 {
+		return indexOfEncoding(encs, identityEncoding)
+	}
+	return -1
+}
+
+// negotiate is negotiateOrder for the Accept-Encoding header ae of r, using
+// the handler's Negotiator or negotiation cache if it has one.
+func (f *fileHandler) negotiate(r *http.Request, ae string, encs []encoding, available encodingSet, order []int) int {
+	if f.negotiator != nil {
+		return f.negotiateWith(r, encs, available, order)
+	}
+	if f.negotiations == nil || f.smallest {
+		return negotiateOrder(ae, encs, available, order)
+	}
+	return f.negotiations.negotiate(ae, encs, available, order)
+}
+
+// cut slices s around the first instance of sep.
+func cut(s string, sep byte) (before, after string) {
+	if i := strings.IndexByte(s, sep); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// trimOWS trims optional whitespace (spaces and tabs) from s.
+func trimOWS(s string) string {
+	for len(s) > 0 && (s[0] == ' ' || s[0] == '\t') {
+		s = s[1:]
+	}
+	for len(s) > 0 && (s[len(s)-1] == ' ' || s[len(s)-1] == '\t') {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// parseQ parses a qvalue (RFC 9110 section 12.4.2), returning it in
+// thousandths. Malformed values are treated as 0, i.e. "not acceptable".
+func parseQ(s string) int {
+	if s == "" || (s[0] != '0' && s[0] != '1') {
+		return 0
+	}
+	q := int(s[0]-'0') * 1000
+	if len(s) == 1 {
+		return q
+	}
+	if s[1] != '.' || len(s) > 5 {
+		return 0
+	}
+	scale := 100
+	for _, c := range s[2:] {
+		if c < '0' || c > '9' {
+			return 0
+		}
+		q += int(c-'0') * scale
+		scale /= 10
+	}
+	if q > 1000 {
+		return 0
+	}
+	return q
+}
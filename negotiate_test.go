@@ -0,0 +1,120 @@
+package gzipped
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	encs := preferredEncodings
+	bit := func(name string) encodingSet { return 1 << uint(indexOfEncoding(encs, name)) }
+	br, zst, gz, id := bit("br"), bit("zstd"), bit("gzip"), bit(identityEncoding)
+	for _, tc := range []struct {
+		ae        string
+		available encodingSet
+		expect    string // "" means -1
+	}{
+		{"gzip", br | gz | id, "gzip"},
+		{"br", br | gz | id, "br"},
+		{"gzip, br", br | gz | id, "br"},
+		{"gzip;q=1.0, br;q=0.999", br | gz | id, "gzip"},
+		{"GZIP", gz | id, "gzip"},
+		{" gzip ;  q=0.5 , br;q=0", br | gz | id, "gzip"},
+		{"*", gz, "gzip"},
+		{"*;q=0", gz, ""},
+		{"*;q=0, identity", gz, "identity"},
+		{"deflate", br | gz, "identity"},
+		{"br", gz, "identity"},
+		{"br, identity;q=0", gz, ""},
+		{"gzip;q=0", gz | id, "identity"},
+		{"gzip;q=2", gz | id, "identity"},
+		{"gzip;q=0.5000", gz | id, "identity"},
+		{"gzip;q=abc", gz | id, "identity"},
+		{"gzip;level=9;q=0.5", gz | id, "gzip"},
+		{",,gzip,,", gz, "gzip"},
+		{"gzip;q=0.1, gzip;q=0", gz | id, "identity"},
+		// Limits
+		{"gzip, " + strings.Repeat(" ", maxAcceptEncodingLen), gz | id, "identity"},
+		{"gzip, " + strings.Repeat(" ", maxAcceptEncodingLen) + ", identity;q=0", gz, "identity"},
+		{strings.Repeat(",", maxAcceptEncodingCodings-1) + "gzip", gz | id, "gzip"},
+		{strings.Repeat(",", maxAcceptEncodingCodings) + "gzip", gz | id, "identity"},
+		{strings.Repeat("x, ", maxAcceptEncodingCodings) + "*;q=0", gz, "identity"},
+		{"gzip;a;b;c;q=0.5", gz | id, "gzip"},
+		{"gzip;a;b;c;d;q=0.5, br", br | gz | id, "br"},
+		{"br;a;b;c;d;e, gzip;q=0.5", br | gz | id, "gzip"},
+		// zstd
+		{"zstd", br | zst | gz | id, "zstd"},
+		{"gzip, zstd, br", br | zst | gz | id, "br"},
+		{"gzip, zstd", br | zst | gz | id, "zstd"},
+		{"zstd;q=0.5, gzip", zst | gz | id, "gzip"},
+		{"zstd", gz | id, "identity"},
+	} {
+		i := negotiate(tc.ae, encs, tc.available)
+		got := ""
+		if i >= 0 {
+			got = encs[i].name
+		}
+		if got != tc.expect {
+			t.Errorf("negotiate(%q, %04b) = %q, expected %q", tc.ae, tc.available, got, tc.expect)
+		}
+	}
+}
+
+func TestNegotiateExported(t *testing.T) {
+	for _, tc := range []struct {
+		ae        string
+		available []string
+		expect    string
+	}{
+		{"gzip, br", []string{"br", "gzip"}, "br"},
+		{"gzip, br", []string{"gzip", "br"}, "gzip"},
+		{"gzip;q=0.5, br;q=0.4", []string{"br", "gzip"}, "gzip"},
+		{"GZIP", []string{"gzip"}, "gzip"},
+		{"gzip", []string{"GZip"}, "GZip"},
+		{"deflate", []string{"br", "gzip"}, "identity"},
+		{"", []string{"gzip"}, "identity"},
+		{"gzip, identity", []string{"Identity", "gzip"}, "identity"},
+		{"br, identity;q=0", []string{"gzip"}, ""},
+		{"*;q=0", nil, ""},
+		{"zstd", []string{"", "zstd"}, "zstd"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		if got := Negotiate(req, tc.available); got != tc.expect {
+			t.Errorf("Negotiate(%q, %q) = %q, expected %q", tc.ae, tc.available, got, tc.expect)
+		}
+	}
+}
+
+func TestNegotiateAllocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		negotiate("gzip, deflate, br;q=0.9, *;q=0.1", preferredEncodings, 1<<uint(len(preferredEncodings))-1)
+	})
+	if allocs != 0 {
+		t.Errorf("negotiate allocated %v times per run", allocs)
+	}
+}
+
+func TestVariantNames(t *testing.T) {
+	var names [maxEncodings]string
+	variantNames("/css/site.css", preferredEncodings, names[:])
+	for i, expect := range []string{"/css/site.css.br", "/css/site.css.zst", "/css/site.css.gz", "/css/site.css"} {
+		if names[i] != expect {
+			t.Errorf("variant name %d is %q, expected %q", i, names[i], expect)
+		}
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		variantNames("/css/site.css", preferredEncodings, names[:])
+	})
+	if allocs > 1 {
+		t.Errorf("variantNames allocated %v times per run", allocs)
+	}
+}
+
+func BenchmarkNegotiate(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		negotiate("gzip, deflate, br", preferredEncodings, 1<<uint(len(preferredEncodings))-1)
+	}
+}
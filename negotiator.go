@@ -0,0 +1,76 @@
+package gzipped
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Negotiator chooses the content-coding a file is sent with. Negotiate is
+// given the request and the names of the codings the file has variants for,
+// most preferred first, including "identity" if the uncompressed file
+// exists, and returns one of them. The package's Negotiate function is the
+// built-in negotiation.
+//
+// Returning "identity" serves the uncompressed file, decompressing a variant
+// on the fly if there isn't one, as does returning a coding which isn't
+// available. Returning "" means the client accepts none of them, including
+// identity, which FileServer handles as it does when the built-in
+// negotiation refuses everything.
+type Negotiator interface {
+	Negotiate(r *http.Request, available []string) string
+}
+
+// NegotiatorFunc adapts a function to a Negotiator, so that the built-in
+// negotiation can be wrapped with quirks:
+//
+//	gzipped.WithNegotiator(gzipped.NegotiatorFunc(func(r *http.Request, available []string) string {
+//		if brokenBrotli(r.UserAgent()) {
+//			available = without(available, "br")
+//		}
+//		return gzipped.Negotiate(r, available)
+//	}))
+type NegotiatorFunc func(r *http.Request, available []string) string
+
+// Negotiate calls fn(r, available).
+func (fn NegotiatorFunc) Negotiate(r *http.Request, available []string) string {
+	return fn(r, available)
+}
+
+// WithNegotiator chooses the encoding of each file with n rather than by the
+// rules of RFC 9110, for quirks such as ignoring "*" or avoiding an
+// encoding for particular clients. n is only called for requests with an
+// Accept-Encoding header, as others are always sent identity. Since n might
+// look at more of the request than Accept-Encoding, WithNegotiationCache has
+// no effect, and the options setting the server's preference, such as
+// WithEncodingOrder, only set the order of the names n is given.
+func WithNegotiator(n Negotiator) Option {
+	return func(f *fileHandler) {
+		f.negotiator = n
+	}
+}
+
+// negotiateWith asks the handler's Negotiator to choose between the
+// available encodings, returning the index of its choice in encs as
+// negotiateOrder would.
+func (f *fileHandler) negotiateWith(r *http.Request, encs []encoding, available encodingSet, order []int) int {
+	names := make([]string, 0, len(encs))
+	for k := range encs {
+		i := k
+		if order != nil {
+			i = order[k]
+		}
+		if available.has(i) {
+			names = append(names, encs[i].name)
+		}
+	}
+	name := f.negotiator.Negotiate(r, names)
+	if name == "" {
+		return -1
+	}
+	for i := range encs {
+		if available.has(i) && strings.EqualFold(encs[i].name, name) {
+			return i
+		}
+	}
+	return indexOfEncoding(encs, identityEncoding)
+}
@@ -0,0 +1,53 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithNegotiator(t *testing.T) {
+	var offered [][]string
+	n := NegotiatorFunc(func(r *http.Request, available []string) string {
+		offered = append(offered, available)
+		if strings.Contains(r.UserAgent(), "NoBrotli") {
+			var rest []string
+			for _, name := range available {
+				if name != "br" {
+					rest = append(rest, name)
+				}
+			}
+			available = rest
+		}
+		if r.Header.Get("Accept-Encoding") == "*" {
+			// Ignore "*", as some broken clients send it.
+			return "identity"
+		}
+		return Negotiate(r, available)
+	})
+	h := FileServer(Dir("./testdata/"), WithNegotiator(n))
+	for _, tc := range []struct {
+		path, ae, ua, enc string
+		status            int
+	}{
+		{"/app.js", "br, gzip", "", "br", 200},
+		{"/app.js", "br, gzip", "NoBrotli/1.0", "gzip", 200},
+		{"/file.txt", "*", "", "", 200},
+		{"/app.js", "*", "", "", 200},
+		{"/file.txt", "gzip", "", "gzip", 200},
+		{"/file.txt", "", "", "", 200},
+	} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		req.Header.Set("User-Agent", tc.ua)
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Header().Get("Content-Encoding") != tc.enc {
+			t.Errorf("GET %s with %q from %q: %d with Content-Encoding %q", tc.path, tc.ae, tc.ua, rr.Code, rr.Header().Get("Content-Encoding"))
+		}
+	}
+	if len(offered) != 5 || strings.Join(offered[0], ",") != "br,gzip" || strings.Join(offered[2], ",") != "gzip,identity" {
+		t.Errorf("negotiator offered %q", offered)
+	}
+}
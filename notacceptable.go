@@ -0,0 +1,30 @@
+package gzipped
+
+import "net/http"
+
+// WithNotAcceptable responds 406 Not Acceptable when a client refuses the
+// identity encoding, as with "Accept-Encoding: br, identity;q=0", and none
+// of the file's variants is one it accepts, rather than sending the
+// uncompressed file regardless. Without it, the refusal is ignored, since
+// many clients which send it would rather have the file than an error.
+//
+// h responds to those requests and to those for files which exist only in
+// encodings the client doesn't accept, in place of the plain text 406
+// response, and is expected to set the status itself. It can be nil. The
+// response has Vary: Accept-Encoding set before h is called.
+func WithNotAcceptable(h http.Handler) Option {
+	return func(f *fileHandler) {
+		f.strictIdentity = true
+		f.notAcceptable = h
+	}
+}
+
+// serveNotAcceptable responds 406 Not Acceptable.
+func (f *fileHandler) serveNotAcceptable(w http.ResponseWriter, r *http.Request) {
+	addVary(w.Header(), acceptEncodingHeader)
+	if f.notAcceptable != nil {
+		f.notAcceptable.ServeHTTP(w, r)
+		return
+	}
+	serveErrorText(w, r, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+}
@@ -0,0 +1,49 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithNotAcceptable(t *testing.T) {
+	page := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotAcceptable)
+		w.Write([]byte("try gzip"))
+	})
+	for _, tc := range []struct {
+		path, ae, enc, body string
+		status              int
+		handler             http.Handler
+	}{
+		{"/file2.txt", "br, identity;q=0", "", "Not Acceptable\n", 406, nil},
+		{"/file2.txt", "br, identity;q=0", "", "try gzip", 406, page},
+		{"/file2.txt", "*;q=0", "", "try gzip", 406, page},
+		{"/file.txt", "br, identity;q=0", "", "try gzip", 406, page},
+		{"/app.js", "deflate, identity;q=0", "", "try gzip", 406, page},
+		{"/file.txt", "gzip, identity;q=0", "gzip", "", 200, page},
+		{"/file.txt", "br", "", "", 200, page},
+		{"/file2.txt", "", "", "", 200, page},
+	} {
+		h := FileServer(Dir("./testdata/"), WithNotAcceptable(tc.handler))
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Header().Get("Content-Encoding") != tc.enc || tc.body != "" && rr.Body.String() != tc.body {
+			t.Errorf("GET %s with %q: %d with Content-Encoding %q and body %q", tc.path, tc.ae, rr.Code, rr.Header().Get("Content-Encoding"), rr.Body)
+		}
+		if rr.Code == 406 && rr.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("GET %s with %q: Vary %q", tc.path, tc.ae, rr.Header()["Vary"])
+		}
+	}
+
+	// Without the option, the refusal is ignored.
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/file2.txt", nil)
+	req.Header.Set("Accept-Encoding", "br, identity;q=0")
+	FileServer(Dir("./testdata/")).ServeHTTP(rr, req)
+	if rr.Code != 200 {
+		t.Errorf("without WithNotAcceptable: %d", rr.Code)
+	}
+}
@@ -0,0 +1,82 @@
+package gzipped
+
+import (
+	"io"
+	"net/http"
+)
+
+// WithNotFoundHandler sets a handler to respond to requests which don't
+// match a file, in place of the plain text 404 response. Unlike the next
+// handler of FileServerWithFallback, h is expected to respond 404 itself,
+// and missing files are still reported to WithMissingAssetReporter. See
+// NotFoundPage for serving a custom page.
+func WithNotFoundHandler(h http.Handler) Option {
+	return func(f *fileHandler) {
+		f.notFoundHandler = h
+	}
+}
+
+// NotFoundPage returns a handler which serves the file name from root, such
+// as "/404.html", with status 404 Not Found. Its compressed variants are
+// negotiated as FileServer does, and opts are passed on to FileServer.
+// Range and conditional headers are ignored, as they apply to the resource
+// which was requested rather than the error page.
+func NotFoundPage(root FileSystem, name string, opts ...Option) http.Handler {
+	fs := FileServer(root, opts...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = name
+		r2.URL.RawPath = ""
+		for _, h := range []string{rangeHeader, "If-Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+			delete(r2.Header, h)
+		}
+		fs.ServeHTTP(&statusWriter{ResponseWriter: w, status: http.StatusNotFound}, r2)
+	})
+}
+
+// statusWriter replaces a 200 OK status with another one.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader && code >= 200 {
+		sw.wroteHeader = true
+		if code == http.StatusOK {
+			code = sw.status
+		}
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(p)
+}
+
+// ReadFrom lets the underlying writer send files efficiently, if it can.
+func (sw *statusWriter) ReadFrom(src io.Reader) (int64, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	if rf, ok := sw.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	return io.Copy(writerOnly{sw.ResponseWriter}, src)
+}
+
+// Flush passes flushes through to the underlying writer, if it supports them.
+func (sw *statusWriter) Flush() {
+	if fl, ok := sw.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,58 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestNotFoundPage(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"404.html":    "<h1>Lost?</h1>",
+		"404.html.gz": "not really gzip",
+	} {
+		if err := os.WriteFile(dir+"/"+name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var missing int
+	page := NotFoundPage(Dir(dir), "/404.html")
+	h := FileServer(Dir("./testdata/"),
+		WithNotFoundHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			missing++
+			page.ServeHTTP(w, r)
+		})))
+	for _, tc := range []struct {
+		path, ae, body, encoding string
+		status                   int
+	}{
+		{"/nonexistent.txt", "", "<h1>Lost?</h1>", "", 404},
+		{"/nonexistent.txt", "gzip", "not really gzip", "gzip", 404},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		req.Header.Set("Range", "bytes=0-1")
+		req.Header.Set("If-Modified-Since", "Thu, 01 Jan 2099 00:00:00 GMT")
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Body.String() != tc.body || rr.Header().Get("Content-Encoding") != tc.encoding {
+			t.Errorf("GET %s (%q): %d %q %q, expected %d %q %q", tc.path, tc.ae, rr.Code, rr.Header().Get("Content-Encoding"), rr.Body.String(), tc.status, tc.encoding, tc.body)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("404 page served as %q", ct)
+		}
+	}
+	if missing != 2 {
+		t.Errorf("not found handler called %d times, expected 2", missing)
+	}
+
+	// A missing page falls back to the plain 404.
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/nonexistent.txt", nil)
+	NotFoundPage(Dir(dir), "/nope.html").ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound || rr.Body.String() != "404 page not found\n" {
+		t.Errorf("missing 404 page gave %d %q", rr.Code, rr.Body.String())
+	}
+}
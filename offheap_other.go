@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !gzipped_minimal
+
+package gzipped
+
+// allocOffHeap isn't supported on this platform, so the Go heap is used.
+func allocOffHeap(n int) ([]byte, bool) {
+	return nil, false
+}
+
+func freeOffHeap(b []byte) {}
@@ -0,0 +1,22 @@
+//go:build (darwin || dragonfly || freebsd || linux || netbsd || openbsd) && !gzipped_minimal
+
+package gzipped
+
+import "syscall"
+
+// allocOffHeap allocates n bytes of anonymous mapped memory, reporting
+// whether it succeeded.
+func allocOffHeap(n int) ([]byte, bool) {
+	if n == 0 {
+		return nil, false
+	}
+	b, err := syscall.Mmap(-1, 0, n, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+func freeOffHeap(b []byte) {
+	_ = syscall.Munmap(b)
+}
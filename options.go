@@ -0,0 +1,57 @@
+package gzipped
+
+import "net/http"
+
+// Option configures optional behavior of the handler returned by FileServer.
+type Option func(*fileHandler)
+
+// ErrorHook is called when the handler encounters an internal error while
+// serving a request, such as a compressed variant which exists but cannot
+// be opened. The response has already been decided by the time the hook is
+// called; the hook is for reporting only.
+type ErrorHook func(r *http.Request, err error)
+
+// ReportError calls h, so that an ErrorHook is also an ErrorReporter.
+func (h ErrorHook) ReportError(r *http.Request, err error) {
+	h(r, err)
+}
+
+// WithErrorHook sets a function to be called whenever an internal serve
+// error occurs. It's shorthand for WithErrorReporter(hook).
+func WithErrorHook(hook ErrorHook) Option {
+	if hook == nil {
+		return WithErrorReporter(nil)
+	}
+	return WithErrorReporter(hook)
+}
+
+// WithStrictVariants makes the handler refuse to fall back to the identity
+// file when a compressed variant was negotiated but could not be opened.
+// Instead it responds 500 Internal Server Error and calls the error hook, so
+// that a broken deploy fails loudly rather than silently wasting bandwidth.
+func WithStrictVariants() Option {
+	return func(f *fileHandler) {
+		f.strict = true
+	}
+}
+
+// WithFallback passes requests which don't match a file to next, instead of
+// responding 404 Not Found, along with requests using methods other than GET
+// and HEAD. That lets the file server sit in front of a dynamic application,
+// such as a reverse proxy to an app server. It takes priority over
+// WithNotFoundHandler. FileServerWithFallback is shorthand for it.
+func WithFallback(next http.Handler) Option {
+	return func(f *fileHandler) {
+		f.fallback = next
+	}
+}
+
+// WithIndexFile makes requests for a directory, i.e. paths ending in "/",
+// serve the file with the given name in that directory, as http.FileServer
+// does with "index.html". Its compressed variants are negotiated like any
+// other file's. Without it, such requests get 404 Not Found.
+func WithIndexFile(name string) Option {
+	return func(f *fileHandler) {
+		f.indexFile = name
+	}
+}
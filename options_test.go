@@ -0,0 +1,182 @@
+package gzipped
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// brokenFS claims compressed variants exist, but fails to open them.
+type brokenFS struct {
+	FileSystem
+}
+
+func (b brokenFS) Open(name string) (http.File, error) {
+	if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".br") {
+		return nil, os.ErrPermission
+	}
+	return b.FileSystem.Open(name)
+}
+
+func TestStrictVariants(t *testing.T) {
+	root := brokenFS{Dir("./testdata/")}
+
+	// Without strict mode we quietly fall back to the identity file.
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/file.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	FileServer(root).ServeHTTP(rr, req)
+	if rr.Code != 200 || rr.Header().Get("Content-Encoding") != "" {
+		t.Errorf("non-strict fallback got %d, Content-Encoding %q", rr.Code, rr.Header().Get("Content-Encoding"))
+	}
+
+	var hookErr error
+	fs := FileServer(root, WithStrictVariants(), WithErrorHook(func(r *http.Request, err error) {
+		hookErr = err
+	}))
+	rr = httptest.NewRecorder()
+	fs.ServeHTTP(rr, req)
+	if rr.Code != 500 {
+		t.Errorf("strict mode returned %d, expected 500", rr.Code)
+	}
+	var verr *VariantError
+	if !errors.As(hookErr, &verr) || verr.Encoding != "gzip" || verr.Name != "/file.txt.gz" {
+		t.Errorf("error hook got %v", hookErr)
+	}
+	if !errors.Is(hookErr, os.ErrPermission) {
+		t.Errorf("error hook lost underlying error: %v", hookErr)
+	}
+
+	// Files with no compressed variant are unaffected by strict mode.
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/file2.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	fs.ServeHTTP(rr, req)
+	if rr.Code != 200 {
+		t.Errorf("strict mode broke identity-only file, got %d", rr.Code)
+	}
+}
+
+// deniedFS refuses to open some files.
+type deniedFS struct {
+	FileSystem
+	denied string
+}
+
+func (d deniedFS) Open(name string) (http.File, error) {
+	if name == d.denied {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	return d.FileSystem.Open(name)
+}
+
+func TestPermissionDenied(t *testing.T) {
+	var hookErr error
+	h := FileServer(deniedFS{Dir("./testdata/"), "/file.txt"}, WithErrorHook(func(r *http.Request, err error) {
+		hookErr = err
+	}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/file.txt", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("denied file got status %d", rr.Code)
+	}
+	if !errors.Is(hookErr, os.ErrPermission) {
+		t.Errorf("hook got %v", hookErr)
+	}
+
+	// A denied variant still falls back to the original.
+	h = FileServer(deniedFS{Dir("./testdata/"), "/file.txt.gz"})
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/file.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Encoding") != "" {
+		t.Errorf("denied variant got status %d, Content-Encoding %q", rr.Code, rr.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestOptionOrder(t *testing.T) {
+	var strict Option
+	f := FileServer(Dir("./testdata/"), strict, WithRangeLimits(2), WithRangeLimits(5)).(*fileHandler)
+	if f.strict {
+		t.Error("nil option set strict mode")
+	}
+	if f.maxRanges != 5 {
+		t.Errorf("later option didn't override earlier one: maxRanges %d", f.maxRanges)
+	}
+}
+
+func TestIndexFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(dir+"/docs", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"index.html":         "<p>home</p>",
+		"docs/index.html":    "<p>docs</p>",
+		"docs/index.html.gz": "not really gzip",
+	} {
+		if err := os.WriteFile(dir+"/"+name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(h http.Handler, p string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", p, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := get(FileServer(Dir(dir)), "/docs/"); rr.Code != http.StatusNotFound {
+		t.Errorf("directory without WithIndexFile got %d", rr.Code)
+	}
+	h := FileServer(Dir(dir), WithIndexFile("index.html"))
+	for _, tc := range []struct {
+		path, body, encoding string
+		status               int
+	}{
+		{"/", "<p>home</p>", "", 200},
+		{"/docs/", "not really gzip", "gzip", 200},
+		{"/docs/../", "<p>home</p>", "", 200},
+		{"/nowhere/", "404 page not found\n", "", 404},
+	} {
+		rr := get(h, tc.path)
+		if rr.Code != tc.status || rr.Body.String() != tc.body || rr.Header().Get("Content-Encoding") != tc.encoding {
+			t.Errorf("GET %s: %d %q %q, expected %d %q %q", tc.path, rr.Code, rr.Header().Get("Content-Encoding"), rr.Body.String(), tc.status, tc.encoding, tc.body)
+		}
+		if tc.status == 200 && rr.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("GET %s: Content-Type %q", tc.path, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestWithFallback(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-App", r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusTeapot)
+	})
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	h := FileServer(Dir("./testdata/"), WithNotFoundHandler(notFound), WithFallback(next))
+	for _, tc := range []struct {
+		method, path string
+		status       int
+		app          string
+	}{
+		{"GET", "/file.txt", 200, ""},
+		{"GET", "/api/users", http.StatusTeapot, "GET /api/users"},
+		{"POST", "/file.txt", http.StatusTeapot, "POST /file.txt"},
+		{"GET", "/", http.StatusTeapot, "GET /"},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Header().Get("X-App") != tc.app {
+			t.Errorf("%s %s: %d from %q, expected %d from %q", tc.method, tc.path, rr.Code, rr.Header().Get("X-App"), tc.status, tc.app)
+		}
+	}
+}
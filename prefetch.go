@@ -0,0 +1,118 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"os"
+	"sync"
+)
+
+// PrefetchConfig configures sibling variant prefetching, enabled by
+// WithPrefetch.
+type PrefetchConfig struct {
+	// Contents makes prefetching load the variants themselves into memory,
+	// not just their metadata.
+	Contents bool
+	// MaxFileSize is the size in bytes of the largest variant whose
+	// contents will be prefetched. The default is 1 MiB.
+	MaxFileSize int64
+	// MemoryBudget is the maximum total size in bytes of prefetched
+	// contents. The default is 64 MiB.
+	MemoryBudget int64
+}
+
+// The maximum number of prefetches which can run at once. Requests which
+// would start more are served without prefetching.
+const maxPrefetches = 8
+
+// WithPrefetch makes the first request for a file start a background lookup
+// of all of its variants, so that the next client, whatever it accepts, finds
+// which variants exist already known and optionally their content already in
+// memory. Variant metadata is cached for a few seconds, so newly added
+// variants may take that long to be noticed.
+func WithPrefetch(cfg PrefetchConfig) Option {
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = 1 << 20
+	}
+	if cfg.MemoryBudget <= 0 {
+		cfg.MemoryBudget = 64 << 20
+	}
+	return func(f *fileHandler) {
+		if f.stats == nil {
+			f.stats = newStatCache(defaultStatTTL, defaultStatMaxEntries)
+		}
+		if cfg.Contents {
+			if f.mem == nil {
+				f.mem = &memCache{}
+			}
+			f.mem.budget = cfg.MemoryBudget
+		}
+		f.prefetch = &prefetcher{
+			cfg:      cfg,
+			inflight: make(map[string]bool),
+			sem:      make(chan struct{}, maxPrefetches),
+		}
+	}
+}
+
+// prefetcher runs background lookups of sibling variants.
+type prefetcher struct {
+	cfg      PrefetchConfig
+	mu       sync.Mutex
+	inflight map[string]bool
+	sem      chan struct{}
+	wg       sync.WaitGroup // for tests to wait for prefetches to finish
+}
+
+// start begins prefetching the variants of fpath, unless they're already
+// known or being fetched.
+func (p *prefetcher) start(f *fileHandler, fpath string) {
+	if m, ok := f.stats.get(fpath); ok && m.infos != nil {
+		return
+	}
+	p.mu.Lock()
+	if p.inflight[fpath] {
+		p.mu.Unlock()
+		return
+	}
+	select {
+	case p.sem <- struct{}{}:
+	default:
+		p.mu.Unlock()
+		return
+	}
+	p.inflight[fpath] = true
+	p.mu.Unlock()
+	p.wg.Add(1)
+	go p.fetch(f, fpath)
+}
+
+func (p *prefetcher) fetch(f *fileHandler, fpath string) {
+	defer p.wg.Done()
+	defer func() {
+		p.mu.Lock()
+		delete(p.inflight, fpath)
+		p.mu.Unlock()
+		<-p.sem
+	}()
+	encs := preferredEncodings
+	names := make([]string, len(encs))
+	variantNames(fpath, encs, names)
+	m := &variantMeta{infos: make([]os.FileInfo, len(encs))}
+	for i := range encs {
+		info, err := Stat(f.root, names[i])
+		if err != nil || info.IsDir() {
+			continue
+		}
+		m.available |= 1 << uint(i)
+		m.infos[i] = info
+		if p.cfg.Contents && info.Size() <= p.cfg.MaxFileSize {
+			if _, ok := f.mem.get(names[i]); !ok {
+				if e, err := f.mem.load(f.root, names[i], p.cfg.MaxFileSize); err == nil {
+					f.mem.add(names[i], e)
+				}
+			}
+		}
+	}
+	f.stats.put(fpath, m)
+}
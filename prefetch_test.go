@@ -0,0 +1,49 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrefetch(t *testing.T) {
+	root := newCountingFS(Dir("./testdata/"))
+	fs := FileServer(root, WithPrefetch(PrefetchConfig{Contents: true})).(*fileHandler)
+	get := func(ae string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/file.txt", nil)
+		if ae != "" {
+			req.Header.Set("Accept-Encoding", ae)
+		}
+		fs.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// A client without Accept-Encoding only needed the identity file, but
+	// the other variants are looked up in the background.
+	get("")
+	fs.prefetch.wg.Wait()
+	m, ok := fs.stats.get("/file.txt")
+	if !ok || m.infos == nil {
+		t.Fatal("variant metadata wasn't prefetched")
+	}
+	gz, id := indexOfEncoding(preferredEncodings, "gzip"), indexOfEncoding(preferredEncodings, identityEncoding)
+	if m.available != 1<<uint(gz)|1<<uint(id) {
+		t.Errorf("prefetched availability %04b, expected gzip and identity", m.available)
+	}
+	if _, ok := fs.mem.get("/file.txt.gz"); !ok {
+		t.Error("gzip variant contents weren't prefetched")
+	}
+
+	// The next client finds everything warm.
+	exists, opens := root.count("exists:/file.txt.gz"), root.count("/file.txt.gz")
+	rr := get("gzip")
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("prefetched gzip variant wasn't served")
+	}
+	if root.count("exists:/file.txt.gz") != exists || root.count("/file.txt.gz") != opens {
+		t.Error("request for prefetched variant went to the FileSystem")
+	}
+}
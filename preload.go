@@ -0,0 +1,81 @@
+package gzipped
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// WithPreloads adds Link headers to the responses for files, to have
+// browsers start fetching what a page will need before they've parsed it.
+// links maps the paths of files, such as "/index.html", to Link header
+// values, which PreloadLink can make from URLs:
+//
+//	gzipped.WithPreloads(map[string][]string{
+//		"/index.html": {gzipped.PreloadLink("/app.css"), gzipped.PreloadLink("/app.js")},
+//	})
+//
+// See WithEarlyHints for sending them ahead of the response.
+func WithPreloads(links map[string][]string) Option {
+	m := make(map[string][]string, len(links))
+	for p, values := range links {
+		m[path.Clean("/"+p)] = append([]string(nil), values...)
+	}
+	return func(f *fileHandler) {
+		f.preloads = m
+	}
+}
+
+// WithEarlyHints sends the Link headers of WithPreloads in a 103 Early Hints
+// response as soon as a request arrives, before the file is looked for, as
+// well as with the response itself. Clients which don't understand 103
+// responses ignore them, but HTTP/1.0 clients aren't sent them.
+func WithEarlyHints() Option {
+	return func(f *fileHandler) {
+		f.earlyHints = true
+	}
+}
+
+// PreloadLink returns a Link header value to preload the resource at url,
+// with the destination the browser needs to use it inferred from its
+// extension: style sheets, scripts, fonts (fetched in CORS mode, as browsers
+// require), images, or fetch for anything else.
+func PreloadLink(url string) string {
+	ext := strings.ToLower(path.Ext(strings.SplitN(url, "?", 2)[0]))
+	var as string
+	switch ext {
+	case ".css":
+		as = "style"
+	case ".js", ".mjs":
+		as = "script"
+	case ".woff2", ".woff", ".ttf", ".otf":
+		return "<" + url + ">; rel=preload; as=font; crossorigin"
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".avif", ".svg", ".ico":
+		as = "image"
+	default:
+		return "<" + url + ">; rel=preload; as=fetch; crossorigin"
+	}
+	return "<" + url + ">; rel=preload; as=" + as
+}
+
+// sendEarlyHints sends a 103 Early Hints response with the preloads for
+// fpath, if it has any.
+func (f *fileHandler) sendEarlyHints(w http.ResponseWriter, r *http.Request, fpath string) {
+	links, ok := f.preloads[fpath]
+	if !ok || r.Method != http.MethodGet || !r.ProtoAtLeast(1, 1) {
+		return
+	}
+	h := w.Header()
+	h["Link"] = links
+	w.WriteHeader(http.StatusEarlyHints)
+	// The final response only gets them if a file is found.
+	delete(h, "Link")
+}
+
+// setPreloads adds the preloads for fpath to the response headers.
+func (f *fileHandler) setPreloads(w http.ResponseWriter, fpath string) {
+	if links, ok := f.preloads[fpath]; ok {
+		h := w.Header()
+		h["Link"] = append(h["Link"], links...)
+	}
+}
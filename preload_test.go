@@ -0,0 +1,79 @@
+package gzipped
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPreloadLink(t *testing.T) {
+	for url, expect := range map[string]string{
+		"/app.css":         "</app.css>; rel=preload; as=style",
+		"/app.js?v=2":      "</app.js?v=2>; rel=preload; as=script",
+		"/font.woff2":      "</font.woff2>; rel=preload; as=font; crossorigin",
+		"/hero.webp":       "</hero.webp>; rel=preload; as=image",
+		"/data/items.json": "</data/items.json>; rel=preload; as=fetch; crossorigin",
+	} {
+		if got := PreloadLink(url); got != expect {
+			t.Errorf("PreloadLink(%q) = %q, expected %q", url, got, expect)
+		}
+	}
+}
+
+func TestPreloads(t *testing.T) {
+	links := []string{PreloadLink("/app.css"), PreloadLink("/app.js")}
+	h := FileServer(Dir("./testdata/"), WithPreloads(map[string][]string{"file.txt": links, "/missing.txt": links}))
+	for _, tc := range []struct {
+		path  string
+		links int
+	}{
+		{"/file.txt", 2},
+		{"/file2.txt", 0},
+		{"/missing.txt", 0},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		h.ServeHTTP(rr, req)
+		if got := rr.Header()["Link"]; len(got) != tc.links {
+			t.Errorf("GET %s: Link %q", tc.path, got)
+		}
+	}
+}
+
+func TestEarlyHints(t *testing.T) {
+	h := FileServer(Dir("./testdata/"), WithEarlyHints(),
+		WithPreloads(map[string][]string{"/file.txt": {PreloadLink("/app.css")}}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("GET /file.txt HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	var statuses []string
+	var links int
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "HTTP/1.1 ") {
+			statuses = append(statuses, line[9:12])
+		}
+		if strings.HasPrefix(line, "Link: ") {
+			links++
+		}
+	}
+	if len(statuses) != 2 || statuses[0] != "103" || statuses[1] != "200" || links != 2 {
+		t.Errorf("responses %q with %d Link headers, expected 103 and 200 with one each", statuses, links)
+	}
+}
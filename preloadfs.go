@@ -0,0 +1,187 @@
+package gzipped
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	fs2 "io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+)
+
+// Preload reads every file in root, compressed variants and all, into
+// memory, and returns a FileSystem serving them from there, so that requests
+// never touch the disk. root must implement DirReader. It's meant for small
+// asset sets, such as an embed.FS or a directory shipped alongside the
+// binary, which can be held in memory in their entirety; changes to root
+// after Preload returns aren't seen.
+//
+// The FileSystem returned implements DirReader and Stater, and its files can
+// seek, so they support range requests.
+func Preload(root FileSystem) (FileSystem, error) {
+	dr, ok := root.(DirReader)
+	if !ok {
+		return nil, errors.New("gzipped: FileSystem can't list directories")
+	}
+	p := &preloadedFS{files: map[string]*preloadedFile{}}
+	if err := p.load(root, dr, "/"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// preloadedFS is the FileSystem returned by Preload.
+type preloadedFS struct {
+	files map[string]*preloadedFile // files and directories, by clean path
+}
+
+// preloadedFile is the content of a file, or the entries of a directory.
+type preloadedFile struct {
+	info    os.FileInfo
+	data    []byte
+	entries []fs2.DirEntry // sorted by name, for directories
+}
+
+// load reads the directory dir and everything below it.
+func (p *preloadedFS) load(root FileSystem, dr DirReader, dir string) error {
+	info, err := statOpen(root, dir)
+	if err != nil {
+		return err
+	}
+	entries, err := dr.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	d := &preloadedFile{info: info}
+	for _, de := range entries {
+		name := path.Join(dir, de.Name())
+		if de.IsDir() {
+			if err := p.load(root, dr, name); err != nil {
+				return err
+			}
+		} else if err := p.loadFile(root, name); err != nil {
+			return err
+		}
+		d.entries = append(d.entries, fs2.FileInfoToDirEntry(p.files[name].info))
+	}
+	sort.Slice(d.entries, func(i, j int) bool { return d.entries[i].Name() < d.entries[j].Name() })
+	p.files[dir] = d
+	return nil
+}
+
+// loadFile reads the named file.
+func (p *preloadedFS) loadFile(root FileSystem, name string) error {
+	file, err := root.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		// A link to a directory.
+		file.Close()
+		return p.load(root, root.(DirReader), name)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return &os.PathError{Op: "read", Path: name, Err: err}
+	}
+	if int64(len(data)) != info.Size() {
+		return fmt.Errorf("gzipped: %s changed size while being preloaded", name)
+	}
+	p.files[name] = &preloadedFile{info: info, data: data}
+	return nil
+}
+
+// statOpen returns the FileInfo of the named file, by opening it.
+func statOpen(root FileSystem, name string) (os.FileInfo, error) {
+	file, err := root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return file.Stat()
+}
+
+func (p *preloadedFS) lookup(op, name string) (*preloadedFile, error) {
+	pf, ok := p.files[path.Clean("/"+name)]
+	if !ok {
+		return nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return pf, nil
+}
+
+// Exists reports whether there's a file or directory called name.
+func (p *preloadedFS) Exists(name string) bool {
+	_, ok := p.files[path.Clean("/"+name)]
+	return ok
+}
+
+// Stat returns the FileInfo of the named file or directory.
+func (p *preloadedFS) Stat(name string) (fs2.FileInfo, error) {
+	pf, err := p.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return pf.info, nil
+}
+
+// Open opens the named file or directory.
+func (p *preloadedFS) Open(name string) (http.File, error) {
+	pf, err := p.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &preloadedReader{Reader: bytes.NewReader(pf.data), file: pf}, nil
+}
+
+// ReadDir lists the named directory.
+func (p *preloadedFS) ReadDir(name string) ([]fs2.DirEntry, error) {
+	pf, err := p.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !pf.info.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return append([]fs2.DirEntry(nil), pf.entries...), nil
+}
+
+// preloadedReader is an open preloadedFile.
+type preloadedReader struct {
+	*bytes.Reader
+	file *preloadedFile
+	read int // directory entries returned by Readdir so far
+}
+
+func (r *preloadedReader) Close() error { return nil }
+
+func (r *preloadedReader) Stat() (os.FileInfo, error) { return r.file.info, nil }
+
+// Readdir returns the directory's entries as os.File.Readdir does.
+func (r *preloadedReader) Readdir(count int) ([]os.FileInfo, error) {
+	if !r.file.info.IsDir() {
+		return nil, errors.New("not a directory")
+	}
+	entries := r.file.entries[r.read:]
+	if count > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if count < len(entries) {
+			entries = entries[:count]
+		}
+	}
+	infos := make([]os.FileInfo, len(entries))
+	for i, de := range entries {
+		infos[i], _ = de.Info()
+	}
+	r.read += len(entries)
+	return infos, nil
+}
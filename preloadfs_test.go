@@ -0,0 +1,101 @@
+package gzipped
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreload(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"index.html":       "<p>hello</p>",
+		"app.js":           "console.log('hello')",
+		"app.js.gz":        "not really gzip",
+		"sub/style.css":    "p { color: red; background: white }",
+		"sub/style.css.br": "not really brotli",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root, err := Preload(Dir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nothing is read from the disk after Preload.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	h := FileServer(root)
+	for _, tc := range []struct {
+		path, ae, rng, expect, encoding string
+	}{
+		{"/app.js", "", "", "console.log('hello')", ""},
+		{"/app.js", "gzip", "", "not really gzip", "gzip"},
+		{"/sub/style.css", "br", "", "not really brotli", "br"},
+		{"/sub/style.css", "", "bytes=0-0", "p", ""},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		if tc.rng != "" {
+			req.Header.Set("Range", tc.rng)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Body.String() != tc.expect || rec.Header().Get("Content-Encoding") != tc.encoding {
+			t.Errorf("GET %s (%q): %d, Content-Encoding %q, %q", tc.path, tc.ae, rec.Code, rec.Header().Get("Content-Encoding"), rec.Body.String())
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing.js", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing file: %d", rec.Code)
+	}
+
+	entries, err := root.(DirReader).ReadDir("/sub")
+	if err != nil || len(entries) != 2 || entries[0].Name() != "style.css" || entries[1].Name() != "style.css.br" {
+		t.Errorf("ReadDir: %v, %v", entries, err)
+	}
+	if info, err := Stat(root, "/app.js.gz"); err != nil || info.Size() != int64(len("not really gzip")) {
+		t.Errorf("Stat: %v, %v", info, err)
+	}
+	if problems := Validate(root); len(problems) != 0 {
+		t.Errorf("Validate: %v", problems)
+	}
+
+	d, err := root.Open("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	var names []string
+	for {
+		infos, err := d.Readdir(2)
+		for _, info := range infos {
+			names = append(names, info.Name())
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(names) != 4 {
+		t.Errorf("Readdir: %v", names)
+	}
+}
+
+func TestPreloadNeedsDirReader(t *testing.T) {
+	if _, err := Preload(struct{ FileSystem }{Dir("testdata")}); err == nil {
+		t.Error("no error for a FileSystem which can't list directories")
+	}
+}
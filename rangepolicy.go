@@ -0,0 +1,129 @@
+package gzipped
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// RangePolicy decides whether range requests are supported for a file, given
+// its path and the FileInfo of the variant being served.
+type RangePolicy func(fpath string, info os.FileInfo) bool
+
+// WithRangePolicy restricts range requests to the files for which allow
+// returns true. Other files are always sent whole with 200 OK, and
+// advertise Accept-Ranges: none. Partial content is rarely useful for small
+// compressed assets, where it mostly adds complexity and can confuse
+// proxies, but matters for large media.
+func WithRangePolicy(allow RangePolicy) Option {
+	return func(f *fileHandler) {
+		f.rangePolicy = allow
+	}
+}
+
+// WithoutRanges turns off range requests altogether: every file is sent
+// whole with 200 OK and advertises Accept-Ranges: none, and Range and
+// If-Range headers are ignored. It's WithRangePolicy with a policy allowing
+// nothing.
+func WithoutRanges() Option {
+	return WithRangePolicy(func(string, os.FileInfo) bool { return false })
+}
+
+// RangesForPatterns returns a RangePolicy allowing ranges for files matching
+// any of the patterns, in the syntax of path.Match. Patterns containing a
+// slash are matched against the whole path, and also match everything below
+// a directory they match; others are matched against the file name.
+func RangesForPatterns(patterns ...string) RangePolicy {
+	return func(fpath string, _ os.FileInfo) bool {
+		for _, p := range patterns {
+			if MatchPattern(p, fpath) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RangesAbove returns a RangePolicy allowing ranges for files larger than
+// size bytes.
+func RangesAbove(size int64) RangePolicy {
+	return func(_ string, info os.FileInfo) bool {
+		return info.Size() > size
+	}
+}
+
+// withoutRanges returns a copy of r with no Range or If-Range headers, and
+// a ResponseWriter which will advertise that ranges aren't accepted. size is
+// the size of the file to be served.
+func withoutRanges(w http.ResponseWriter, r *http.Request, size int64) (http.ResponseWriter, *http.Request) {
+	w = &noRangesWriter{ResponseWriter: w}
+	return w, dropRange(w, r, size)
+}
+
+// dropRange returns a copy of r with no Range or If-Range headers, so that
+// the whole file is sent, or r itself if it isn't a range request. size is
+// the size of the file to be served.
+func dropRange(w http.ResponseWriter, r *http.Request, size int64) *http.Request {
+	if len(r.Header[rangeHeader]) == 0 {
+		return r
+	}
+	if h := w.Header(); len(h[contentEncodingHeader]) > 0 {
+		// openVariant leaves the length of range requests to ServeContent,
+		// which won't set it for an encoded response.
+		h[contentLengthHeader] = []string{strconv.FormatInt(size, 10)}
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.Header = r.Header.Clone()
+	delete(r2.Header, rangeHeader)
+	delete(r2.Header, "If-Range")
+	return r2
+}
+
+// noRangesWriter replaces the Accept-Ranges header http.ServeContent always
+// sets with Accept-Ranges: none.
+type noRangesWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (nw *noRangesWriter) WriteHeader(code int) {
+	if !nw.wroteHeader {
+		nw.wroteHeader = true
+		if h := nw.Header(); len(h["Accept-Ranges"]) > 0 {
+			h.Set("Accept-Ranges", "none")
+		}
+	}
+	nw.ResponseWriter.WriteHeader(code)
+}
+
+func (nw *noRangesWriter) Write(p []byte) (int, error) {
+	if !nw.wroteHeader {
+		nw.WriteHeader(http.StatusOK)
+	}
+	return nw.ResponseWriter.Write(p)
+}
+
+// ReadFrom lets the underlying writer send files efficiently, if it can.
+func (nw *noRangesWriter) ReadFrom(src io.Reader) (int64, error) {
+	if !nw.wroteHeader {
+		nw.WriteHeader(http.StatusOK)
+	}
+	if rf, ok := nw.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	return io.Copy(writerOnly{nw.ResponseWriter}, src)
+}
+
+// Flush passes flushes through to the underlying writer, if it supports them.
+func (nw *noRangesWriter) Flush() {
+	if fl, ok := nw.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (nw *noRangesWriter) Unwrap() http.ResponseWriter {
+	return nw.ResponseWriter
+}
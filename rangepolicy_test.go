@@ -0,0 +1,63 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRangePolicy(t *testing.T) {
+	dir := t.TempDir()
+	for name, size := range map[string]int{"movie.mp4": 5000, "app.js": 100, "app.js.gz": 50} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		policy        RangePolicy
+		path, ae      string
+		status        int
+		acceptRanges  string
+		contentLength string
+	}{
+		{RangesForPatterns("*.mp4"), "/movie.mp4", "", http.StatusPartialContent, "bytes", "10"},
+		{RangesForPatterns("*.mp4"), "/app.js", "", http.StatusOK, "none", "100"},
+		{RangesForPatterns("*.mp4"), "/app.js", "gzip", http.StatusOK, "none", "50"},
+		{RangesForPatterns("/media/*"), "/movie.mp4", "", http.StatusOK, "none", "5000"},
+		{RangesAbove(1000), "/movie.mp4", "", http.StatusPartialContent, "bytes", "10"},
+		{RangesAbove(1000), "/app.js", "", http.StatusOK, "none", "100"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Range", "bytes=0-9")
+		if tc.ae != "" {
+			req.Header.Set("Accept-Encoding", tc.ae)
+		}
+		rec := httptest.NewRecorder()
+		FileServer(Dir(dir), WithRangePolicy(tc.policy)).ServeHTTP(rec, req)
+		h := rec.Header()
+		if rec.Code != tc.status || h.Get("Accept-Ranges") != tc.acceptRanges || h.Get("Content-Length") != tc.contentLength {
+			t.Errorf("GET %s (%q): status %d, Accept-Ranges %q, Content-Length %q", tc.path, tc.ae,
+				rec.Code, h.Get("Accept-Ranges"), h.Get("Content-Length"))
+		}
+		if req.Header.Get("Range") == "" {
+			t.Errorf("GET %s: the caller's request was modified", tc.path)
+		}
+	}
+}
+
+func TestWithoutRanges(t *testing.T) {
+	h := FileServer(Dir("./testdata/"), WithoutRanges())
+	for _, ae := range []string{"", "gzip"} {
+		req := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+		req.Header.Set("Range", "bytes=0-3")
+		req.Header.Set("If-Range", `"x"`)
+		req.Header.Set("Accept-Encoding", ae)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Header().Get("Accept-Ranges") != "none" || rec.Body.Len() <= 4 {
+			t.Errorf("%q: status %d, Accept-Ranges %q, %d bytes", ae, rec.Code, rec.Header().Get("Accept-Ranges"), rec.Body.Len())
+		}
+	}
+}
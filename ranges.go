@@ -0,0 +1,183 @@
+package gzipped
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Ranges separated by fewer bytes than this are merged, since the gap costs
+// less to send than the headers of another part of a multipart response.
+const rangeCoalesceGap = 80
+
+// WithRangeLimits limits the number of ranges a request may ask for, since
+// requests for many small or overlapping ranges can make a server send far
+// more than the size of the file. Overlapping and nearly adjacent ranges are
+// first coalesced and sorted; if more than maxRanges remain, the response is
+// 416 Range Not Satisfiable.
+func WithRangeLimits(maxRanges int) Option {
+	return func(f *fileHandler) {
+		f.maxRanges = maxRanges
+	}
+}
+
+// WithIdentityRanges makes range requests for files with compressed
+// variants get ranges of the uncompressed file, when it exists, rather than
+// ranges of whichever compressed variant was negotiated. Ranges of compressed
+// bytes are correct HTTP, but many clients and proxies mishandle them, and a
+// download resumed against a different representation is corrupted.
+func WithIdentityRanges() Option {
+	return func(f *fileHandler) {
+		f.identityRanges = true
+	}
+}
+
+// identityRange reports whether r is a range request which should be served
+// from the identity file, which is one of the available encodings, rather
+// than a compressed variant. If so, it sets the Vary header, since the
+// response still depended on Accept-Encoding.
+func (f *fileHandler) identityRange(w http.ResponseWriter, r *http.Request, encs []encoding, available encodingSet) bool {
+	if len(r.Header[rangeHeader]) == 0 || !available.has(indexOfEncoding(encs, identityEncoding)) {
+		return false
+	}
+	addVary(w.Header(), acceptEncodingHeader)
+	return true
+}
+
+// negotiatedRangeRequest returns the request to serve a file of the given
+// size with, when it's one of several representations of the resource. A
+// range request whose If-Range holds a date rather than an entity tag is
+// served in full, since a date can't tell which representation the client
+// has part of: the variants may well share a modification time, and a
+// client which switches encodings between requests would otherwise splice
+// ranges of one onto another. An entity tag is left for ServeContent to
+// compare with the ETag of the representation chosen, if it has one.
+func negotiatedRangeRequest(w http.ResponseWriter, r *http.Request, size int64) *http.Request {
+	ir := r.Header.Get("If-Range")
+	if ir == "" || strings.HasPrefix(ir, `"`) || strings.HasPrefix(ir, "W/") {
+		return r
+	}
+	return dropRange(w, r, size)
+}
+
+type byteRange struct {
+	start, end int64 // inclusive
+}
+
+// limitRanges applies the range limits to a multi-range request for a file
+// of the given size. It returns the request to serve, which has a rewritten
+// Range header if any ranges were coalesced, or nil if it has responded 416.
+func (f *fileHandler) limitRanges(w http.ResponseWriter, r *http.Request, size int64) *http.Request {
+	hdr := r.Header[rangeHeader]
+	if len(hdr) != 1 || !strings.Contains(hdr[0], ",") {
+		return r
+	}
+	ranges, ok := parseRanges(hdr[0], size)
+	if !ok || len(ranges) == 0 {
+		// Leave ServeContent to reject it or ignore it.
+		return r
+	}
+	n := len(ranges)
+	ranges = coalesceRanges(ranges)
+	if len(ranges) > f.maxRanges {
+		w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+		serveErrorText(w, r, http.StatusText(http.StatusRequestedRangeNotSatisfiable), http.StatusRequestedRangeNotSatisfiable)
+		return nil
+	}
+	if len(ranges) == n {
+		return r
+	}
+	var b strings.Builder
+	b.WriteString("bytes=")
+	for i, br := range ranges {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatInt(br.start, 10))
+		b.WriteByte('-')
+		b.WriteString(strconv.FormatInt(br.end, 10))
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.Header = r.Header.Clone()
+	r2.Header[rangeHeader] = []string{b.String()}
+	return r2
+}
+
+// parseRanges parses a Range header value for a file of the given size,
+// dropping ranges which can't be satisfied. It reports false if the header is
+// malformed.
+func parseRanges(s string, size int64) ([]byteRange, bool) {
+	const prefix = "bytes="
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return nil, false
+	}
+	var ranges []byteRange
+	for _, spec := range strings.Split(s[len(prefix):], ",") {
+		spec = trimOWS(spec)
+		if spec == "" {
+			continue
+		}
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, false
+		}
+		first, last = trimOWS(first), trimOWS(last)
+		var br byteRange
+		if first == "" {
+			// Suffix range: the last n bytes.
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, false
+			}
+			if n == 0 {
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			br = byteRange{size - n, size - 1}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, false
+			}
+			end := size - 1
+			if last != "" {
+				if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+					return nil, false
+				}
+				if end >= size {
+					end = size - 1
+				}
+			}
+			if start >= size {
+				continue
+			}
+			br = byteRange{start, end}
+		}
+		ranges = append(ranges, br)
+	}
+	return ranges, true
+}
+
+// coalesceRanges sorts ranges and merges those which overlap or are close
+// together.
+func coalesceRanges(ranges []byteRange) []byteRange {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start < ranges[j].start
+	})
+	out := ranges[:1]
+	for _, br := range ranges[1:] {
+		last := &out[len(out)-1]
+		if br.start <= last.end+rangeCoalesceGap {
+			if br.end > last.end {
+				last.end = br.end
+			}
+			continue
+		}
+		out = append(out, br)
+	}
+	return out
+}
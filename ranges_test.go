@@ -0,0 +1,201 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseRanges(t *testing.T) {
+	for _, tc := range []struct {
+		hdr    string
+		expect []byteRange
+		ok     bool
+	}{
+		{"bytes=0-9", []byteRange{{0, 9}}, true},
+		{"bytes=0-9, 20-", []byteRange{{0, 9}, {20, 99}}, true},
+		{"bytes=-10", []byteRange{{90, 99}}, true},
+		{"bytes=-200", []byteRange{{0, 99}}, true},
+		{"bytes=50-500", []byteRange{{50, 99}}, true},
+		{"bytes=100-200,0-0", []byteRange{{0, 0}}, true},
+		{"Bytes=1-2", []byteRange{{1, 2}}, true},
+		{"bytes=5-1", nil, false},
+		{"bytes=x-1", nil, false},
+		{"bytes=1", nil, false},
+		{"items=1-2", nil, false},
+	} {
+		got, ok := parseRanges(tc.hdr, 100)
+		if ok != tc.ok || !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("parseRanges(%q) = %v, %v; expected %v, %v", tc.hdr, got, ok, tc.expect, tc.ok)
+		}
+	}
+}
+
+func TestCoalesceRanges(t *testing.T) {
+	got := coalesceRanges([]byteRange{{1000, 1100}, {0, 99}, {50, 149}, {200, 210}, {1050, 1060}})
+	expect := []byteRange{{0, 210}, {1000, 1100}}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("coalesced to %v, expected %v", got, expect)
+	}
+}
+
+func TestRangeLimits(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("0123456789", 1000)
+	if err := os.WriteFile(filepath.Join(dir, "big.txt"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := FileServer(Dir(dir), WithRangeLimits(2))
+	get := func(rng string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/big.txt", nil)
+		req.Header.Set("Range", rng)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Overlapping ranges become one.
+	rec := get("bytes=0-99,50-149,0-149")
+	if rec.Code != http.StatusPartialContent || rec.Header().Get("Content-Range") != "bytes 0-149/10000" {
+		t.Errorf("overlapping ranges: status %d, Content-Range %q", rec.Code, rec.Header().Get("Content-Range"))
+	}
+	if rec.Body.String() != content[:150] {
+		t.Errorf("overlapping ranges: wrong body")
+	}
+
+	// Two distinct ranges are allowed.
+	rec = get("bytes=5000-5009,0-9")
+	if rec.Code != http.StatusPartialContent || !strings.HasPrefix(rec.Header().Get("Content-Type"), "multipart/byteranges") {
+		t.Errorf("two ranges: status %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	// Three are not, however they're written.
+	rec = get("bytes=0-0,1000-1000,2000-2000,2000-2000")
+	if rec.Code != http.StatusRequestedRangeNotSatisfiable || rec.Header().Get("Content-Range") != "bytes */10000" {
+		t.Errorf("three ranges: status %d, Content-Range %q", rec.Code, rec.Header().Get("Content-Range"))
+	}
+
+	// Many tiny ranges close together coalesce and are allowed.
+	var specs []string
+	for i := 0; i < 100; i++ {
+		specs = append(specs, strconv.Itoa(i*10)+"-"+strconv.Itoa(i*10))
+	}
+	rec = get("bytes=" + strings.Join(specs, ","))
+	if rec.Code != http.StatusPartialContent || rec.Header().Get("Content-Range") != "bytes 0-990/10000" {
+		t.Errorf("close ranges: status %d, Content-Range %q", rec.Code, rec.Header().Get("Content-Range"))
+	}
+}
+
+func TestRangeLimitsVariant(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"big.txt", "big.txt.gz"} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, 1000), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := FileServer(Dir(dir), WithRangeLimits(1))
+	req := httptest.NewRequest(http.MethodGet, "/big.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=0-0,-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestedRangeNotSatisfiable || rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("status %d, Content-Encoding %q", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestIdentityRanges(t *testing.T) {
+	get := func(h http.Handler, ifRange string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/file.txt", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("Range", "bytes=0-3")
+		if ifRange != "" {
+			req.Header.Set("If-Range", ifRange)
+		}
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	rr := get(FileServer(Dir("./testdata/"), WithIdentityRanges()), "")
+	if rr.Code != http.StatusPartialContent || rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != "zyxw" {
+		t.Errorf("identity range got %d, Content-Encoding %q, %q", rr.Code, rr.Header().Get("Content-Encoding"), rr.Body.String())
+	}
+	if rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("identity range had Vary %q", rr.Header().Get("Vary"))
+	}
+
+	h := FileServer(Dir("./testdata/"))
+	rr = get(h, "")
+	if rr.Code != http.StatusPartialContent || rr.Header().Get("Content-Encoding") != "gzip" || rr.Body.Len() != 4 {
+		t.Errorf("compressed range got %d, Content-Encoding %q, %d bytes", rr.Code, rr.Header().Get("Content-Encoding"), rr.Body.Len())
+	}
+
+	// A date in If-Range can't say which representation the client has.
+	info, err := os.Stat("./testdata/file.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = get(h, info.ModTime().UTC().Format(http.TimeFormat))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Length") != strconv.FormatInt(info.Size(), 10) {
+		t.Errorf("If-Range date got %d, Content-Length %q", rr.Code, rr.Header().Get("Content-Length"))
+	}
+}
+
+func TestIfRangeRepresentation(t *testing.T) {
+	get := func(h http.Handler, ae, ifRange string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/file.txt", nil)
+		req.Header.Set("Accept-Encoding", ae)
+		req.Header.Set("Range", "bytes=0-3")
+		req.Header.Set("If-Range", ifRange)
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	h := FileServer(Dir("./testdata/"), WithETags())
+	full := get(h, "gzip", "x")
+	gzipTag := full.Header().Get("Etag")
+	plain := get(h, "identity", "x")
+	plainTag, plainDate := plain.Header().Get("Etag"), plain.Header().Get("Last-Modified")
+	if gzipTag == "" || plainTag == "" || gzipTag == plainTag {
+		t.Fatalf("ETags %q and %q", gzipTag, plainTag)
+	}
+
+	for _, tc := range []struct {
+		ae, ifRange string
+		expect      int
+	}{
+		{"gzip", gzipTag, http.StatusPartialContent},
+		{"identity", plainTag, http.StatusPartialContent},
+		// The client has part of one representation, but would be sent
+		// another.
+		{"gzip", plainTag, http.StatusOK},
+		{"identity", gzipTag, http.StatusOK},
+		{"identity", plainDate, http.StatusOK},
+	} {
+		rr := get(h, tc.ae, tc.ifRange)
+		if rr.Code != tc.expect {
+			t.Errorf("%s with If-Range %s: got %d, expected %d", tc.ae, tc.ifRange, rr.Code, tc.expect)
+		}
+		if rr.Code == http.StatusOK && rr.Body.Len() <= 4 {
+			t.Errorf("%s with If-Range %s: only %d bytes", tc.ae, tc.ifRange, rr.Body.Len())
+		}
+	}
+
+	// A file with no variants has one representation, so a date is fine.
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/file2.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(rr, req)
+	req.Header.Set("Range", "bytes=0-3")
+	req.Header.Set("If-Range", rr.Header().Get("Last-Modified"))
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusPartialContent {
+		t.Errorf("If-Range date for a single representation got %d", rr.Code)
+	}
+}
@@ -0,0 +1,63 @@
+package gzipped
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// errIsDirectory is the error for opening a directory as a file.
+var errIsDirectory = errors.New("is directory")
+
+// WithCanonicalRedirects redirects requests to the canonical URLs of
+// directories, as http.FileServer does. A request for the index file named
+// by WithIndexFile, such as /docs/index.html, is redirected to /docs/, and
+// one for a directory without a trailing slash, such as /docs, to /docs/.
+// The redirects are 301 Moved Permanently, relative so that they work behind
+// http.StripPrefix, and keep the query string. Without WithIndexFile,
+// there's no page for a directory to redirect to, so neither is made.
+func WithCanonicalRedirects() Option {
+	return func(f *fileHandler) {
+		f.redirects = true
+	}
+}
+
+// redirectIndex redirects a request for a directory's index file to the
+// directory, reporting whether it did.
+func (f *fileHandler) redirectIndex(w http.ResponseWriter, r *http.Request, upath string) bool {
+	if !strings.HasSuffix(upath, "/"+f.indexFile) {
+		return false
+	}
+	localRedirect(w, r, "./")
+	return true
+}
+
+// redirectDirectory redirects a request for a directory without a trailing
+// slash to the URL with one, if err says it's a directory, reporting whether
+// it did.
+func (f *fileHandler) redirectDirectory(w http.ResponseWriter, r *http.Request, err error) bool {
+	upath := r.URL.Path
+	if !errors.Is(err, errIsDirectory) || strings.HasSuffix(upath, "/") {
+		return false
+	}
+	localRedirect(w, r, path.Base(upath)+"/")
+	return true
+}
+
+// localRedirect redirects to newPath, relative to the request's URL, keeping
+// any query string, as net/http's file server does.
+func localRedirect(w http.ResponseWriter, r *http.Request, newPath string) {
+	redirect(w, r, newPath, http.StatusMovedPermanently)
+}
+
+// redirect is localRedirect with the given status. Unlike http.Redirect, it
+// leaves a relative newPath relative, rather than resolving it against a
+// request path which might have had a prefix stripped.
+func redirect(w http.ResponseWriter, r *http.Request, newPath string, status int) {
+	if q := r.URL.RawQuery; q != "" {
+		newPath += "?" + q
+	}
+	w.Header()["Location"] = []string{newPath}
+	w.WriteHeader(status)
+}
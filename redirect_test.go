@@ -0,0 +1,56 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestCanonicalRedirects(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(dir+"/docs/guide", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/docs/index.html", "/docs/page.html"} {
+		if err := os.WriteFile(dir+name, []byte("<p>docs</p>"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := FileServer(Dir(dir), WithIndexFile("index.html"), WithCanonicalRedirects())
+	for _, tc := range []struct {
+		url      string
+		status   int
+		location string
+	}{
+		{"/docs/index.html", http.StatusMovedPermanently, "./"},
+		{"/docs/index.html?v=2", http.StatusMovedPermanently, "./?v=2"},
+		{"/docs", http.StatusMovedPermanently, "docs/"},
+		{"/docs/guide?q=x", http.StatusMovedPermanently, "guide/?q=x"},
+		{"/docs/", http.StatusOK, ""},
+		{"/docs/page.html", http.StatusOK, ""},
+		{"/nothing", http.StatusNotFound, ""},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.url, nil)
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Header().Get("Location") != tc.location {
+			t.Errorf("GET %s: %d to %q, expected %d to %q", tc.url, rr.Code, rr.Header().Get("Location"), tc.status, tc.location)
+		}
+	}
+
+	// Without the option, or without an index file, there are no redirects.
+	for _, h := range []http.Handler{
+		FileServer(Dir(dir), WithIndexFile("index.html")),
+		FileServer(Dir(dir), WithCanonicalRedirects()),
+	} {
+		for _, url := range []string{"/docs", "/docs/index.html"} {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", url, nil)
+			h.ServeHTTP(rr, req)
+			if rr.Code == http.StatusMovedPermanently {
+				t.Errorf("GET %s redirected to %q", url, rr.Header().Get("Location"))
+			}
+		}
+	}
+}
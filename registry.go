@@ -0,0 +1,117 @@
+package gzipped
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Server preferences of the built-in encodings, as used by RegisterEncoding.
+const (
+	PreferenceBrotli = 300
+	PreferenceZstd   = 200
+	PreferenceGzip   = 100
+)
+
+var (
+	registryMu sync.Mutex
+	// Server preferences of the registered encodings, by name.
+	preferences = map[string]int{
+		"br":   PreferenceBrotli,
+		"zstd": PreferenceZstd,
+		"gzip": PreferenceGzip,
+	}
+)
+
+// RegisterEncoding makes FileServer look for variants of every file with the
+// extension ext, such as ".zst", to serve to clients which accept the
+// content-coding name, such as "zstd". When the client is equally happy with
+// several encodings, the one with the highest serverPreference is sent; the
+// preferences of the built-in encodings are the Preference constants.
+// Registering a name again replaces its extension and preference.
+//
+// The set of encodings is global, and is read when handlers, indexes and
+// options are created and on every request, so RegisterEncoding and
+// UnregisterEncoding should only be called during program initialization,
+// before any of those.
+func RegisterEncoding(name, ext string, serverPreference int) error {
+	name = strings.ToLower(name)
+	if name == "" || strings.ContainsAny(name, " \t,;=\"") || name == "*" {
+		return fmt.Errorf("gzipped: invalid content-coding %q", name)
+	}
+	if name == identityEncoding {
+		return errors.New("gzipped: the identity encoding can't be registered")
+	}
+	if len(ext) < 2 || ext[0] != '.' || strings.ContainsAny(ext, "/\\") {
+		return fmt.Errorf("gzipped: invalid variant extension %q", ext)
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	encs := make([]encoding, 0, len(preferredEncodings)+1)
+	for _, enc := range preferredEncodings {
+		if enc.name == name || enc.name == identityEncoding {
+			continue
+		}
+		if enc.ext == ext {
+			return fmt.Errorf("gzipped: extension %q is already used by %s", ext, enc.name)
+		}
+		encs = append(encs, enc)
+	}
+	if len(encs)+2 > maxEncodings {
+		return errors.New("gzipped: too many encodings registered")
+	}
+	encs = append(encs, newEncoding(name, ext))
+	preferences[name] = serverPreference
+	setEncodings(encs)
+	return nil
+}
+
+// UnregisterEncoding stops FileServer serving variants for the named
+// content-coding, built-in or not. The identity encoding can't be removed.
+// See RegisterEncoding for when it's safe to call.
+func UnregisterEncoding(name string) error {
+	name = strings.ToLower(name)
+	if name == identityEncoding {
+		return errors.New("gzipped: the identity encoding can't be unregistered")
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := preferences[name]; !ok {
+		return fmt.Errorf("gzipped: encoding %q isn't registered", name)
+	}
+	delete(preferences, name)
+	encs := make([]encoding, 0, len(preferredEncodings))
+	for _, enc := range preferredEncodings {
+		if enc.name != name && enc.name != identityEncoding {
+			encs = append(encs, enc)
+		}
+	}
+	setEncodings(encs)
+	return nil
+}
+
+// Encodings returns the names of the registered content-codings, most
+// preferred first, not including identity.
+func Encodings() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	names := make([]string, 0, len(preferredEncodings))
+	for _, enc := range preferredEncodings {
+		if enc.name != identityEncoding {
+			names = append(names, enc.name)
+		}
+	}
+	return names
+}
+
+// setEncodings sorts encs by preference, appends identity, and makes the
+// result the preferred encodings. The slice is replaced rather than
+// modified, so anything still holding the old one is unaffected.
+func setEncodings(encs []encoding) {
+	sort.SliceStable(encs, func(i, j int) bool {
+		return preferences[encs[i].name] > preferences[encs[j].name]
+	})
+	preferredEncodings = append(encs, newEncoding(identityEncoding, ""))
+}
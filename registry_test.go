@@ -0,0 +1,94 @@
+package gzipped
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// restoreEncodings puts the registry back as it was when the test ends.
+func restoreEncodings(t *testing.T) {
+	encs := preferredEncodings
+	prefs := make(map[string]int, len(preferences))
+	for k, v := range preferences {
+		prefs[k] = v
+	}
+	t.Cleanup(func() {
+		preferredEncodings, preferences = encs, prefs
+	})
+}
+
+func TestRegisterEncoding(t *testing.T) {
+	restoreEncodings(t)
+	for _, tc := range []struct {
+		name, ext string
+	}{
+		{"", ".x"},
+		{"a,b", ".x"},
+		{"*", ".x"},
+		{"identity", ".id"},
+		{"deflate", ""},
+		{"deflate", "zz"},
+		{"deflate", "./zz"},
+		{"deflate", ".gz"},
+	} {
+		if err := RegisterEncoding(tc.name, tc.ext, 0); err == nil {
+			t.Errorf("RegisterEncoding(%q, %q) succeeded", tc.name, tc.ext)
+		}
+	}
+	if err := UnregisterEncoding("identity"); err == nil {
+		t.Error("unregistered identity")
+	}
+	if err := UnregisterEncoding("compress"); err == nil {
+		t.Error("unregistered an encoding which wasn't registered")
+	}
+
+	if err := RegisterEncoding("Deflate", ".zz", PreferenceGzip+1); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnregisterEncoding("br"); err != nil {
+		t.Fatal(err)
+	}
+	if got, expect := Encodings(), []string{"zstd", "deflate", "gzip"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("encodings %v, expected %v", got, expect)
+	}
+	// Registering again moves an encoding.
+	if err := RegisterEncoding("gzip", ".gz", PreferenceZstd+1); err != nil {
+		t.Fatal(err)
+	}
+	if got, expect := Encodings(), []string{"gzip", "zstd", "deflate"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("encodings %v, expected %v", got, expect)
+	}
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"page.txt":    "hello",
+		"page.txt.zz": "not really deflate",
+		"page.txt.gz": "not really gzip",
+		"page.txt.br": "not really brotli",
+	} {
+		if err := ioutil.WriteFile(dir+"/"+name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs := FileServer(Dir(dir))
+	for _, tc := range []struct {
+		ae     string
+		expect string
+	}{
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"br", ""},
+		{"br, deflate", "deflate"},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/page.txt", nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		fs.ServeHTTP(rr, req)
+		if got := rr.Header().Get("Content-Encoding"); got != tc.expect {
+			t.Errorf("Accept-Encoding %q: Content-Encoding %q, expected %q", tc.ae, got, tc.expect)
+		}
+	}
+}
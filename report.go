@@ -0,0 +1,76 @@
+package gzipped
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// ErrorReporter receives the handler's internal errors, for forwarding to an
+// error tracking service such as Sentry. The request being served is passed
+// along so that its method, URL, headers and context can be attached to the
+// report; it is nil for errors which happen in the background, outside any
+// request.
+//
+// Errors are reported as one of the following types, possibly wrapped:
+//
+//   - *VariantError, for a compressed variant which couldn't be served;
+//   - *PanicError, for a panic recovered from a callback: a ServeHook, a
+//     WithHeaders or WithSlowRequestLog function, the Logger or
+//     AccessLogger, or a MissingAssetConfig.Report or
+//     LoadSheddingConfig.OnShed function.
+//
+// ReportError may be called concurrently. If it panics, the panic is
+// recovered and discarded.
+type ErrorReporter interface {
+	ReportError(r *http.Request, err error)
+}
+
+// WithErrorReporter sets the ErrorReporter which is told about internal
+// errors. It replaces any hook set by WithErrorHook.
+func WithErrorReporter(rep ErrorReporter) Option {
+	return func(f *fileHandler) {
+		f.reporter = rep
+	}
+}
+
+// PanicError records a panic recovered from a callback.
+type PanicError struct {
+	Callback string      // which callback panicked
+	Value    interface{} // the value passed to panic
+	Stack    []byte      // stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("gzipped: panic in %s: %v", e.Callback, e.Value)
+}
+
+// Unwrap returns the panic value if it was an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// reportError hands err to the error reporter, if there is one.
+func (f *fileHandler) reportError(r *http.Request, err error) {
+	if f.reporter == nil {
+		return
+	}
+	defer func() {
+		_ = recover()
+	}()
+	f.reporter.ReportError(r, err)
+}
+
+// callback runs the callback fn on behalf of request r, which may be nil,
+// reporting it as a *PanicError if it panics. It reports whether fn returned
+// normally.
+func (f *fileHandler) callback(r *http.Request, name string, fn func()) (ok bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			f.reportError(r, &PanicError{Callback: name, Value: v, Stack: debug.Stack()})
+		}
+	}()
+	fn()
+	return true
+}
@@ -0,0 +1,94 @@
+package gzipped
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+)
+
+type recordingReporter struct {
+	mu   sync.Mutex
+	reqs []*http.Request
+	errs []error
+}
+
+func (rr *recordingReporter) ReportError(r *http.Request, err error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.reqs = append(rr.reqs, r)
+	rr.errs = append(rr.errs, err)
+}
+
+func TestErrorReporter(t *testing.T) {
+	rep := &recordingReporter{}
+	h := FileServer(brokenFS{Dir("./testdata/")}, WithStrictVariants(), WithErrorReporter(rep))
+	req := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if len(rep.errs) != 1 || rep.reqs[0] != req {
+		t.Fatalf("reported %v for requests %v", rep.errs, rep.reqs)
+	}
+	var verr *VariantError
+	if !errors.As(rep.errs[0], &verr) || !errors.Is(verr, os.ErrPermission) {
+		t.Errorf("reported %v, expected a VariantError", rep.errs[0])
+	}
+}
+
+func TestReporterPanic(t *testing.T) {
+	h := FileServer(brokenFS{Dir("./testdata/")}, WithStrictVariants(), WithErrorHook(func(*http.Request, error) {
+		panic("reporter broken")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status %d", rec.Code)
+	}
+
+	// A nil hook means no reporting, rather than a nil function to call.
+	h = FileServer(brokenFS{Dir("./testdata/")}, WithStrictVariants(), WithErrorHook(nil))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if h.(*fileHandler).reporter != nil {
+		t.Errorf("nil hook installed as a reporter")
+	}
+}
+
+type panicLogger struct{}
+
+func (panicLogger) Debug(string, ...interface{}) { panic("logger broken") }
+func (panicLogger) Info(string, ...interface{})  { panic("logger broken") }
+
+func TestHookPanics(t *testing.T) {
+	for _, tc := range []struct {
+		callback string
+		opt      Option
+		status   int
+	}{
+		{"ServeHook", WithServeHook(func(*http.Request, Variant, http.Header) error { panic("hook broken") }), http.StatusInternalServerError},
+		{"WithHeaders", WithHeaders(func(string, http.Header) { panic("headers broken") }), http.StatusOK},
+		{"Logger.Debug", WithLogger(panicLogger{}), http.StatusOK},
+		{"AccessLogger.Info", WithAccessLogger(panicLogger{}), http.StatusOK},
+		{"WithSlowRequestLog", WithSlowRequestLog(0, func(SlowRequest) { panic("log broken") }), http.StatusOK},
+	} {
+		rep := &recordingReporter{}
+		h := FileServer(Dir("./testdata/"), WithErrorReporter(rep), tc.opt)
+		req := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status %d, expected %d", tc.callback, rec.Code, tc.status)
+		}
+		if len(rep.errs) == 0 || rep.reqs[0] != req {
+			t.Errorf("%s: reported %v for requests %v", tc.callback, rep.errs, rep.reqs)
+			continue
+		}
+		var perr *PanicError
+		if !errors.As(rep.errs[0], &perr) || perr.Callback != tc.callback {
+			t.Errorf("%s: reported %v, expected a PanicError", tc.callback, rep.errs[0])
+		}
+	}
+}
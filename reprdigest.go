@@ -0,0 +1,99 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The most files whose digests WithReprDigest remembers.
+const maxReprDigests = 10000
+
+// WithReprDigest adds a Repr-Digest header (RFC 9530) to responses, with the
+// SHA-256 hash of the representation sent: the compressed variant, in full,
+// even for range requests. Hashes come from the index or manifest if there
+// is one, and otherwise are computed when a file is first served and
+// remembered until it changes. Clients can decline the header by sending
+// Want-Repr-Digest without a nonzero preference for sha-256.
+//
+// Files decompressed on the fly, and files which can't seek and aren't in an
+// index, are sent without a digest.
+func WithReprDigest() Option {
+	return func(f *fileHandler) {
+		f.digests = &reprDigests{limit: perShard(maxReprDigests)}
+	}
+}
+
+type reprDigests struct {
+	sums  shardedMap[reprSum]
+	limit int
+}
+
+// reprSum is the hash of a file, with the size and modification time it was
+// computed for.
+type reprSum struct {
+	size  int64
+	mtime time.Time
+	sum   [sha256.Size]byte
+}
+
+// apply sets the Repr-Digest header for v, if r wants it.
+func (d *reprDigests) apply(f *fileHandler, w http.ResponseWriter, r *http.Request, fpath string, v variant) {
+	if v.decode || !wantsSHA256(r.Header.Get("Want-Repr-Digest")) {
+		return
+	}
+	sum, ok := d.sum(f, fpath, v)
+	if !ok {
+		return
+	}
+	w.Header()["Repr-Digest"] = []string{"sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"}
+}
+
+func (d *reprDigests) sum(f *fileHandler, fpath string, v variant) ([sha256.Size]byte, bool) {
+	if f.index != nil {
+		if iv, ok := f.index.variant(fpath, v.encoding); ok && iv.SHA256 != ([sha256.Size]byte{}) {
+			return iv.SHA256, true
+		}
+	}
+	size, mtime := v.info.Size(), v.info.ModTime()
+	if cached, ok := d.sums.get(v.name); ok && cached.size == size && cached.mtime.Equal(mtime) {
+		return cached.sum, true
+	}
+	if !seekable(v.file) {
+		return [sha256.Size]byte{}, false
+	}
+	h := sha256.New()
+	_, err := io.Copy(h, v.file)
+	if _, serr := v.file.Seek(0, io.SeekStart); err != nil || serr != nil {
+		return [sha256.Size]byte{}, false
+	}
+	s := reprSum{size: size, mtime: mtime}
+	h.Sum(s.sum[:0])
+	d.sums.put(v.name, s, d.limit, func(reprSum) bool { return true })
+	return s.sum, true
+}
+
+// wantsSHA256 reports whether a Want-Repr-Digest header value wh allows a
+// sha-256 digest: it does if it's absent or can't be parsed, or if it gives
+// sha-256 a nonzero preference.
+func wantsSHA256(wh string) bool {
+	if wh == "" {
+		return true
+	}
+	for _, member := range strings.Split(wh, ",") {
+		key, value := cut(member, '=')
+		if !strings.EqualFold(trimOWS(key), "sha-256") {
+			continue
+		}
+		value, _ = cut(value, ';')
+		n, err := strconv.Atoi(trimOWS(value))
+		return err != nil || n > 0
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestWantsSHA256(t *testing.T) {
+	for wh, expect := range map[string]bool{
+		"":                      true,
+		"sha-256=1":             true,
+		"sha-512=3, sha-256=10": true,
+		"sha-256=0":             false,
+		"sha-512=3":             false,
+		"SHA-256=x":             true,
+	} {
+		if got := wantsSHA256(wh); got != expect {
+			t.Errorf("wantsSHA256(%q) = %v", wh, got)
+		}
+	}
+}
+
+func TestReprDigest(t *testing.T) {
+	digest := func(name string) string {
+		data, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(data)
+		return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+	}
+	idx, err := BuildIndex(Dir("./testdata/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, h := range map[string]http.Handler{
+		"computed": FileServer(Dir("./testdata/"), WithReprDigest()),
+		"indexed":  FileServer(Dir("./testdata/"), WithReprDigest(), WithIndex(idx)),
+	} {
+		for _, tc := range []struct {
+			path, ae, rng, want, expect string
+		}{
+			{"/file.txt", "", "", "", digest("file.txt")},
+			{"/file.txt", "gzip", "", "", digest("file.txt.gz")},
+			{"/file.txt", "gzip", "bytes=0-1", "", digest("file.txt.gz")},
+			{"/file.txt", "gzip", "", "sha-256=0, sha-512=1", ""},
+			{"/page", "", "", "", ""},
+		} {
+			for i := 0; i < 2; i++ {
+				rr := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", tc.path, nil)
+				req.Header.Set("Accept-Encoding", tc.ae)
+				if tc.rng != "" {
+					req.Header.Set("Range", tc.rng)
+				}
+				if tc.want != "" {
+					req.Header.Set("Want-Repr-Digest", tc.want)
+				}
+				h.ServeHTTP(rr, req)
+				if got := rr.Header().Get("Repr-Digest"); got != tc.expect {
+					t.Errorf("%s: GET %s (%q, %q, %q): Repr-Digest %q, expected %q", name, tc.path, tc.ae, tc.rng, tc.want, got, tc.expect)
+				}
+				if tc.rng == "" && tc.path == "/file.txt" && rr.Body.Len() == 0 {
+					t.Errorf("%s: GET %s (%q): empty body after hashing", name, tc.path, tc.ae)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,288 @@
+// Package s3fs provides a gzipped.FileSystem backed by an S3-compatible
+// object storage bucket, so that FileServer can negotiate compressed
+// variants stored alongside the originals, as app.js, app.js.gz and
+// app.js.br objects.
+//
+// Requests are signed with AWS Signature Version 4 using only the standard
+// library, and work with Amazon S3 and with compatible services such as
+// MinIO, Cloudflare R2 and Ceph.
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config describes the bucket to serve.
+type Config struct {
+	// Bucket is the name of the bucket.
+	Bucket string
+	// Region is the bucket's region, such as "us-east-1". Services which
+	// don't have regions generally accept "us-east-1" or "auto".
+	Region string
+	// Endpoint is the base URL of the service, such as
+	// "http://localhost:9000". The default is Amazon S3's endpoint for the
+	// region.
+	Endpoint string
+	// PathStyle addresses the bucket as a path under the endpoint, rather
+	// than as a subdomain of it. Most services other than Amazon S3 need
+	// it.
+	PathStyle bool
+	// Prefix is prepended to every object key, so that files can be served
+	// from a "directory" of the bucket, such as "static/".
+	Prefix string
+	// AccessKeyID and SecretAccessKey are the credentials to sign requests
+	// with, and SessionToken the token for temporary credentials. Requests
+	// are sent unsigned if AccessKeyID is empty, for public buckets.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Client sends the requests. The default is http.DefaultClient.
+	Client *http.Client
+}
+
+// FS is a gzipped.FileSystem serving the objects in a bucket. Exists sends a
+// HEAD request for the object, and Open a GET request, whose response is
+// streamed. Seeking sends a new ranged GET request when the file is next
+// read, so range requests can be served without fetching whole objects.
+//
+// Buckets have no directories, so directories can't be opened, and FS
+// doesn't implement gzipped.DirReader.
+type FS struct {
+	cfg  Config
+	base *url.URL
+	now  func() time.Time
+}
+
+// New returns an FS for the bucket described by cfg.
+func New(cfg Config) (*FS, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3fs: no bucket given")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3fs: invalid endpoint: %w", err)
+	}
+	if base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("s3fs: invalid endpoint %q", endpoint)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	if cfg.PathStyle {
+		base.Path += "/" + cfg.Bucket
+	} else {
+		base.Host = cfg.Bucket + "." + base.Host
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &FS{cfg: cfg, base: base, now: time.Now}, nil
+}
+
+// key returns the object key for the file name, or "" for the root.
+func (fsys *FS) key(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return ""
+	}
+	return fsys.cfg.Prefix + name
+}
+
+// request sends a request for the object key, with a Range header starting
+// at offset if it's positive.
+func (fsys *FS) request(ctx context.Context, method, key string, offset int64) (*http.Response, error) {
+	u := *fsys.base
+	u.RawPath = u.EscapedPath() + "/" + uriEncode(key, false)
+	u.Path += "/" + key
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	if fsys.cfg.AccessKeyID != "" {
+		sign(req, fsys.cfg, fsys.now())
+	}
+	return fsys.cfg.Client.Do(req)
+}
+
+// statusError turns an unsuccessful response into an error, closing its
+// body.
+func statusError(op, name string, resp *http.Response) error {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	var err error
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		err = os.ErrNotExist
+	case http.StatusForbidden, http.StatusUnauthorized:
+		err = os.ErrPermission
+	default:
+		err = errors.New(resp.Status)
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// Exists reports whether there's an object for name.
+func (fsys *FS) Exists(name string) bool {
+	key := fsys.key(name)
+	if key == "" {
+		return false
+	}
+	resp, err := fsys.request(context.Background(), http.MethodHead, key, 0)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// Open starts fetching the object for name.
+func (fsys *FS) Open(name string) (http.File, error) {
+	return fsys.OpenContext(context.Background(), name)
+}
+
+// OpenContext is Open, with the requests for the object made with ctx, so
+// that they're abandoned when it's done. FileServer uses it to stop fetching
+// objects for clients which have gone away.
+func (fsys *FS) OpenContext(ctx context.Context, name string) (http.File, error) {
+	key := fsys.key(name)
+	if key == "" {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	resp, err := fsys.request(ctx, http.MethodGet, key, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("open", name, resp)
+	}
+	o := &object{fs: fsys, ctx: ctx, key: key, body: resp.Body, size: resp.ContentLength}
+	o.info.name = path.Base(path.Clean("/" + name))
+	o.info.size = resp.ContentLength
+	o.info.mtime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return o, nil
+}
+
+// object is an open object. Reads come from the body of a GET response,
+// which is replaced by a ranged one when the file has been seeked elsewhere.
+type object struct {
+	fs      *FS
+	ctx     context.Context
+	key     string
+	body    io.ReadCloser
+	bodyPos int64 // offset in the object of the next byte of body
+	pos     int64
+	size    int64 // -1 if unknown
+	info    objectInfo
+}
+
+func (o *object) Read(p []byte) (int, error) {
+	if o.body == nil || o.bodyPos != o.pos {
+		if err := o.refetch(); err != nil {
+			return 0, err
+		}
+	}
+	if o.body == nil {
+		return 0, io.EOF
+	}
+	n, err := o.body.Read(p)
+	o.pos += int64(n)
+	o.bodyPos += int64(n)
+	return n, err
+}
+
+// refetch replaces the body with one starting at the current position.
+func (o *object) refetch() error {
+	if o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	if o.size >= 0 && o.pos >= o.size {
+		return nil
+	}
+	resp, err := o.fs.request(o.ctx, http.MethodGet, o.key, o.pos)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// The Range header was ignored, so skip to the position.
+		if _, err := io.CopyN(io.Discard, resp.Body, o.pos); err != nil {
+			resp.Body.Close()
+			return err
+		}
+	default:
+		return statusError("read", o.info.name, resp)
+	}
+	o.body, o.bodyPos = resp.Body, o.pos
+	return nil
+}
+
+func (o *object) Seek(offset int64, whence int) (int64, error) {
+	pos := offset
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		pos += o.pos
+	case io.SeekEnd:
+		if o.size < 0 {
+			return 0, errors.New("s3fs: seek from end of object of unknown size")
+		}
+		pos += o.size
+	default:
+		return 0, errors.New("s3fs: invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("s3fs: negative position")
+	}
+	o.pos = pos
+	return pos, nil
+}
+
+func (o *object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
+
+func (o *object) Readdir(int) ([]os.FileInfo, error) {
+	return nil, errors.New("s3fs: not a directory")
+}
+
+func (o *object) Stat() (os.FileInfo, error) {
+	return o.info, nil
+}
+
+type objectInfo struct {
+	name  string
+	size  int64
+	mtime time.Time
+}
+
+func (i objectInfo) Name() string       { return i.name }
+func (i objectInfo) Size() int64        { return i.size }
+func (i objectInfo) Mode() os.FileMode  { return 0o444 }
+func (i objectInfo) ModTime() time.Time { return i.mtime }
+func (i objectInfo) IsDir() bool        { return false }
+func (i objectInfo) Sys() interface{}   { return nil }
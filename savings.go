@@ -0,0 +1,52 @@
+package gzipped
+
+import (
+	"net/http"
+	"os"
+)
+
+// WithMinSavings makes the handler serve the original file rather than a
+// compressed variant which isn't at least bytes smaller than it, and at
+// least percent per cent smaller, since decompressing a variant that saves
+// next to nothing just costs the client CPU. Tiny files often have variants
+// larger than themselves. The next best encoding is tried instead, and
+// failing that the original. Either threshold may be zero. Like
+// WithSmallestVariant, it costs a stat of the original on each request for
+// a compressed variant, unless WithIndex is used or the stat cache knows
+// the sizes. Variants with no original are served as usual.
+func WithMinSavings(bytes int64, percent int) Option {
+	return func(f *fileHandler) {
+		f.minSavings = &minSavings{bytes: bytes, percent: int64(percent)}
+	}
+}
+
+type minSavings struct {
+	bytes, percent int64
+}
+
+// smallSavings reports whether the variant v of fpath saves too little over
+// the original, if available says there is one. infos has the FileInfo of
+// each variant, if the FileSystem said.
+func (f *fileHandler) smallSavings(r *http.Request, fpath string, v variant, encs []encoding, names []string, infos []os.FileInfo, available encodingSet) bool {
+	id := indexOfEncoding(encs, identityEncoding)
+	if !available.has(id) {
+		return false
+	}
+	var size int64
+	if infos[id] != nil {
+		size = infos[id].Size()
+	} else {
+		size = f.variantSize(fpath, encs, names, id)
+	}
+	if size < 0 {
+		return false
+	}
+	saved := size - v.info.Size()
+	if saved >= f.minSavings.bytes && saved*100 >= size*f.minSavings.percent {
+		return false
+	}
+	if f.logger != nil {
+		f.debug(r, "gzipped: skipping variant with small savings", "path", fpath, "file", v.name, "saved", saved)
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMinSavings(t *testing.T) {
+	dir := t.TempDir()
+	for name, size := range map[string]int{
+		"tiny.txt": 20, "tiny.txt.gz": 40, "tiny.txt.br": 18,
+		"big.txt": 1000, "big.txt.gz": 300, "big.txt.br": 960,
+		"only.txt.gz": 10,
+	} {
+		if err := os.WriteFile(dir+"/"+name, []byte(strings.Repeat("x", size)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		bytes            int64
+		percent          int
+		path, ae, expect string
+	}{
+		{0, 0, "/tiny.txt", "gzip", ""},
+		{1, 0, "/tiny.txt", "gzip", ""},
+		{1, 0, "/tiny.txt", "br, gzip", "br"},
+		{10, 0, "/tiny.txt", "br, gzip", ""},
+		{0, 10, "/big.txt", "br, gzip", "gzip"},
+		{0, 80, "/big.txt", "br, gzip", ""},
+		{100, 0, "/big.txt", "br", ""},
+		{100, 50, "/only.txt", "gzip", "gzip"},
+	} {
+		h := FileServer(Dir(dir), WithMinSavings(tc.bytes, tc.percent))
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		if rr.Code != 200 || rr.Header().Get("Content-Encoding") != tc.expect {
+			t.Errorf("%d bytes, %d%%, %s (%q): %d, Content-Encoding %q, expected %q",
+				tc.bytes, tc.percent, tc.path, tc.ae, rr.Code, rr.Header().Get("Content-Encoding"), tc.expect)
+		}
+		if tc.expect == "" && rr.Body.Len() != map[string]int{"/tiny.txt": 20, "/big.txt": 1000}[tc.path] {
+			t.Errorf("%s (%q): served %d bytes, expected the original", tc.path, tc.ae, rr.Body.Len())
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package gzipped
+
+import (
+	"net/http"
+	"path"
+)
+
+// ServeFile responds to r with the file name from root, negotiating its
+// compressed variants as FileServer does, whatever the request's URL path.
+// It's for serving a particular file from your own routing logic, such as
+// the same page for several routes. opts are applied as for FileServer, on
+// every call, so options with a setup cost such as WithIndex or
+// WithValidation are better used with a FileServer; those which act on the
+// handler as a whole, such as WithSlowRequestLog and WithLoadShedding, have no
+// effect.
+//
+// Unlike http.ServeFile, name is always relative to root, a name ending in
+// "/" is not served its directory's index file, and there are no redirects
+// to canonical directory URLs, even with WithCanonicalRedirects.
+func ServeFile(w http.ResponseWriter, r *http.Request, root FileSystem, name string, opts ...Option) {
+	f := FileServer(root, opts...).(*fileHandler)
+	f.redirects = false
+	if f.checkMethod(w, r) {
+		return
+	}
+	f.serve(w, r, path.Clean("/"+name), nil)
+}
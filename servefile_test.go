@@ -0,0 +1,31 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeFile(t *testing.T) {
+	for _, tc := range []struct {
+		name, ae, encoding string
+		status             int
+	}{
+		{"/file.txt", "gzip", "gzip", 200},
+		{"file.txt", "", "", 200},
+		{"/../file.txt", "gzip", "gzip", 200},
+		{"/nonexistent.txt", "gzip", "", 404},
+		{"/", "", "", 404},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/some/route", nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		ServeFile(rr, req, Dir("./testdata/"), tc.name)
+		if rr.Code != tc.status || rr.Header().Get("Content-Encoding") != tc.encoding {
+			t.Errorf("ServeFile %s (%q): %d %q, expected %d %q", tc.name, tc.ae, rr.Code, rr.Header().Get("Content-Encoding"), tc.status, tc.encoding)
+		}
+		if req.URL.Path != "/some/route" {
+			t.Errorf("ServeFile changed the request path to %s", req.URL.Path)
+		}
+	}
+}
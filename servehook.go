@@ -0,0 +1,71 @@
+package gzipped
+
+import (
+	"errors"
+	fs2 "io/fs"
+	"net/http"
+)
+
+// ServeHook is called with a request, the variant of a file chosen to answer
+// it, and the response headers, before anything is written. An error stops
+// the file being served.
+type ServeHook func(r *http.Request, v Variant, h http.Header) error
+
+// WithServeHook calls hook for each file about to be served, once the
+// variant has been chosen and the handler has set its headers, so that the
+// request can be counted, the headers changed, or the file refused:
+//
+//	gzipped.WithServeHook(func(r *http.Request, v gzipped.Variant, h http.Header) error {
+//		if strings.HasPrefix(v.Name, "/private/") && !loggedIn(r) {
+//			return fs.ErrPermission
+//		}
+//		h.Set("X-Served-Encoding", v.Encoding)
+//		return nil
+//	})
+//
+// If hook returns an error satisfying errors.Is(err, fs.ErrNotExist), the
+// response is 404 Not Found, as if the file didn't exist; any other error
+// gives 403 Forbidden. If hook panics, the panic is reported to the
+// ErrorReporter and the response is 500 Internal Server Error. The option
+// can be given more than once, and the hooks are called in order until one
+// returns an error.
+//
+// Hooks aren't called for 304 Not Modified responses which WithETags answers
+// without opening a file.
+func WithServeHook(hook ServeHook) Option {
+	return func(f *fileHandler) {
+		f.serveHooks = append(f.serveHooks, hook)
+	}
+}
+
+// runServeHooks calls the hooks for v, and if one refuses it responds with
+// an error and reports false.
+func (f *fileHandler) runServeHooks(w http.ResponseWriter, r *http.Request, v variant) bool {
+	h := w.Header()
+	pub := v.public()
+	for _, hook := range f.serveHooks {
+		var err error
+		ok := f.callback(r, "ServeHook", func() {
+			err = hook(r, pub, h)
+		})
+		if ok && err == nil {
+			continue
+		}
+		// Headers describing the file don't belong on the error, or on
+		// whatever the not found handling serves instead.
+		clearVariantHeaders(w)
+		for _, name := range []string{"Accept-Ranges", "Cache-Control", contentLanguageHeader, contentTypeHeader,
+			"Etag", "Last-Modified", "Link", "Repr-Digest"} {
+			delete(h, name)
+		}
+		if !ok {
+			serveErrorText(w, r, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		} else if errors.Is(err, fs2.ErrNotExist) {
+			f.notFound(w, r)
+		} else {
+			serveErrorText(w, r, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		}
+		return false
+	}
+	return true
+}
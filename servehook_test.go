@@ -0,0 +1,103 @@
+package gzipped
+
+import (
+	"errors"
+	"io"
+	fs2 "io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWithServeHook(t *testing.T) {
+	var served []Variant
+	h := FileServer(Dir("./testdata/"),
+		WithCacheControl("/*", "max-age=3600"),
+		WithServeHook(func(r *http.Request, v Variant, h http.Header) error {
+			served = append(served, v)
+			switch v.Name {
+			case "/file.txt":
+				return fs2.ErrPermission
+			case "/app.js.br":
+				return fs2.ErrNotExist
+			}
+			h.Set("X-Encoding", v.Encoding)
+			return nil
+		}),
+		WithServeHook(func(r *http.Request, v Variant, h http.Header) error {
+			if v.Encoding == "gzip" {
+				return errors.New("no gzip")
+			}
+			return nil
+		}))
+	for _, tc := range []struct {
+		path, ae, enc string
+		status        int
+	}{
+		{"/app.js", "gzip, br", "", 404},
+		{"/app.js", "", "identity", 200},
+		{"/app.js", "gzip", "gzip", 403},
+		{"/file.txt", "", "", 403},
+		{"/nonexistent.txt", "", "", 404},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Header().Get("X-Encoding") != tc.enc {
+			t.Errorf("GET %s with %q: %d with headers %v", tc.path, tc.ae, rr.Code, rr.Header())
+		}
+		if rr.Code != 200 && (rr.Header().Get("Cache-Control") != "" || rr.Header().Get("Content-Encoding") != "") {
+			t.Errorf("GET %s with %q: refused with file headers %v", tc.path, tc.ae, rr.Header())
+		}
+	}
+	if len(served) != 4 || served[0].Encoding != "br" || !served[1].Decoded || served[1].Size == 0 {
+		t.Errorf("hook called with %+v", served)
+	}
+}
+
+func TestServeHookNotFoundHeaders(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/404.html", []byte("<h1>Lost?</h1>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	veto := WithServeHook(func(r *http.Request, v Variant, h http.Header) error {
+		if v.Encoding == "gzip" {
+			return fs2.ErrNotExist
+		}
+		return nil
+	})
+	for _, tc := range []struct {
+		name   string
+		opt    Option
+		status int
+		body   string
+	}{
+		{"fallback", WithFallback(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "from the app")
+		})), http.StatusOK, "from the app"},
+		{"not found page", WithNotFoundHandler(NotFoundPage(Dir(dir), "/404.html")), http.StatusNotFound, "<h1>Lost?</h1>"},
+	} {
+		h := FileServer(Dir("./testdata/"), WithETags(), veto, tc.opt)
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Body.String() != tc.body {
+			t.Errorf("%s: %d %q", tc.name, rr.Code, rr.Body.String())
+		}
+		hdr := rr.Header()
+		if hdr.Get("Content-Encoding") != "" || strings.Contains(hdr.Get("Etag"), "gz") {
+			t.Errorf("%s: variant headers left on response: %v", tc.name, hdr)
+		}
+		if cl := hdr.Get("Content-Length"); cl != "" && cl != strconv.Itoa(len(tc.body)) {
+			t.Errorf("%s: Content-Length %s for a %d byte body", tc.name, cl, len(tc.body))
+		}
+		if tc.status == http.StatusOK && (hdr.Get("Last-Modified") != "" || hdr.Get("Accept-Ranges") != "" || hdr.Get("Etag") != "") {
+			t.Errorf("%s: file headers left on response: %v", tc.name, hdr)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import "sync"
+
+// The number of shards in a shardedMap. Lookups for different keys usually
+// take different locks, so a single lock doesn't become a bottleneck when
+// many requests are being served concurrently.
+const cacheShards = 64
+
+// shardedMap is a map from strings split into independently locked shards.
+type shardedMap[V any] struct {
+	shards [cacheShards]mapShard[V]
+}
+
+type mapShard[V any] struct {
+	mu sync.RWMutex
+	m  map[string]V
+	// Keep each shard's lock on its own cache line, so that CPUs using
+	// neighboring shards don't contend for it.
+	_ [32]byte
+}
+
+// shard returns the shard for key, chosen by FNV-1a hash.
+func (s *shardedMap[V]) shard(key string) *mapShard[V] {
+	return &s.shards[fnv32(key)%cacheShards]
+}
+
+// fnv32 returns the 32-bit FNV-1a hash of key.
+func fnv32(key string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return h
+}
+
+func (s *shardedMap[V]) get(key string) (V, bool) {
+	sh := s.shard(key)
+	sh.mu.RLock()
+	v, ok := sh.m[key]
+	sh.mu.RUnlock()
+	return v, ok
+}
+
+// put stores v under key, as long as the key's shard has fewer than limit
+// entries. If it's full, entries for which expired returns true are removed
+// first to make room; if there still isn't any, v isn't stored. A limit of
+// zero or less means no limit.
+func (s *shardedMap[V]) put(key string, v V, limit int, expired func(V) bool) bool {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.m == nil {
+		sh.m = make(map[string]V)
+	}
+	if _, ok := sh.m[key]; !ok && limit > 0 && len(sh.m) >= limit {
+		for k, old := range sh.m {
+			if expired(old) {
+				delete(sh.m, k)
+			}
+		}
+		if len(sh.m) >= limit {
+			return false
+		}
+	}
+	sh.m[key] = v
+	return true
+}
+
+func (s *shardedMap[V]) delete(key string) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	delete(sh.m, key)
+	sh.mu.Unlock()
+}
+
+// clear removes every entry.
+func (s *shardedMap[V]) clear() {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		sh.m = nil
+		sh.mu.Unlock()
+	}
+}
+
+// perShard divides a limit on the total number of entries between the
+// shards, rounding up.
+func perShard(limit int) int {
+	return (limit + cacheShards - 1) / cacheShards
+}
@@ -0,0 +1,108 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedMap(t *testing.T) {
+	var m shardedMap[int]
+	never := func(int) bool { return false }
+	always := func(int) bool { return true }
+	if !m.put("a", 1, 1, never) {
+		t.Fatal("put into empty map failed")
+	}
+	if v, ok := m.get("a"); !ok || v != 1 {
+		t.Errorf("get returned %d, %v", v, ok)
+	}
+	// Replacing an existing key is always allowed.
+	if !m.put("a", 2, 1, never) {
+		t.Error("replacing a key in a full shard failed")
+	}
+	// Find another key in the same shard, which is now full.
+	var other string
+	for i := 0; ; i++ {
+		other = strconv.Itoa(i)
+		if m.shard(other) == m.shard("a") {
+			break
+		}
+	}
+	if m.put(other, 3, 1, never) {
+		t.Error("put into a full shard succeeded")
+	}
+	if !m.put(other, 3, 1, always) {
+		t.Error("put didn't evict expired entries")
+	}
+	if _, ok := m.get("a"); ok {
+		t.Error("expired entry wasn't evicted")
+	}
+	m.delete(other)
+	if _, ok := m.get(other); ok {
+		t.Error("deleted entry still present")
+	}
+}
+
+// Keys for the concurrent benchmarks, like the paths of a typical site.
+var benchKeys = func() []string {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "/assets/js/chunk-" + strconv.Itoa(i) + ".js"
+	}
+	return keys
+}()
+
+func BenchmarkStatCacheParallel(b *testing.B) {
+	c := newStatCache(time.Hour, len(benchKeys)*2)
+	for _, k := range benchKeys {
+		c.put(k, &variantMeta{available: 7})
+	}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.get(benchKeys[i%len(benchKeys)])
+			i++
+		}
+	})
+}
+
+// For comparison, the same lookups in a map behind a single lock.
+func BenchmarkSingleLockMapParallel(b *testing.B) {
+	var mu sync.RWMutex
+	m := make(map[string]*variantMeta)
+	for _, k := range benchKeys {
+		m[k] = &variantMeta{available: 7, expires: time.Now().Add(time.Hour)}
+	}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			mu.RLock()
+			v := m[benchKeys[i%len(benchKeys)]]
+			mu.RUnlock()
+			_ = v.expired()
+			i++
+		}
+	})
+}
+
+func BenchmarkServeHTTPDirIndexParallel(b *testing.B) {
+	fs := FileServer(Dir("./testdata/"), WithDirectoryIndex(time.Hour))
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		req, _ := http.NewRequest("GET", "/file.txt", nil)
+		req.Header.Set("Accept-Encoding", "gzip, br")
+		w := &nopResponseWriter{h: http.Header{}}
+		for pb.Next() {
+			for k := range w.h {
+				delete(w.h, k)
+			}
+			fs.ServeHTTP(w, req)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package gzipped
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// LoadSheddingConfig configures WithLoadShedding.
+type LoadSheddingConfig struct {
+	// MaxConcurrent is the most requests served at once, each of which
+	// may hold a file open. If it's zero or negative, no requests are
+	// shed.
+	MaxConcurrent int
+	// MaxQueue is the most requests which may wait for a turn when
+	// MaxConcurrent are already being served. The default is none.
+	MaxQueue int
+	// QueueTimeout is the longest a request waits in the queue. The
+	// default is one second. A request whose client goes away stops
+	// waiting at once.
+	QueueTimeout time.Duration
+	// RetryAfter is sent in the Retry-After header of shed requests,
+	// rounded up to whole seconds. The default is one second.
+	RetryAfter time.Duration
+	// OnShed, if set, is called for each request which is shed, for
+	// example to count them.
+	OnShed func(r *http.Request)
+}
+
+// WithLoadShedding limits how many requests the handler serves at once,
+// responding 503 Service Unavailable with a Retry-After header to those it
+// can't serve soon enough, so that overload is handled predictably instead of
+// by queueing without limit and running out of file descriptors.
+func WithLoadShedding(cfg LoadSheddingConfig) Option {
+	if cfg.QueueTimeout <= 0 {
+		cfg.QueueTimeout = time.Second
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = time.Second
+	}
+	var s *shedder
+	if cfg.MaxConcurrent > 0 {
+		secs := int64((cfg.RetryAfter + time.Second - 1) / time.Second)
+		s = &shedder{
+			cfg:        cfg,
+			slots:      make(chan struct{}, cfg.MaxConcurrent),
+			retryAfter: []string{strconv.FormatInt(secs, 10)},
+		}
+	}
+	return func(f *fileHandler) {
+		if s != nil {
+			f.shed = s
+		}
+	}
+}
+
+type shedder struct {
+	cfg        LoadSheddingConfig
+	slots      chan struct{}
+	queued     int32
+	retryAfter []string
+}
+
+// acquire waits for a turn to serve a request with context ctx, reporting
+// false if the request should be shed instead.
+func (s *shedder) acquire(ctx context.Context) bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if int(atomic.AddInt32(&s.queued, 1)) > s.cfg.MaxQueue {
+		atomic.AddInt32(&s.queued, -1)
+		return false
+	}
+	defer atomic.AddInt32(&s.queued, -1)
+	t := time.NewTimer(s.cfg.QueueTimeout)
+	defer t.Stop()
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-t.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *shedder) release() {
+	<-s.slots
+}
+
+// rejectOverload responds to a shed request.
+func (f *fileHandler) rejectOverload(w http.ResponseWriter, r *http.Request) {
+	if f.shed.cfg.OnShed != nil {
+		f.callback(r, "LoadSheddingConfig.OnShed", func() {
+			f.shed.cfg.OnShed(r)
+		})
+	}
+	if m, ok := f.metrics.(ShedMetrics); ok {
+		m.Shed()
+	}
+	w.Header()["Retry-After"] = f.shed.retryAfter
+	serveErrorText(w, r, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
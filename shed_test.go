@@ -0,0 +1,123 @@
+package gzipped
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blockingFS blocks opening files until released.
+type blockingFS struct {
+	FileSystem
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b blockingFS) Open(name string) (http.File, error) {
+	b.entered <- struct{}{}
+	<-b.release
+	return b.FileSystem.Open(name)
+}
+
+func TestLoadShedding(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		queue     int
+		releaseIn time.Duration
+		status    int
+	}{
+		{"no queue", 0, 0, http.StatusServiceUnavailable},
+		{"queue timeout", 1, 0, http.StatusServiceUnavailable},
+		{"queued", 1, 10 * time.Millisecond, http.StatusOK},
+	} {
+		root := blockingFS{Dir("./testdata/"), make(chan struct{}), make(chan struct{})}
+		shed := 0
+		h := FileServer(root, WithLoadShedding(LoadSheddingConfig{
+			MaxConcurrent: 1,
+			MaxQueue:      tc.queue,
+			QueueTimeout:  50 * time.Millisecond,
+			RetryAfter:    1500 * time.Millisecond,
+			OnShed:        func(*http.Request) { shed++ },
+		}))
+		done := make(chan struct{})
+		go func() {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/file.txt", nil))
+			close(done)
+		}()
+		<-root.entered
+		if tc.releaseIn > 0 {
+			time.AfterFunc(tc.releaseIn, func() { close(root.release) })
+			// The queued request will need to open its file too.
+			go func() { <-root.entered }()
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/file.txt", nil))
+		if rec.Code != tc.status {
+			t.Errorf("%s: status %d, expected %d", tc.name, rec.Code, tc.status)
+		}
+		if tc.status == http.StatusServiceUnavailable {
+			if rec.Header().Get("Retry-After") != "2" || shed != 1 {
+				t.Errorf("%s: Retry-After %q, %d shed", tc.name, rec.Header().Get("Retry-After"), shed)
+			}
+		}
+		if tc.releaseIn == 0 {
+			close(root.release)
+		}
+		<-done
+	}
+}
+
+type shedCounter struct {
+	shed int
+}
+
+func (s *shedCounter) Served(string)    {}
+func (s *shedCounter) Fallback(string)  {}
+func (s *shedCounter) CacheLookup(bool) {}
+func (s *shedCounter) NotFound()        {}
+func (s *shedCounter) Shed()            { s.shed++ }
+
+func TestLoadSheddingClientGone(t *testing.T) {
+	root := blockingFS{Dir("./testdata/"), make(chan struct{}), make(chan struct{})}
+	m := &shedCounter{}
+	h := FileServer(root, WithMetrics(m), WithLoadShedding(LoadSheddingConfig{
+		MaxConcurrent: 1,
+		MaxQueue:      1,
+		QueueTimeout:  time.Minute,
+	}))
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/file.txt", nil))
+		close(done)
+	}()
+	<-root.entered
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/file.txt", nil).WithContext(ctx))
+	if rec.Code != http.StatusServiceUnavailable || time.Since(start) > 10*time.Second {
+		t.Errorf("status %d after %v", rec.Code, time.Since(start))
+	}
+	if m.shed != 1 {
+		t.Errorf("%d shed requests counted, expected 1", m.shed)
+	}
+	close(root.release)
+	<-done
+}
+
+func TestLoadSheddingOff(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		h := FileServer(Dir("./testdata/"), WithLoadShedding(LoadSheddingConfig{MaxConcurrent: n}))
+		if h.(*fileHandler).shed != nil {
+			t.Errorf("MaxConcurrent %d turned on load shedding", n)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/file.txt", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("MaxConcurrent %d: status %d", n, rec.Code)
+		}
+	}
+}
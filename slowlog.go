@@ -0,0 +1,125 @@
+package gzipped
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// SlowRequest describes a request which took longer than the threshold set
+// with WithSlowRequestLog.
+type SlowRequest struct {
+	Request  *http.Request
+	Path     string // path of the file requested
+	Encoding string // content encoding sent, "" if no file was found
+	Status   int
+	Bytes    int64 // body bytes written
+	Client   string
+	// Lookup is how long it took to find and open the file, and Duration
+	// the total time to serve the request. A large Lookup points to slow
+	// storage; a large Duration with a small Lookup to a slow client.
+	Lookup   time.Duration
+	Duration time.Duration
+}
+
+// WithSlowRequestLog calls log for each request which takes longer than
+// threshold to serve, to help find slow disks and slow clients without
+// trawling access logs. It's called after the response is complete, from the
+// goroutine which served it.
+func WithSlowRequestLog(threshold time.Duration, log func(SlowRequest)) Option {
+	return func(f *fileHandler) {
+		f.slow = &slowLog{threshold: threshold, log: log}
+	}
+}
+
+type slowLog struct {
+	threshold time.Duration
+	log       func(SlowRequest)
+}
+
+// startTimer begins timing r, for the slow request log or the access log,
+// returning a ResponseWriter to serve it with.
+func (f *fileHandler) startTimer(w http.ResponseWriter, r *http.Request) *requestTimer {
+	return &requestTimer{ResponseWriter: w, f: f, start: time.Now(), req: SlowRequest{Request: r, Client: r.RemoteAddr}}
+}
+
+// requestTimer times a request, and counts what's written in response.
+type requestTimer struct {
+	http.ResponseWriter
+	f     *fileHandler
+	start time.Time
+	req   SlowRequest
+}
+
+// found records the end of the lookup for the file at fpath.
+func (t *requestTimer) found(fpath string, v variant, err error) {
+	t.req.Lookup = time.Since(t.start)
+	t.req.Path = fpath
+	if err == nil {
+		t.req.Encoding = v.encoding
+		if v.decode {
+			t.req.Encoding = identityEncoding
+		}
+	}
+}
+
+// finish logs the request to the access log, and to the slow request log if
+// it was slow.
+func (t *requestTimer) finish() {
+	t.req.Duration = time.Since(t.start)
+	if t.req.Status == 0 {
+		t.req.Status = http.StatusOK
+	}
+	f, r := t.f, t.req.Request
+	if f.access != nil && f.access.logger != nil {
+		f.callback(r, "AccessLogger.Info", func() {
+			f.access.info(t.req)
+		})
+	}
+	if f.access != nil && f.access.w != nil {
+		f.access.write(t.start, t.req)
+	}
+	if f.slow != nil && t.req.Duration >= f.slow.threshold {
+		f.callback(r, "WithSlowRequestLog", func() {
+			f.slow.log(t.req)
+		})
+	}
+}
+
+func (t *requestTimer) WriteHeader(code int) {
+	if t.req.Status == 0 && code >= 200 {
+		t.req.Status = code
+	}
+	t.ResponseWriter.WriteHeader(code)
+}
+
+func (t *requestTimer) Write(p []byte) (int, error) {
+	n, err := t.ResponseWriter.Write(p)
+	t.req.Bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom lets the underlying writer send files efficiently, if it can.
+func (t *requestTimer) ReadFrom(src io.Reader) (int64, error) {
+	var n int64
+	var err error
+	if rf, ok := t.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		n, err = io.Copy(writerOnly{t.ResponseWriter}, src)
+	}
+	t.req.Bytes += n
+	return n, err
+}
+
+// Flush passes flushes through to the underlying writer, if it supports them.
+func (t *requestTimer) Flush() {
+	if fl, ok := t.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (t *requestTimer) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
@@ -0,0 +1,50 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slowFS takes a while to open files.
+type slowFS struct {
+	FileSystem
+	delay time.Duration
+}
+
+func (s slowFS) Open(name string) (http.File, error) {
+	time.Sleep(s.delay)
+	return s.FileSystem.Open(name)
+}
+
+func TestSlowRequestLog(t *testing.T) {
+	var logged []SlowRequest
+	logf := func(sr SlowRequest) { logged = append(logged, sr) }
+
+	req := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	FileServer(slowFS{Dir("./testdata/"), 20 * time.Millisecond}, WithSlowRequestLog(10*time.Millisecond, logf)).ServeHTTP(httptest.NewRecorder(), req)
+	if len(logged) != 1 {
+		t.Fatalf("logged %d requests", len(logged))
+	}
+	sr := logged[0]
+	if sr.Request != req || sr.Path != "/file.txt" || sr.Encoding != "gzip" || sr.Status != http.StatusOK ||
+		sr.Bytes != 47 || sr.Client != req.RemoteAddr {
+		t.Errorf("logged %+v", sr)
+	}
+	if sr.Lookup < 20*time.Millisecond || sr.Duration < sr.Lookup {
+		t.Errorf("lookup took %v of %v", sr.Lookup, sr.Duration)
+	}
+
+	logged = nil
+	FileServer(Dir("./testdata/"), WithSlowRequestLog(time.Hour, logf)).ServeHTTP(httptest.NewRecorder(), req)
+	if len(logged) != 0 {
+		t.Errorf("logged a fast request")
+	}
+
+	FileServer(Dir("./testdata/"), WithSlowRequestLog(0, logf)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if len(logged) != 1 || logged[0].Status != http.StatusNotFound || logged[0].Encoding != "" {
+		t.Errorf("logged %+v for a missing file", logged)
+	}
+}
@@ -0,0 +1,96 @@
+package gzipped
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// WithSmallestVariant breaks ties between the encodings a client accepts
+// equally by sending the smallest file, rather than by the server's order of
+// preference, so that the fewest bytes are sent: gzip sometimes beats brotli
+// for tiny files, and the original can beat both. Unless WithIndex is used,
+// or the stat cache has the sizes from prefetching, every candidate has to be
+// opened to find its size, which costs a little on each request.
+func WithSmallestVariant() Option {
+	return func(f *fileHandler) {
+		f.smallest = true
+	}
+}
+
+// encodingOrderFor returns the order in which to break ties between the
+// encodings of fpath, or nil for the order of encs.
+func (f *fileHandler) encodingOrderFor(fpath string, encs []encoding, names []string, available encodingSet) []int {
+	if f.smallest && bits.OnesCount32(uint32(available)) > 1 {
+		return f.sizeOrder(fpath, encs, names, available)
+	}
+	if f.typeOrders != nil || f.defaultOrder != nil {
+		return f.typeOrder(fpath)
+	}
+	return nil
+}
+
+// sizeOrder returns the indexes of encs ordered by the size of the available
+// variants, smallest first, with ties and variants of unknown size left in
+// the usual order and unavailable encodings last.
+func (f *fileHandler) sizeOrder(fpath string, encs []encoding, names []string, available encodingSet) []int {
+	var order []int
+	if f.typeOrders != nil || f.defaultOrder != nil {
+		order = append(order, f.typeOrder(fpath)...)
+	}
+	if order == nil {
+		order = make([]int, len(encs))
+		for i := range order {
+			order[i] = i
+		}
+	}
+	var sizes [maxEncodings]int64
+	for i := range encs {
+		sizes[i] = -1
+		if available.has(i) {
+			sizes[i] = f.variantSize(fpath, encs, names, i)
+		}
+	}
+	rank := func(i int) (int, int64) {
+		switch {
+		case !available.has(i):
+			return 2, 0
+		case sizes[i] < 0:
+			return 1, 0
+		}
+		return 0, sizes[i]
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ra, sa := rank(order[a])
+		rb, sb := rank(order[b])
+		if ra != rb {
+			return ra < rb
+		}
+		return sa < sb
+	})
+	return order
+}
+
+// variantSize returns the size of the variant of fpath with encoding
+// encs[i], or -1 if it can't be found.
+func (f *fileHandler) variantSize(fpath string, encs []encoding, names []string, i int) int64 {
+	if f.index != nil {
+		if size, _, ok := f.index.stat(fpath, encs[i].name); ok {
+			return size
+		}
+	}
+	if f.stats != nil {
+		if meta, ok := f.stats.get(fpath); ok {
+			if info := meta.info(i); info != nil {
+				return info.Size()
+			}
+		}
+	}
+	file, info, err := f.openAndStat(names[i])
+	if file != nil {
+		file.Close()
+	}
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
@@ -0,0 +1,40 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSmallestVariant(t *testing.T) {
+	dir := t.TempDir()
+	for name, size := range map[string]int{
+		"tiny.txt": 5, "tiny.txt.gz": 25, "tiny.txt.br": 9,
+		"big.txt": 100, "big.txt.gz": 40, "big.txt.br": 50,
+	} {
+		if err := os.WriteFile(dir+"/"+name, []byte(strings.Repeat("x", size)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := FileServer(Dir(dir), WithSmallestVariant())
+	for _, tc := range []struct {
+		path, ae, expect string
+	}{
+		{"/big.txt", "br, gzip", "gzip"},
+		{"/big.txt", "br;q=1, gzip;q=0.5", "br"},
+		{"/big.txt", "br", "br"},
+		{"/tiny.txt", "br, gzip", "br"},
+		{"/tiny.txt", "br, gzip, identity", ""},
+		{"/tiny.txt", "gzip", "gzip"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Content-Encoding"); got != tc.expect {
+			t.Errorf("GET %s (%q): Content-Encoding %q, expected %q", tc.path, tc.ae, got, tc.expect)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package gzipped
+
+import (
+	"errors"
+	"os"
+	"path"
+)
+
+// WithSPAFallback serves the file index, typically "/index.html", for
+// requests which don't match a file and whose paths have no extension, so
+// that a single-page application's client-side routes like /users/42 load
+// the application. Directory paths ending in "/" get it too, unless
+// WithIndexFile is used. The index file is negotiated as usual.
+//
+// Requests for missing files with extensions, like /app.js, still get 404
+// Not Found, as serving HTML in their place would only confuse the browser.
+//
+// The fallback takes priority over the next handler of
+// FileServerWithFallback.
+func WithSPAFallback(index string) Option {
+	return func(f *fileHandler) {
+		f.spaIndex = path.Clean("/" + index)
+	}
+}
+
+// spaRoute reports whether the failure to find fpath should be answered with
+// the single-page application's index file.
+func (f *fileHandler) spaRoute(fpath string, err error) bool {
+	if f.spaIndex == "" || fpath == f.spaIndex || path.Ext(fpath) != "" {
+		return false
+	}
+	var verr *VariantError
+	return !errors.Is(err, ErrNotAcceptable) && !errors.Is(err, os.ErrPermission) && !errors.As(err, &verr)
+}
@@ -0,0 +1,56 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestSPAFallback(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(dir+"/assets", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"index.html":    "<div id=app></div>",
+		"index.html.gz": "not really gzip",
+		"assets/app.js": "app()",
+	} {
+		if err := os.WriteFile(dir+"/"+name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := FileServerWithFallback(Dir(dir), next, WithSPAFallback("index.html"))
+	for _, tc := range []struct {
+		path, ae, body string
+		status         int
+	}{
+		{"/users/42", "", "<div id=app></div>", 200},
+		{"/", "", "<div id=app></div>", 200},
+		{"/users/", "", "<div id=app></div>", 200},
+		{"/users/42", "gzip", "not really gzip", 200},
+		{"/assets", "", "<div id=app></div>", 200},
+		{"/assets/app.js", "", "app()", 200},
+		{"/assets/missing.js", "", "", http.StatusTeapot},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Body.String() != tc.body {
+			t.Errorf("GET %s (%q): %d %q, expected %d %q", tc.path, tc.ae, rr.Code, rr.Body.String(), tc.status, tc.body)
+		}
+	}
+
+	// Without an index file, routes are ordinary 404s.
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/42", nil)
+	FileServer(Dir(t.TempDir()), WithSPAFallback("/index.html")).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("missing index file got %d", rr.Code)
+	}
+}
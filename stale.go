@@ -0,0 +1,41 @@
+package gzipped
+
+import "net/http"
+
+// WithSkipStaleVariants makes the handler check each compressed variant it
+// negotiates against the original file, and refuse to serve one which is
+// older, as it was probably not regenerated when the original changed. The
+// next best encoding is tried instead, and failing that the original. This
+// costs a stat of the original on each request for a compressed variant,
+// unless WithIndex is used. Variants with no original, or no modification
+// time, are served as usual.
+func WithSkipStaleVariants() Option {
+	return func(f *fileHandler) {
+		f.skipStale = true
+	}
+}
+
+// staleVariant reports whether the variant v of fpath is older than the
+// original file, if available says there is one.
+func (f *fileHandler) staleVariant(r *http.Request, fpath string, v variant, encs []encoding, available encodingSet) bool {
+	if !available.has(indexOfEncoding(encs, identityEncoding)) || isZeroTime(v.info.ModTime()) {
+		return false
+	}
+	mtime, ok := f.originalModTime(fpath)
+	if !ok || !v.info.ModTime().Before(mtime) {
+		return false
+	}
+	if f.logger != nil {
+		f.debug(r, "gzipped: skipping stale variant", "path", fpath, "file", v.name)
+	}
+	return true
+}
+
+// clearVariantHeaders removes the headers openVariant set for a variant
+// which isn't going to be served after all. Vary is left, as the choice
+// still depended on Accept-Encoding.
+func clearVariantHeaders(w http.ResponseWriter) {
+	h := w.Header()
+	delete(h, contentEncodingHeader)
+	delete(h, contentLengthHeader)
+}
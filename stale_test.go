@@ -0,0 +1,60 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSkipStaleVariants(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for name, age := range map[string]time.Duration{
+		"app.js":    0,
+		"app.js.br": time.Hour,
+		"app.js.gz": -time.Second,
+		"lib.js.gz": time.Hour,
+	} {
+		p := dir + "/" + name
+		if err := os.WriteFile(p, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := FileServer(Dir(dir), WithSkipStaleVariants())
+	for _, tc := range []struct {
+		path, ae, expect string
+	}{
+		{"/app.js", "br, gzip", "gzip"},
+		{"/app.js", "br", ""},
+		{"/lib.js", "gzip", "gzip"},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		enc := rr.Header().Get("Content-Encoding")
+		if rr.Code != http.StatusOK || enc != tc.expect {
+			t.Errorf("GET %s (%q): %d with Content-Encoding %q, expected %q", tc.path, tc.ae, rr.Code, enc, tc.expect)
+		}
+		if vary := rr.Header()["Vary"]; len(vary) != 1 {
+			t.Errorf("GET %s (%q): Vary %q", tc.path, tc.ae, vary)
+		}
+		if tc.expect == "" && rr.Body.String() != "app.js" {
+			t.Errorf("GET %s (%q) served %q", tc.path, tc.ae, rr.Body.String())
+		}
+	}
+
+	// Without the option the stale variant is served.
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/app.js", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	FileServer(Dir(dir)).ServeHTTP(rr, req)
+	if enc := rr.Header().Get("Content-Encoding"); enc != "br" {
+		t.Errorf("served %q without WithSkipStaleVariants", enc)
+	}
+}
@@ -0,0 +1,144 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// variantMeta records which variants of a file exist.
+type variantMeta struct {
+	available encodingSet
+	// FileInfo for each variant, indexed like the encoding list, or nil if
+	// the variants haven't been statted yet. Entries for variants which
+	// don't exist are nil.
+	infos   []os.FileInfo
+	expires time.Time
+}
+
+// info returns the FileInfo of variant i, or nil if it isn't known.
+func (m *variantMeta) info(i int) os.FileInfo {
+	if m.infos == nil {
+		return nil
+	}
+	return m.infos[i]
+}
+
+func (m *variantMeta) expired() bool {
+	return time.Now().After(m.expires)
+}
+
+// statCache remembers which variants of each requested file exist, so that
+// they don't need to be looked for on every request. It's sharded so that
+// concurrent requests rarely contend for the same lock.
+type statCache struct {
+	ttl      time.Duration
+	perShard int
+	entries  shardedMap[*variantMeta]
+	bypass   atomic.Bool
+}
+
+// Defaults for the stat cache.
+const (
+	defaultStatTTL        = 10 * time.Second
+	defaultStatMaxEntries = 10000
+)
+
+func newStatCache(ttl time.Duration, maxEntries int) *statCache {
+	return &statCache{ttl: ttl, perShard: perShard(maxEntries)}
+}
+
+// get returns the cached metadata for the logical file fpath, if there is
+// any which hasn't expired.
+func (c *statCache) get(fpath string) (*variantMeta, bool) {
+	if c.bypass.Load() {
+		return nil, false
+	}
+	m, ok := c.entries.get(fpath)
+	if !ok || m.expired() {
+		return nil, false
+	}
+	return m, true
+}
+
+// put caches metadata for fpath. If the cache is full, expired entries are
+// purged to make room; if there are none, the new entry isn't cached.
+func (c *statCache) put(fpath string, m *variantMeta) {
+	if c.bypass.Load() {
+		return
+	}
+	m.expires = time.Now().Add(c.ttl)
+	c.entries.put(fpath, m, c.perShard, (*variantMeta).expired)
+}
+
+// StatCacheConfig configures a StatCache. Zero values mean the defaults.
+type StatCacheConfig struct {
+	// How long to remember a file's variants, 10 seconds by default.
+	TTL time.Duration
+	// The most files to remember, 10000 by default.
+	MaxEntries int
+	// Bypass starts the cache bypassed; see SetBypass.
+	Bypass bool
+}
+
+// StatCache remembers which variants of each requested file exist, so that
+// a busy server doesn't have to look for them on the FileSystem on every
+// request. Create one with NewStatCache and pass it to FileServer with
+// WithStatCache; it can be shared between handlers serving the same files.
+// A StatCache is safe for concurrent use.
+type StatCache struct {
+	cache *statCache
+}
+
+// NewStatCache returns an empty StatCache.
+func NewStatCache(cfg StatCacheConfig) *StatCache {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultStatTTL
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultStatMaxEntries
+	}
+	c := &StatCache{cache: newStatCache(cfg.TTL, cfg.MaxEntries)}
+	c.cache.bypass.Store(cfg.Bypass)
+	return c
+}
+
+// Invalidate forgets what's known about the named files, so that the next
+// request for each looks on the FileSystem again. Names may be logical file
+// names or the names of variants, such as "/css/site.css.gz".
+func (c *StatCache) Invalidate(names ...string) {
+	for _, name := range names {
+		c.cache.entries.delete(name)
+		for _, enc := range preferredEncodings {
+			if enc.ext != "" && strings.HasSuffix(name, enc.ext) {
+				c.cache.entries.delete(strings.TrimSuffix(name, enc.ext))
+			}
+		}
+	}
+}
+
+// Purge forgets everything in the cache.
+func (c *StatCache) Purge() {
+	c.cache.entries.clear()
+}
+
+// SetBypass turns the cache off, when bypass is true, or back on. While it's
+// bypassed every request looks on the FileSystem, which suits development,
+// where files change all the time. Turning it back on starts from empty.
+func (c *StatCache) SetBypass(bypass bool) {
+	if !c.cache.bypass.Swap(bypass) && bypass {
+		c.Purge()
+	}
+}
+
+// WithStatCache makes the handler cache which variants of each file exist in
+// c. Without it, nothing is cached unless WithPrefetch is used, which makes
+// a cache of its own.
+func WithStatCache(c *StatCache) Option {
+	return func(f *fileHandler) {
+		f.stats = c.cache
+	}
+}
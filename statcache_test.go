@@ -0,0 +1,69 @@
+//go:build !gzipped_minimal
+
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStatCache(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string) {
+		if err := os.WriteFile(dir+"/"+name, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("site.css")
+	root := newCountingFS(Dir(dir))
+	cache := NewStatCache(StatCacheConfig{TTL: time.Hour})
+	h := FileServer(root, WithStatCache(cache))
+	get := func() string {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/site.css", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		h.ServeHTTP(rr, req)
+		return rr.Header().Get("Content-Encoding")
+	}
+	get()
+	get()
+	if n := root.count("exists:/site.css.gz"); n != 1 {
+		t.Errorf("looked for variant %d times, expected once", n)
+	}
+
+	// New variants aren't seen until the cache is invalidated.
+	write("site.css.gz")
+	if enc := get(); enc != "" {
+		t.Errorf("served uncached encoding %q", enc)
+	}
+	cache.Invalidate("/site.css.gz")
+	if enc := get(); enc != "gzip" {
+		t.Errorf("served %q after invalidation, expected gzip", enc)
+	}
+
+	os.Remove(dir + "/site.css.gz")
+	cache.Purge()
+	if enc := get(); enc != "" {
+		t.Errorf("served %q after purge", enc)
+	}
+
+	cache.SetBypass(true)
+	write("site.css.gz")
+	n := root.count("exists:/site.css.gz")
+	if enc := get(); enc != "gzip" {
+		t.Errorf("served %q while bypassed, expected gzip", enc)
+	}
+	get()
+	if root.count("exists:/site.css.gz") != n+2 {
+		t.Error("bypassed cache was used")
+	}
+	cache.SetBypass(false)
+	get()
+	get()
+	if root.count("exists:/site.css.gz") != n+3 {
+		t.Error("cache wasn't used after bypass was turned off")
+	}
+}
@@ -0,0 +1,159 @@
+package gzipped
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// seekable reports whether file supports seeking, which http.ServeContent
+// needs. Files from streaming backends such as object storage or pipes may
+// return errors from Seek.
+func seekable(file http.File) bool {
+	switch file.(type) {
+	case *os.File, *memFile:
+		// Files from Dir and the memory cache always seek, so don't pay
+		// for a system call to check.
+		return true
+	}
+	_, err := file.Seek(0, io.SeekCurrent)
+	return err == nil
+}
+
+// serveStream sends a file which can't seek. Without seeking, ranges can't be
+// served, so the whole file is always sent. If the file's size is known it
+// is sent as the Content-Length; a negative size means unknown, and the
+// response is sent chunked.
+func serveStream(w http.ResponseWriter, r *http.Request, v variant) {
+	h := w.Header()
+	h.Set("Accept-Ranges", "none")
+	mtime := v.info.ModTime()
+	if !isZeroTime(mtime) {
+		h.Set("Last-Modified", mtime.UTC().Format(http.TimeFormat))
+	}
+	if notModified(w, r, mtime) {
+		writeNotModified(w)
+		return
+	}
+
+	br := bufio.NewReaderSize(v.file, sniffLen)
+	if _, haveType := h[contentTypeHeader]; !haveType {
+		// Only identity files get here untyped, so it's safe to sniff.
+		buf, _ := br.Peek(sniffLen)
+		h.Set(contentTypeHeader, http.DetectContentType(buf))
+	}
+	if size := v.info.Size(); size >= 0 {
+		h.Set(contentLengthHeader, strconv.FormatInt(size, 10))
+	} else {
+		delete(h, contentLengthHeader)
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = io.Copy(w, br)
+	}
+}
+
+// writeNotModified responds 304 Not Modified, removing the headers which
+// describe a body.
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	delete(h, contentTypeHeader)
+	delete(h, contentLengthHeader)
+	delete(h, contentEncodingHeader)
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// The most bytes of a non-seekable file which will be kept so that it can be
+// rewound after sniffing its content type.
+const maxRewind = 64 << 10
+
+// rewindFile wraps a file which can't seek, recording what's read from it so
+// that it can be rewound to the start once, as long as not too much has been
+// read. That's enough to let a compressed stream be decoded to sniff its
+// content type, and then sent.
+type rewindFile struct {
+	http.File
+	buf       []byte
+	pos       int  // read position in buf, once rewound
+	rewound   bool // whether Seek(0, io.SeekStart) has been called
+	overflown bool // whether more than maxRewind bytes have been read
+}
+
+func (rf *rewindFile) Read(p []byte) (int, error) {
+	if rf.rewound && rf.pos < len(rf.buf) {
+		n := copy(p, rf.buf[rf.pos:])
+		rf.pos += n
+		return n, nil
+	}
+	n, err := rf.File.Read(p)
+	if !rf.rewound && !rf.overflown {
+		if len(rf.buf)+n > maxRewind {
+			rf.overflown, rf.buf = true, nil
+		} else {
+			rf.buf = append(rf.buf, p[:n]...)
+		}
+	}
+	return n, err
+}
+
+func (rf *rewindFile) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart || rf.rewound || rf.overflown {
+		return 0, errors.New("seek not supported")
+	}
+	rf.rewound = true
+	return 0, nil
+}
+
+func isZeroTime(t time.Time) bool {
+	return t.IsZero() || t.Equal(time.Unix(0, 0))
+}
+
+// notModified evaluates the conditional headers of a GET or HEAD request, as
+// http.ServeContent would: If-None-Match against the ETag already set on the
+// response if there is one, otherwise If-Modified-Since against mtime.
+func notModified(w http.ResponseWriter, r *http.Request, mtime time.Time) bool {
+	return notModifiedBy(r, w.Header().Get("Etag"), mtime)
+}
+
+// notModifiedBy is notModified for a representation with the validators
+// etag, which may be empty, and mtime.
+func notModifiedBy(r *http.Request, etag string, mtime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || isZeroTime(mtime) {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !mtime.Truncate(time.Second).After(t)
+}
+
+// etagMatches reports whether the If-None-Match list inm matches etag, using
+// the weak comparison RFC 9110 section 13.1.2 calls for.
+func etagMatches(inm, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for inm != "" {
+		var tag string
+		tag, inm = cut(inm, ',')
+		tag = trimOWS(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
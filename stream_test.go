@@ -0,0 +1,100 @@
+package gzipped
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// streamFS returns files which can't seek, optionally hiding their size.
+type streamFS struct {
+	FileSystem
+	unknownSize bool
+}
+
+type streamFile struct {
+	http.File
+	unknownSize bool
+}
+
+type unknownSizeInfo struct {
+	os.FileInfo
+}
+
+func (unknownSizeInfo) Size() int64 { return -1 }
+
+func (s streamFile) Seek(int64, int) (int64, error) {
+	return 0, errors.New("can't seek")
+}
+
+func (s streamFile) Stat() (os.FileInfo, error) {
+	info, err := s.File.Stat()
+	if err == nil && s.unknownSize {
+		info = unknownSizeInfo{info}
+	}
+	return info, err
+}
+
+func (s streamFS) Open(name string) (http.File, error) {
+	file, err := s.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return streamFile{File: file, unknownSize: s.unknownSize}, nil
+}
+
+func TestStreaming(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		root   streamFS
+		path   string
+		accept string
+		length string
+		ctype  string
+	}{
+		{"identity", streamFS{Dir("./testdata/"), false}, "/file2.txt", "", "20", "text/plain; charset=utf-8"},
+		{"gzip", streamFS{Dir("./testdata/"), false}, "/file.txt", "gzip", "47", "text/plain; charset=utf-8"},
+		{"sniffed", streamFS{Dir("./testdata/"), false}, "/page", "gzip", "", "text/html; charset=utf-8"},
+		{"chunked", streamFS{Dir("./testdata/"), true}, "/file2.txt", "", "", "text/plain; charset=utf-8"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			req.Header.Set("Range", "bytes=0-3")
+			if tc.accept != "" {
+				req.Header.Set("Accept-Encoding", tc.accept)
+			}
+			FileServer(tc.root).ServeHTTP(rr, req)
+			h := rr.Header()
+			if rr.Code != 200 {
+				t.Fatalf("status %d, expected the full body", rr.Code)
+			}
+			if h.Get("Accept-Ranges") != "none" {
+				t.Errorf("Accept-Ranges %q", h.Get("Accept-Ranges"))
+			}
+			if tc.length != "" && h.Get("Content-Length") != tc.length {
+				t.Errorf("Content-Length %q, expected %s", h.Get("Content-Length"), tc.length)
+			}
+			if tc.length == "" && tc.name == "chunked" && h.Get("Content-Length") != "" {
+				t.Errorf("Content-Length %q for unknown size", h.Get("Content-Length"))
+			}
+			if h.Get("Content-Type") != tc.ctype {
+				t.Errorf("Content-Type %q, expected %q", h.Get("Content-Type"), tc.ctype)
+			}
+			if n, err := strconv.Atoi(tc.length); err == nil && rr.Body.Len() != n {
+				t.Errorf("body is %d bytes", rr.Body.Len())
+			}
+
+			// Conditional requests still work.
+			rr2 := httptest.NewRecorder()
+			req.Header.Set("If-Modified-Since", h.Get("Last-Modified"))
+			FileServer(tc.root).ServeHTTP(rr2, req)
+			if rr2.Code != http.StatusNotModified {
+				t.Errorf("conditional request returned %d", rr2.Code)
+			}
+		})
+	}
+}
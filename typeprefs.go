@@ -0,0 +1,87 @@
+package gzipped
+
+import "strings"
+
+// WithTypePreferences varies the server's preference between encodings by
+// content type, since how much brotli gains over gzip depends on the kind of
+// data. prefs maps media types, or patterns like "text/*", to encoding names
+// in order of preference; encodings not listed keep their default order
+// after the listed ones. For example:
+//
+//	gzipped.WithTypePreferences(map[string][]string{
+//		"text/*":                   {"br", "gzip"},
+//		"application/javascript":   {"br", "gzip"},
+//		"application/octet-stream": {"gzip", "br"},
+//	})
+//
+// Preferences only break ties: an encoding the client gives a higher q-value
+// still wins. The type is found from the file name's extension.
+func WithTypePreferences(prefs map[string][]string) Option {
+	orders := make(map[string][]int, len(prefs))
+	for pattern, names := range prefs {
+		orders[strings.ToLower(pattern)] = encodingOrder(preferredEncodings, names)
+	}
+	return func(f *fileHandler) {
+		f.typeOrders = orders
+	}
+}
+
+// WithEncodingPreference sets the server's order of preference between
+// encodings, most preferred first, for when the client is equally happy with
+// several: for example ("gzip", "br") to send gzip where brotli files were
+// only compressed at a low quality, or are slower for clients to decode.
+// Encodings not listed keep their default order after the listed ones.
+// WithTypePreferences takes precedence for the types it lists.
+func WithEncodingPreference(names ...string) Option {
+	order := encodingOrder(preferredEncodings, names)
+	return func(f *fileHandler) {
+		f.defaultOrder = order
+	}
+}
+
+// encodingOrder returns a permutation of the indexes of encs with the named
+// encodings first, in the order given, and the rest in their original order.
+func encodingOrder(encs []encoding, names []string) []int {
+	order := make([]int, 0, len(encs))
+	var used encodingSet
+	for _, name := range names {
+		if i := indexOfEncoding(encs, strings.ToLower(name)); i >= 0 && !used.has(i) {
+			order = append(order, i)
+			used |= 1 << uint(i)
+		}
+	}
+	for i := range encs {
+		if !used.has(i) {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// typeOrder returns the encoding preference order for the file fpath, or nil
+// for the order of the encodings themselves.
+func (f *fileHandler) typeOrder(fpath string) []int {
+	if f.typeOrders == nil {
+		return f.defaultOrder
+	}
+	ctype := f.typeByExtension(f.untranslated(fpath))
+	if ctype == "" {
+		return f.defaultOrder
+	}
+	if i := strings.IndexByte(ctype, ';'); i >= 0 {
+		ctype = ctype[:i]
+	}
+	ctype = strings.ToLower(strings.TrimSpace(ctype))
+	if order, ok := f.typeOrders[ctype]; ok {
+		return order
+	}
+	if i := strings.IndexByte(ctype, '/'); i >= 0 {
+		if order, ok := f.typeOrders[ctype[:i]+"/*"]; ok {
+			return order
+		}
+	}
+	if order, ok := f.typeOrders["*/*"]; ok {
+		return order
+	}
+	return f.defaultOrder
+}
@@ -0,0 +1,78 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestEncodingOrder(t *testing.T) {
+	for _, tc := range []struct {
+		names  []string
+		expect []int
+	}{
+		{nil, []int{0, 1, 2, 3}},
+		{[]string{"gzip"}, []int{2, 0, 1, 3}},
+		{[]string{"GZIP", "br"}, []int{2, 0, 1, 3}},
+		{[]string{"identity", "zstd", "gzip", "gzip"}, []int{3, 1, 2, 0}},
+		{[]string{"identity", "deflate", "gzip"}, []int{3, 2, 0, 1}},
+	} {
+		if got := encodingOrder(preferredEncodings, tc.names); !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("encodingOrder(%v) = %v, expected %v", tc.names, got, tc.expect)
+		}
+	}
+}
+
+func TestTypePreferences(t *testing.T) {
+	h := FileServer(Dir("./testdata/"), WithTypePreferences(map[string][]string{
+		"text/*":    {"gzip", "br"},
+		"text/html": {"br"},
+	}))
+	for _, tc := range []struct {
+		path, ae, expect string
+	}{
+		{"/app.js", "br, gzip", "gzip"},
+		{"/app.js", "*", "gzip"},
+		{"/app.js", "br;q=1, gzip;q=0.5", "br"},
+		{"/app.js", "br", "br"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Content-Encoding"); got != tc.expect {
+			t.Errorf("GET %s (%q): Content-Encoding %q, expected %q", tc.path, tc.ae, got, tc.expect)
+		}
+	}
+
+	f := h.(*fileHandler)
+	if order := f.typeOrder("/index.html"); !reflect.DeepEqual(order, []int{0, 1, 2, 3}) {
+		t.Errorf("exact type match gave order %v", order)
+	}
+	if order := f.typeOrder("/image.png"); order != nil {
+		t.Errorf("unmatched type gave order %v", order)
+	}
+}
+
+func TestEncodingPreference(t *testing.T) {
+	h := FileServer(Dir("./testdata/"),
+		WithEncodingPreference("gzip", "br"),
+		WithTypePreferences(map[string][]string{"text/css": {"br"}}))
+	for _, tc := range []struct {
+		path, ae, expect string
+	}{
+		{"/app.js", "br, gzip", "gzip"},
+		{"/app.js", "br;q=1, gzip;q=0.5", "br"},
+		{"/page", "br, gzip", "gzip"},
+		{"/brotli.css", "br, gzip", "br"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Content-Encoding"); got != tc.expect {
+			t.Errorf("GET %s (%q): Content-Encoding %q, expected %q", tc.path, tc.ae, got, tc.expect)
+		}
+	}
+}
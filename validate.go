@@ -0,0 +1,146 @@
+package gzipped
+
+import (
+	"errors"
+	"fmt"
+	fs2 "io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ProblemKind is the kind of a Problem found by Validate.
+type ProblemKind int
+
+const (
+	// StaleVariant is a compressed variant older than its original, which
+	// probably wasn't regenerated when the original changed.
+	StaleVariant ProblemKind = iota + 1
+	// LargerVariant is a compressed variant bigger than its original, which
+	// wastes bandwidth rather than saving it.
+	LargerVariant
+	// OrphanVariant is a compressed variant with no original. That's
+	// expected if you deploy only compressed files; otherwise the original
+	// was probably deleted or renamed.
+	OrphanVariant
+	// UnreadableFile is a file or directory which couldn't be examined.
+	UnreadableFile
+)
+
+func (k ProblemKind) String() string {
+	switch k {
+	case StaleVariant:
+		return "stale variant"
+	case LargerVariant:
+		return "variant larger than original"
+	case OrphanVariant:
+		return "orphaned variant"
+	case UnreadableFile:
+		return "unreadable"
+	}
+	return fmt.Sprintf("ProblemKind(%d)", int(k))
+}
+
+// Problem is something wrong with the files in a FileSystem, found by
+// Validate.
+type Problem struct {
+	Kind     ProblemKind
+	Name     string // the variant, or for UnreadableFile the file or directory
+	Original string // the variant's original file, if it has one
+	Err      error  // for UnreadableFile, what went wrong
+}
+
+func (p Problem) String() string {
+	if p.Err != nil {
+		return p.Name + ": " + p.Kind.String() + ": " + p.Err.Error()
+	}
+	return p.Name + ": " + p.Kind.String()
+}
+
+// Validate walks root, which must implement DirReader, and returns the
+// problems it finds with compressed variants: variants older than their
+// originals, larger than them, or with no original at all. Files with no
+// modification time, as in an embed.FS, are never counted as stale. The
+// problems are sorted by name, and there are none if everything's in order.
+//
+// It's meant for deployment pipelines and startup checks, to fail fast on
+// stale .gz and .br files rather than serve them.
+func Validate(root FileSystem) []Problem {
+	dr, ok := root.(DirReader)
+	if !ok {
+		return []Problem{{Kind: UnreadableFile, Name: "/", Err: errors.New("FileSystem can't list directories")}}
+	}
+	var problems []Problem
+	validateDir(dr, "/", &problems)
+	sort.Slice(problems, func(i, j int) bool {
+		if problems[i].Name != problems[j].Name {
+			return problems[i].Name < problems[j].Name
+		}
+		return problems[i].Kind < problems[j].Kind
+	})
+	return problems
+}
+
+func validateDir(dr DirReader, dir string, problems *[]Problem) {
+	entries, err := dr.ReadDir(dir)
+	if err != nil {
+		*problems = append(*problems, Problem{Kind: UnreadableFile, Name: dir, Err: err})
+		return
+	}
+	files := make(map[string]fs2.DirEntry, len(entries))
+	for _, de := range entries {
+		if de.IsDir() {
+			validateDir(dr, path.Join(dir, de.Name()), problems)
+			continue
+		}
+		files[de.Name()] = de
+	}
+	for name, de := range files {
+		for _, enc := range preferredEncodings {
+			if enc.ext == "" || !strings.HasSuffix(name, enc.ext) {
+				continue
+			}
+			p := Problem{Name: path.Join(dir, name)}
+			orig, ok := files[strings.TrimSuffix(name, enc.ext)]
+			if !ok {
+				p.Kind = OrphanVariant
+				*problems = append(*problems, p)
+				continue
+			}
+			p.Original = path.Join(dir, orig.Name())
+			vinfo, err := de.Info()
+			if err != nil {
+				*problems = append(*problems, Problem{Kind: UnreadableFile, Name: p.Name, Err: err})
+				continue
+			}
+			oinfo, err := orig.Info()
+			if err != nil {
+				*problems = append(*problems, Problem{Kind: UnreadableFile, Name: p.Original, Err: err})
+				continue
+			}
+			if !isZeroTime(vinfo.ModTime()) && vinfo.ModTime().Before(oinfo.ModTime()) {
+				p.Kind = StaleVariant
+				*problems = append(*problems, p)
+			}
+			if vinfo.Size() > oinfo.Size() {
+				p.Kind = LargerVariant
+				*problems = append(*problems, p)
+			}
+		}
+	}
+}
+
+// WithValidation runs Validate on the handler's FileSystem when FileServer
+// is called, and passes any problems found to onProblems, which might log
+// them or, in a strict deployment, exit:
+//
+//	gzipped.WithValidation(func(problems []gzipped.Problem) {
+//		log.Fatalf("stale static files: %v", problems)
+//	})
+func WithValidation(onProblems func([]Problem)) Option {
+	return func(f *fileHandler) {
+		if problems := Validate(f.root); len(problems) > 0 {
+			onProblems(problems)
+		}
+	}
+}
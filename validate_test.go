@@ -0,0 +1,60 @@
+package gzipped
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	write := func(name, content string, age time.Duration) {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.css", "body{}", 0)
+	write("a.css.gz", "old", time.Hour)
+	write("b.js", "x", 0)
+	write("b.js.br", "much larger", 0)
+	write("c.txt.gz", "orphan", 0)
+	write("d.txt", "fine, thanks", 0)
+	write("d.txt.gz", "fine", time.Second*-1)
+	write("sub/e.html", "<p>", 0)
+	write("sub/e.html.zst", "old and larger", time.Minute)
+
+	var got []string
+	for _, p := range Validate(Dir(dir)) {
+		got = append(got, p.String())
+	}
+	expect := []string{
+		"/a.css.gz: stale variant",
+		"/b.js.br: variant larger than original",
+		"/c.txt.gz: orphaned variant",
+		"/sub/e.html.zst: stale variant",
+		"/sub/e.html.zst: variant larger than original",
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("problems %q, expected %q", got, expect)
+	}
+
+	if ps := Validate(struct{ FileSystem }{Dir(dir)}); len(ps) != 1 || ps[0].Kind != UnreadableFile {
+		t.Errorf("validating a FileSystem which can't list directories gave %v", ps)
+	}
+
+	var reported []Problem
+	FileServer(Dir(dir), WithValidation(func(ps []Problem) { reported = ps }))
+	if len(reported) != len(expect) || reported[0].Original != "/a.css" {
+		t.Errorf("WithValidation reported %v", reported)
+	}
+}
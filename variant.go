@@ -0,0 +1,84 @@
+package gzipped
+
+import (
+	"net/http"
+	"path"
+	"time"
+)
+
+// Variant describes the representation of a file chosen for a request.
+type Variant struct {
+	Name     string // name of the file on the FileSystem
+	Encoding string // content-coding of the response, "identity" if none
+	Size     int64  // size of the file
+	ModTime  time.Time
+	// Decoded is set when the client accepts none of the compressed
+	// variants of a file which has no uncompressed original, so Name is
+	// decompressed on the fly and Size is its compressed size.
+	Decoded bool
+}
+
+// BestVariant returns the representation of the file name from root which
+// ServeFile would send in response to r, without sending it, so that it can
+// be logged or announced in a Link preload header. opts are applied as for
+// ServeFile, and should match those of the FileServer actually serving the
+// file.
+//
+// If the file doesn't exist the error satisfies errors.Is(err,
+// fs.ErrNotExist), and if it exists only in encodings the client refuses it
+// is ErrNotAcceptable. A *VariantError is returned if a variant can't be
+// opened and WithStrictVariants is used.
+func BestVariant(r *http.Request, root FileSystem, name string, opts ...Option) (Variant, error) {
+	f := FileServer(root, opts...).(*fileHandler)
+	return f.bestVariant(r, path.Clean("/"+name))
+}
+
+func (f *fileHandler) bestVariant(r *http.Request, fpath string) (Variant, error) {
+	if f.hideDotfiles && f.hiddenPath(fpath) {
+		return Variant{}, notFoundError(fpath)
+	}
+	// Negotiation sets response headers as it goes, which aren't wanted.
+	w := headerWriter(http.Header{})
+	if f.languages != nil {
+		fpath = f.negotiateLanguage(w, r, fpath)
+	}
+	v, err := f.findBestFile(w, r, fpath)
+	if err != nil && f.spaRoute(fpath, err) {
+		fpath = f.spaIndex
+		v, err = f.findBestFile(w, r, fpath)
+	}
+	if err != nil {
+		return Variant{}, err
+	}
+	v.file.Close()
+	if f.modTimeSource != ModTimeVariant {
+		v.info = f.variantModTime(fpath, v)
+	}
+	if f.modTimeSource == ModTimeNone {
+		v.info = modTimeInfo{FileInfo: v.info}
+	}
+	return v.public(), nil
+}
+
+// public returns the Variant describing v.
+func (v variant) public() Variant {
+	enc := v.encoding
+	if v.decode {
+		enc = identityEncoding
+	}
+	return Variant{
+		Name:     v.name,
+		Encoding: enc,
+		Size:     v.info.Size(),
+		ModTime:  v.info.ModTime(),
+		Decoded:  v.decode,
+	}
+}
+
+// headerWriter is a ResponseWriter which collects headers and discards
+// everything else.
+type headerWriter http.Header
+
+func (hw headerWriter) Header() http.Header         { return http.Header(hw) }
+func (hw headerWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (hw headerWriter) WriteHeader(int)             {}
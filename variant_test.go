@@ -0,0 +1,46 @@
+package gzipped
+
+import (
+	"errors"
+	fs2 "io/fs"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestBestVariant(t *testing.T) {
+	gz, err := os.Stat("testdata/file.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, ae string
+		expect   Variant
+	}{
+		{"/file.txt", "gzip", Variant{Name: "/file.txt.gz", Encoding: "gzip", Size: gz.Size(), ModTime: gz.ModTime()}},
+		{"file.txt", "", Variant{Name: "/file.txt", Encoding: "identity", Size: 27}},
+		{"/page", "", Variant{Name: "/page.gz", Encoding: "identity", Decoded: true}},
+		{"/page", "br", Variant{Name: "/page.br", Encoding: "br"}},
+	} {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		v, err := BestVariant(req, Dir("./testdata/"), tc.name)
+		if err != nil {
+			t.Errorf("BestVariant %s (%q): %v", tc.name, tc.ae, err)
+			continue
+		}
+		if v.Name != tc.expect.Name || v.Encoding != tc.expect.Encoding || v.Decoded != tc.expect.Decoded ||
+			(tc.expect.Size != 0 && v.Size != tc.expect.Size) || (!tc.expect.ModTime.IsZero() && !v.ModTime.Equal(tc.expect.ModTime)) {
+			t.Errorf("BestVariant %s (%q) = %+v, expected %+v", tc.name, tc.ae, v, tc.expect)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	if _, err := BestVariant(req, Dir("./testdata/"), "/nonexistent.txt"); !errors.Is(err, fs2.ErrNotExist) {
+		t.Errorf("BestVariant of a missing file returned %v", err)
+	}
+	req.Header.Set("Accept-Encoding", "identity, *;q=0")
+	if _, err := BestVariant(req, Dir("./testdata/"), "/brotli.css"); err != ErrNotAcceptable {
+		t.Errorf("BestVariant of an unacceptable file returned %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package gzipped
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+)
+
+// VariantFS adapts a FileSystem for code which can only serve from an
+// http.FileSystem, such as http.FileServer or a web framework's static file
+// support. For each request, For returns an http.FileSystem whose Open
+// negotiates a compressed variant the same way FileServer does, and sets the
+// Content-Encoding and related response headers to match.
+type VariantFS struct {
+	h *fileHandler
+}
+
+// NewVariantFS returns a VariantFS serving files from root. Options apply as
+// they would for FileServer, and any caches they create are shared by all
+// requests.
+func NewVariantFS(root FileSystem, opts ...Option) *VariantFS {
+	return &VariantFS{h: FileServer(root, opts...).(*fileHandler)}
+}
+
+// For returns an http.FileSystem which chooses variants to suit r, setting
+// headers on w as it does. It should only be used to serve r.
+//
+// The files returned report the size of the variant, but the name of the
+// file that was asked for, so that the Content-Type is chosen correctly.
+// Files which only exist in compressed forms that r doesn't accept are
+// reported as not existing.
+func (v *VariantFS) For(w http.ResponseWriter, r *http.Request) http.FileSystem {
+	return &requestFS{h: v.h, w: w, r: r}
+}
+
+type requestFS struct {
+	h       *fileHandler
+	w       http.ResponseWriter
+	r       *http.Request
+	encoded bool // headers for a compressed variant have been set
+}
+
+func (fsys *requestFS) Open(name string) (http.File, error) {
+	if fsys.encoded {
+		// A previously opened variant wasn't used after all.
+		h := fsys.w.Header()
+		delete(h, contentEncodingHeader)
+		delete(h, contentLengthHeader)
+		delete(h, contentTypeHeader)
+		fsys.encoded = false
+	}
+	fpath := path.Clean("/" + name)
+	v, err := fsys.h.findBestFile(fsys.w, fsys.r, fpath)
+	if err != nil {
+		var verr *VariantError
+		switch {
+		case errors.As(err, &verr):
+			fsys.h.reportError(fsys.r, err)
+			return nil, err
+		case errors.Is(err, ErrNotAcceptable):
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		// Directories, and anything else the handler wouldn't serve, are
+		// left to the underlying FileSystem.
+		return fsys.h.root.Open(name)
+	}
+	if v.decode {
+		v.file.Close()
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if v.encoding == identityEncoding {
+		return v.file, nil
+	}
+	fsys.encoded = true
+	fsys.h.setContentType(fsys.w, fpath, v)
+	return &variantFile{File: v.file, info: renamedInfo{FileInfo: v.info, name: path.Base(fpath)}}, nil
+}
+
+// variantFile is a compressed variant presented under the name of the file
+// it's a variant of.
+type variantFile struct {
+	http.File
+	info os.FileInfo
+}
+
+func (f *variantFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+type renamedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i renamedInfo) Name() string {
+	return i.name
+}
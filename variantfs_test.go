@@ -0,0 +1,86 @@
+package gzipped
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVariantFS(t *testing.T) {
+	vfs := NewVariantFS(Dir("./testdata/"))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.FileServer(vfs.For(w, r)).ServeHTTP(w, r)
+	})
+	gz, err := ioutil.ReadFile("testdata/file.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := ioutil.ReadFile("testdata/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		path, ae string
+		status   int
+		encoding string
+		body     []byte
+	}{
+		{"/file.txt", "gzip", http.StatusOK, "gzip", gz},
+		{"/file.txt", "", http.StatusOK, "", plain},
+		{"/file.txt", "deflate", http.StatusOK, "", plain},
+		{"/app.js", "br", http.StatusOK, "br", nil},
+		{"/app.js", "", http.StatusNotFound, "", nil},
+		{"/missing.txt", "gzip", http.StatusNotFound, "", nil},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.ae != "" {
+			req.Header.Set("Accept-Encoding", tc.ae)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("GET %s (%q): status %d, expected %d", tc.path, tc.ae, rec.Code, tc.status)
+			continue
+		}
+		if got := rec.Header().Get("Content-Encoding"); got != tc.encoding {
+			t.Errorf("GET %s (%q): Content-Encoding %q, expected %q", tc.path, tc.ae, got, tc.encoding)
+		}
+		if tc.body != nil && rec.Body.String() != string(tc.body) {
+			t.Errorf("GET %s (%q): wrong body", tc.path, tc.ae)
+		}
+		if tc.status == http.StatusOK && tc.encoding != "" {
+			if got := rec.Header().Get("Content-Type"); got == "" || got == "application/x-gzip" {
+				t.Errorf("GET %s (%q): Content-Type %q", tc.path, tc.ae, got)
+			}
+		}
+	}
+}
+
+func TestVariantFSReopen(t *testing.T) {
+	vfs := NewVariantFS(Dir("./testdata/"))
+	req := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	fsys := vfs.For(rec, req)
+	f, err := fsys.Open("/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := f.Stat()
+	if info.Name() != "file.txt" {
+		t.Errorf("variant reports name %q", info.Name())
+	}
+	f.Close()
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding not set")
+	}
+	f, err = fsys.Open("/file2.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding %q left over from first open", got)
+	}
+}
@@ -0,0 +1,44 @@
+package gzipped
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Vary header value for negotiated responses, allocated once up front.
+var varyAcceptEncoding = []string{acceptEncodingHeader}
+
+// addVary adds the request header name to the Vary header, unless it's
+// already listed there, in any of the header's values and whatever its
+// case, or Vary is "*". Values set by other handlers are kept.
+func addVary(h http.Header, name string) {
+	vary := h[varyHeader]
+	if len(vary) == 0 {
+		if name == acceptEncodingHeader {
+			h[varyHeader] = varyAcceptEncoding
+		} else {
+			h[varyHeader] = []string{name}
+		}
+		return
+	}
+	if varies(vary, name) {
+		return
+	}
+	// Appending can't modify varyAcceptEncoding in place, as it has no
+	// spare capacity.
+	h[varyHeader] = append(vary, name)
+}
+
+// varies reports whether the Vary header values vary list name, or "*".
+func varies(vary []string, name string) bool {
+	for _, v := range vary {
+		for v != "" {
+			var field string
+			field, v = cut(v, ',')
+			if field = trimOWS(field); field == "*" || strings.EqualFold(field, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package gzipped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVary(t *testing.T) {
+	for _, tc := range []struct {
+		path, ae string
+		before   []string // set by a wrapping handler
+		expect   string
+	}{
+		{"/file.txt", "gzip", nil, "Accept-Encoding"},
+		{"/file.txt", "", nil, "Accept-Encoding"},
+		{"/file.txt", "br", nil, "Accept-Encoding"},
+		{"/file2.txt", "gzip", nil, ""},
+		{"/app.js", "", nil, "Accept-Encoding"},
+		{"/file.txt", "gzip", []string{"Origin"}, "Origin,Accept-Encoding"},
+		{"/file.txt", "", []string{"Origin, accept-encoding"}, "Origin, accept-encoding"},
+		{"/file.txt", "gzip", []string{"Origin", "Accept-Encoding"}, "Origin,Accept-Encoding"},
+		{"/file.txt", "gzip", []string{"*"}, "*"},
+	} {
+		h := FileServer(Dir("./testdata/"))
+		rr := httptest.NewRecorder()
+		if tc.before != nil {
+			rr.Header()["Vary"] = append([]string(nil), tc.before...)
+		}
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		h.ServeHTTP(rr, req)
+		if got := strings.Join(rr.Header()["Vary"], ","); rr.Code != 200 || got != tc.expect {
+			t.Errorf("GET %s with %q after Vary %q: %d with Vary %q, expected %q", tc.path, tc.ae, tc.before, rr.Code, got, tc.expect)
+		}
+	}
+
+	// Compressing on the fly as well doesn't repeat it.
+	h := Compress(FileServer(Dir("./testdata/")))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/file.txt", nil))
+	if got := rr.Header()["Vary"]; len(got) != 1 || got[0] != "Accept-Encoding" {
+		t.Errorf("with Compress: Vary %q", got)
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{}
+	addVary(h, acceptEncodingHeader)
+	addVary(h, acceptLanguageHeader)
+	addVary(h, "accept-language")
+	addVary(h, acceptEncodingHeader)
+	if got := strings.Join(h["Vary"], ","); got != "Accept-Encoding,Accept-Language" {
+		t.Errorf("Vary %q", got)
+	}
+	if varyAcceptEncoding[0] != acceptEncodingHeader || len(varyAcceptEncoding) != 1 {
+		t.Errorf("shared Vary value modified: %q", varyAcceptEncoding)
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/lpar/gzipped/v2/watch
+
+go 1.20
+
+require (
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/lpar/gzipped/v2 v2.0.0-20261014184714-868b424a328c
+)
+
+require golang.org/x/sys v0.4.0 // indirect
@@ -0,0 +1,143 @@
+// Package watch keeps a gzipped.StatCache up to date with a directory on
+// disk, using fsnotify, so that a long-running server picks up redeployed
+// assets as soon as they're written rather than when cache entries expire.
+// It's a separate module, so that the gzipped package itself only depends on
+// the standard library:
+//
+//	cache := gzipped.NewStatCache(gzipped.StatCacheConfig{TTL: time.Hour})
+//	w, err := watch.Watch("./static", cache, func(err error) { log.Print(err) })
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer w.Close()
+//	http.Handle("/", gzipped.FileServer(gzipped.Dir("./static"), gzipped.WithStatCache(cache)))
+package watch
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/lpar/gzipped/v2"
+)
+
+// Watcher invalidates a StatCache as the files in a directory change.
+type Watcher struct {
+	dir     string
+	cache   *gzipped.StatCache
+	onError func(error)
+	fw      *fsnotify.Watcher
+	mu      sync.Mutex
+	dirs    map[string]bool // watched directories, by OS path
+	done    chan struct{}
+}
+
+// Watch watches dir, the directory a gzipped.Dir serves, and every directory
+// below it, including ones created later. Whenever a file is created,
+// written, removed or renamed, or its attributes change, what cache knows
+// about it is invalidated; if a directory is removed or renamed, the whole
+// cache is purged. Errors watching, such as the kernel's event queue
+// overflowing, are passed to onError if it's not nil, and also purge the
+// cache, since changes may have been missed.
+func Watch(dir string, cache *gzipped.StatCache, onError func(error)) (*Watcher, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{dir: dir, cache: cache, onError: onError, fw: fw, dirs: map[string]bool{}, done: make(chan struct{})}
+	if err := w.add(dir, false); err != nil {
+		fw.Close()
+		return nil, err
+	}
+	go w.run()
+	return w, nil
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	err := w.fw.Close()
+	<-w.done
+	return err
+}
+
+// add watches the directory osdir and those below it. If invalidate is set,
+// the files found are invalidated, as they may have been cached as missing.
+func (w *Watcher) add(osdir string, invalidate bool) error {
+	return filepath.WalkDir(osdir, func(p string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !de.IsDir() {
+			if invalidate {
+				w.cache.Invalidate(w.name(p))
+			}
+			return nil
+		}
+		if err := w.fw.Add(p); err != nil {
+			return err
+		}
+		w.mu.Lock()
+		w.dirs[p] = true
+		w.mu.Unlock()
+		return nil
+	})
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+	for {
+		select {
+		case ev, ok := <-w.fw.Events:
+			if !ok {
+				return
+			}
+			w.handle(ev)
+		case err, ok := <-w.fw.Errors:
+			if !ok {
+				return
+			}
+			w.cache.Purge()
+			if w.onError != nil {
+				w.onError(err)
+			}
+		}
+	}
+}
+
+// handle invalidates what an event may have changed.
+func (w *Watcher) handle(ev fsnotify.Event) {
+	if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+		w.mu.Lock()
+		wasDir := w.dirs[ev.Name]
+		delete(w.dirs, ev.Name)
+		w.mu.Unlock()
+		if wasDir {
+			// Everything below it went with it.
+			w.cache.Purge()
+			return
+		}
+	}
+	w.cache.Invalidate(w.name(ev.Name))
+	if ev.Has(fsnotify.Create) {
+		if info, err := os.Lstat(ev.Name); err == nil && info.IsDir() {
+			if err := w.add(ev.Name, true); err != nil && w.onError != nil {
+				w.onError(err)
+			}
+		}
+	}
+}
+
+// name returns the name on the FileSystem of the file at the OS path p.
+func (w *Watcher) name(p string) string {
+	rel, err := filepath.Rel(w.dir, p)
+	if err != nil {
+		return "/"
+	}
+	return "/" + filepath.ToSlash(rel)
+}
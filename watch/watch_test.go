@@ -0,0 +1,77 @@
+package watch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lpar/gzipped/v2"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log('hello')"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache := gzipped.NewStatCache(gzipped.StatCacheConfig{TTL: time.Hour})
+	w, err := Watch(dir, cache, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	h := gzipped.FileServer(gzipped.Dir(dir), gzipped.WithStatCache(cache))
+	encoding := func(path string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			return "missing"
+		}
+		return rec.Header().Get("Content-Encoding")
+	}
+	// Wait for the cache to see a change, which it would otherwise only see
+	// after an hour.
+	eventually := func(path, expect string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for encoding(path) != expect {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s still served with %q, expected %q", path, encoding(path), expect)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	eventually("/app.js", "")
+	if err := os.WriteFile(filepath.Join(dir, "app.js.gz"), []byte("not really gzip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eventually("/app.js", "gzip")
+	if err := os.Remove(filepath.Join(dir, "app.js.gz")); err != nil {
+		t.Fatal(err)
+	}
+	eventually("/app.js", "")
+
+	// New directories are watched too, and their files found.
+	eventually("/sub/style.css", "missing")
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "style.css"), []byte("p { color: red }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eventually("/sub/style.css", "")
+	if err := os.WriteFile(filepath.Join(sub, "style.css.gz"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eventually("/sub/style.css", "gzip")
+	if err := os.RemoveAll(sub); err != nil {
+		t.Fatal(err)
+	}
+	eventually("/sub/style.css", "missing")
+}
@@ -0,0 +1,124 @@
+package gzipped
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	fs2 "io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// ZipFS is a FileSystem serving the contents of a zip archive, so that a
+// whole asset bundle, compressed variants and all, can be shipped as one
+// file. Exists is answered from the archive's central directory, without
+// opening anything.
+//
+// Files stored in the archive without compression, as precompressed .gz and
+// .br files are best stored, are read straight from the archive and support
+// range requests. Deflated files are decompressed as they're sent, so are
+// served whole.
+type ZipFS struct {
+	ra     io.ReaderAt
+	zr     *zip.Reader
+	files  map[string]*zip.File
+	dirs   map[string]bool
+	closer io.Closer
+}
+
+// NewZipFS returns a ZipFS for the zip archive of the given size read from
+// ra.
+func NewZipFS(ra io.ReaderAt, size int64) (*ZipFS, error) {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, err
+	}
+	z := &ZipFS{ra: ra, zr: zr, files: make(map[string]*zip.File, len(zr.File)), dirs: map[string]bool{"/": true}}
+	for _, zf := range zr.File {
+		name := path.Clean("/" + zf.Name)
+		if strings.HasSuffix(zf.Name, "/") {
+			z.dirs[name] = true
+		} else {
+			z.files[name] = zf
+		}
+		for dir := path.Dir(name); !z.dirs[dir]; dir = path.Dir(dir) {
+			z.dirs[dir] = true
+		}
+	}
+	return z, nil
+}
+
+// OpenZip opens the zip archive in the named file as a ZipFS. Close closes
+// the file.
+func OpenZip(name string) (*ZipFS, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	z, err := NewZipFS(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	z.closer = file
+	return z, nil
+}
+
+// Close closes the archive file, if OpenZip opened it.
+func (z *ZipFS) Close() error {
+	if z.closer == nil {
+		return nil
+	}
+	return z.closer.Close()
+}
+
+// Exists reports whether the archive contains a file or directory called name.
+func (z *ZipFS) Exists(name string) bool {
+	name = path.Clean("/" + name)
+	_, ok := z.files[name]
+	return ok || z.dirs[name]
+}
+
+// Open opens the named file or directory in the archive.
+func (z *ZipFS) Open(name string) (http.File, error) {
+	name = path.Clean("/" + name)
+	zf, ok := z.files[name]
+	if !ok || zf.Method != zip.Store {
+		return http.FS(z.zr).Open(strings.TrimPrefix(name, "/"))
+	}
+	offset, err := zf.DataOffset()
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &zipFile{SectionReader: io.NewSectionReader(z.ra, offset, int64(zf.UncompressedSize64)), info: zf.FileInfo()}, nil
+}
+
+// ReadDir lists the named directory in the archive.
+func (z *ZipFS) ReadDir(name string) ([]fs2.DirEntry, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	return fs2.ReadDir(z.zr, name)
+}
+
+// zipFile is a file stored uncompressed in an archive, read directly.
+type zipFile struct {
+	*io.SectionReader
+	info os.FileInfo
+}
+
+func (f *zipFile) Close() error { return nil }
+
+func (f *zipFile) Stat() (os.FileInfo, error) { return f.info, nil }
+
+func (f *zipFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, errors.New("not a directory")
+}
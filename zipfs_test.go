@@ -0,0 +1,71 @@
+package gzipped
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestZipFS(t *testing.T) {
+	gz, err := os.ReadFile("testdata/file.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range []struct {
+		name    string
+		method  uint16
+		content []byte
+	}{
+		{"static/file.txt", zip.Deflate, []byte("zyxwvutsrqponmlkjihgfedcba\n")},
+		{"static/file.txt.gz", zip.Store, gz},
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: e.method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(e.content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	z, err := NewZipFS(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expect := range map[string]bool{"/static/file.txt": true, "static/file.txt.gz": true, "/static": true, "/": true, "/file.txt": false} {
+		if z.Exists(name) != expect {
+			t.Errorf("Exists(%q) = %v", name, !expect)
+		}
+	}
+
+	h := FileServer(z)
+	for _, tc := range []struct {
+		ae, rng, encoding, body string
+		status                  int
+	}{
+		{"", "", "", "zyxwvutsrqponmlkjihgfedcba\n", 200},
+		{"gzip", "", "gzip", string(gz), 200},
+		{"gzip", "bytes=0-1", "gzip", string(gz[:2]), 206},
+	} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/static/file.txt", nil)
+		req.Header.Set("Accept-Encoding", tc.ae)
+		if tc.rng != "" {
+			req.Header.Set("Range", tc.rng)
+		}
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status || rr.Header().Get("Content-Encoding") != tc.encoding || rr.Body.String() != tc.body {
+			t.Errorf("GET (%q, %q): %d %q %q", tc.ae, tc.rng, rr.Code, rr.Header().Get("Content-Encoding"), rr.Body.String())
+		}
+	}
+
+	entries, err := z.ReadDir("/static")
+	if err != nil || len(entries) != 2 {
+		t.Errorf("ReadDir returned %v, %v", entries, err)
+	}
+}